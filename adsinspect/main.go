@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Inspection commands, the first argument selects the command
+var commands = map[string]func(args []string) int {
+	"pool":	poolCmd,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: adsinspect <command> [options] files...\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "\tpool\tquality report for an encoded oligo pool\n")
+	fmt.Fprintf(os.Stderr, "Run 'adsinspect <command> -h' for the command's options\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd := commands[os.Args[1]]
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	os.Exit(cmd(os.Args[2:]))
+}
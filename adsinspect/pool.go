@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync/atomic"
+	"adscodex/criteria"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+	"adscodex/seqio"
+	"adscodex/utils"
+)
+
+// Number of bins in the GC content histogram
+const gcBins = 20
+
+// Quality report for an oligo pool
+type PoolReport struct {
+	Oligos		int		`json:"oligos"`
+	MinLen		int		`json:"min_length"`
+	MaxLen		int		`json:"max_length"`
+	GC		GCReport	`json:"gc"`
+	Homopolymers	[]int		`json:"homopolymers"`		// number of oligos by their longest homopolymer
+	Dist		*DistReport	`json:"distance,omitempty"`
+	Primers		*PrimerReport	`json:"primers,omitempty"`
+	Criteria	[]CritReport	`json:"criteria"`
+	Failures	[]string	`json:"failures"`		// the thresholds that were crossed
+}
+
+type GCReport struct {
+	Min		float64		`json:"min"`
+	Mean		float64		`json:"mean"`
+	Max		float64		`json:"max"`
+	Hist		[]int		`json:"hist"`		// number of oligos by GC content, in 5% bins
+}
+
+type DistReport struct {
+	Sampled		int		`json:"sampled"`	// number of oligos the distances were calculated for
+	MinDist		int		`json:"min"`		// minimum distance between two oligos
+	MeanMinDist	float64		`json:"mean_min"`	// mean distance to the closest oligo
+	MeanDist	float64		`json:"mean"`		// mean distance between two oligos
+}
+
+type PrimerReport struct {
+	MaxErrors	int		`json:"max_errors"`
+	Oligos		int		`json:"oligos"`		// number of oligos with cross-matches
+	Matches		map[string]int	`json:"matches"`	// number of oligos with a match, by primer
+}
+
+type CritReport struct {
+	Name		string		`json:"name"`
+	Violations	int		`json:"violations"`
+}
+
+func poolCmd(args []string) int {
+	fs := flag.NewFlagSet("pool", flag.ExitOnError)
+	ftype := fs.String("ft", "auto", "input file type (auto, csv, or fasta)")
+	p5str := fs.String("p5", "CGACATCTCGATGGCAGCAT", "5'-end primer (empty - don't check)")
+	p3str := fs.String("p3", "CAGTGAGCTGGCAACTTCCA", "3'-end primer (empty - don't check)")
+	pdist := fs.Int("pdist", 3, "maximum errors for primer cross-matches in the payload")
+	sample := fs.Int("sample", 1000, "number of oligos to calculate the distances for (0 - all, -1 - skip)")
+	seed := fs.Int64("seed", 0, "seed for choosing the sample of oligos")
+	pjson := fs.Bool("json", false, "print the report in JSON format")
+	mingc := fs.Float64("mingc", 0, "fail if an oligo has lower GC content")
+	maxgc := fs.Float64("maxgc", 1, "fail if an oligo has higher GC content")
+	maxhp := fs.Int("maxhp", 0, "fail if an oligo has a longer homopolymer (0 - disabled)")
+	mindist := fs.Int("mindist", 0, "fail if two oligos are closer (0 - disabled)")
+	maxxm := fs.Int("maxxmatch", -1, "fail if more oligos have primer cross-matches (-1 - disabled)")
+	crits := fs.String("crit", "", "comma-separated criteria that all oligos must satisfy (motifs:GAATTC+GGATCC rejects the oligos with the motifs)")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Expecting pool file names\n")
+		return 2
+	}
+
+	var p5, p3 oligo.Oligo
+	for _, p := range []struct { s string; o *oligo.Oligo } { { *p5str, &p5 }, { *p3str, &p3 } } {
+		if p.s == "" {
+			continue
+		}
+
+		var ok bool
+		if *p.o, ok = long.FromString(p.s); !ok {
+			fmt.Fprintf(os.Stderr, "Invalid primer: %s\n", p.s)
+			return 2
+		}
+	}
+
+	var required []string
+	if *crits != "" {
+		for _, c := range strings.Split(*crits, ",") {
+			if criteria.Find(c) == nil {
+				fmt.Fprintf(os.Stderr, "Unknown criteria: %s (registered: %s)\n", c, strings.Join(criteria.Names(), ", "))
+				return 2
+			}
+
+			required = append(required, c)
+		}
+	}
+
+	format, err := seqio.ParseFormatName(*ftype)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	pool, err := utils.ReadPool(fs.Args(), false, seqio.Parser(format))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	if pool.Size() == 0 {
+		fmt.Fprintf(os.Stderr, "Error: empty pool\n")
+		return 2
+	}
+
+	rep := poolReport(pool, p5, p3, *pdist, *sample, *seed)
+
+	// check the thresholds
+	fail := func(format string, a ...interface{}) {
+		rep.Failures = append(rep.Failures, fmt.Sprintf(format, a...))
+	}
+
+	if rep.GC.Min < *mingc {
+		fail("GC content %.3f below %.3f", rep.GC.Min, *mingc)
+	}
+
+	if rep.GC.Max > *maxgc {
+		fail("GC content %.3f above %.3f", rep.GC.Max, *maxgc)
+	}
+
+	if *maxhp > 0 && len(rep.Homopolymers) > *maxhp + 1 {
+		fail("homopolymer of length %d longer than %d", len(rep.Homopolymers) - 1, *maxhp)
+	}
+
+	if *mindist > 0 && rep.Dist != nil && rep.Dist.MinDist < *mindist {
+		fail("minimum distance %d less than %d", rep.Dist.MinDist, *mindist)
+	}
+
+	if *maxxm >= 0 && rep.Primers != nil && rep.Primers.Oligos > *maxxm {
+		fail("%d oligos with primer cross-matches, more than %d", rep.Primers.Oligos, *maxxm)
+	}
+
+	for _, c := range required {
+		for _, cr := range rep.Criteria {
+			if cr.Name == c && cr.Violations > 0 {
+				fail("%d oligos violate criteria %s", cr.Violations, c)
+			}
+		}
+	}
+
+	if *pjson {
+		b, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+
+		fmt.Printf("%s\n", b)
+	} else {
+		rep.print(os.Stdout)
+	}
+
+	if len(rep.Failures) != 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// Calculates the report for the pool. The primer cross-matches are
+// searched in the part of the oligos between the primers (if the oligos
+// have them). The distances are calculated for a random sample of the
+// oligos, the same sample for the same seed.
+func poolReport(pool *utils.Pool, p5, p3 oligo.Oligo, pdist, sample int, seed int64) (rep *PoolReport) {
+	ols := pool.Oligos()
+	rep = &PoolReport{Oligos: len(ols), MinLen: math.MaxInt32, Failures: []string{}}
+	rep.GC.Min = 1
+	rep.GC.Hist = make([]int, gcBins)
+
+	names := criteria.Names()
+	violations := make([]int, len(names))
+
+	// primers and their reverse complements
+	var pnames []string
+	var primers []oligo.Oligo
+	for _, p := range []struct { name string; o oligo.Oligo } { { "p5", p5 }, { "p3", p3 } } {
+		if p.o == nil {
+			continue
+		}
+
+		rc := p.o.Clone()
+		oligo.Reverse(rc)
+		oligo.Invert(rc)
+		pnames = append(pnames, p.name, p.name + "rc")
+		primers = append(primers, p.o, rc)
+	}
+
+	if primers != nil {
+		rep.Primers = &PrimerReport{MaxErrors: pdist, Matches: make(map[string]int)}
+	}
+
+	for _, ol := range ols {
+		l := ol.Len()
+		if l < rep.MinLen {
+			rep.MinLen = l
+		}
+
+		if l > rep.MaxLen {
+			rep.MaxLen = l
+		}
+
+		gc := oligo.GCcontent(ol)
+		rep.GC.Mean += gc
+		rep.GC.Min = math.Min(rep.GC.Min, gc)
+		rep.GC.Max = math.Max(rep.GC.Max, gc)
+		bin := int(gc * gcBins)
+		if bin >= gcBins {
+			bin = gcBins - 1
+		}
+		rep.GC.Hist[bin]++
+
+		hp := oligo.MaxHomopolymer(ol)
+		for len(rep.Homopolymers) <= hp {
+			rep.Homopolymers = append(rep.Homopolymers, 0)
+		}
+		rep.Homopolymers[hp]++
+
+		for i, n := range names {
+			if !criteria.Find(n).Check(ol) {
+				violations[i]++
+			}
+		}
+
+		if primers != nil {
+			payload := oligo.Oligo(ol)
+			start, end := 0, l
+			if p5 != nil && oligo.HasPrefix(ol, p5, 0) {
+				start = p5.Len()
+			}
+
+			if p3 != nil && oligo.HasSuffix(ol, p3, 0) {
+				end -= p3.Len()
+			}
+
+			if start < end {
+				payload = ol.Slice(start, end)
+			}
+
+			found := false
+			for i, p := range primers {
+				if pos, _ := oligo.Find(payload, p, pdist); pos >= 0 {
+					rep.Primers.Matches[pnames[i]]++
+					found = true
+				}
+			}
+
+			if found {
+				rep.Primers.Oligos++
+			}
+		}
+	}
+
+	rep.GC.Mean /= float64(len(ols))
+	for i, n := range names {
+		rep.Criteria = append(rep.Criteria, CritReport{n, violations[i]})
+	}
+
+	if sample < 0 {
+		return
+	}
+
+	var sols []*utils.Oligo
+	if sample > 0 && sample < len(ols) {
+		rnd := rand.New(rand.NewSource(seed))
+		for _, i := range rnd.Perm(len(ols))[0:sample] {
+			sols = append(sols, ols[i])
+		}
+	}
+
+	var n uint64
+	pcent := uint64(len(ols) / 100)
+	if sols != nil {
+		pcent = uint64(len(sols) / 100)
+	}
+
+	stats := pool.Distances(sols, func() {
+		if v := atomic.AddUint64(&n, 1); pcent != 0 && v % pcent == 0 {
+			fmt.Fprintf(os.Stderr, ".")
+		}
+	})
+
+	if pcent != 0 {
+		fmt.Fprintf(os.Stderr, "\n")
+	}
+
+	rep.Dist = &DistReport{Sampled: len(stats), MinDist: math.MaxInt32}
+	for _, st := range stats {
+		if st.MinDist < rep.Dist.MinDist {
+			rep.Dist.MinDist = st.MinDist
+		}
+
+		rep.Dist.MeanMinDist += float64(st.MinDist)
+		rep.Dist.MeanDist += st.AvgDist
+	}
+
+	if len(stats) > 0 {
+		rep.Dist.MeanMinDist /= float64(len(stats))
+		rep.Dist.MeanDist /= float64(len(stats))
+	}
+
+	return
+}
+
+func (rep *PoolReport) print(w io.Writer) {
+	fmt.Fprintf(w, "Oligos: %d, length %d-%d\n", rep.Oligos, rep.MinLen, rep.MaxLen)
+	fmt.Fprintf(w, "GC content: min %.3f mean %.3f max %.3f\n", rep.GC.Min, rep.GC.Mean, rep.GC.Max)
+	for i, n := range rep.GC.Hist {
+		if n != 0 {
+			fmt.Fprintf(w, "\t%3d-%3d%%: %d\n", i * 100 / gcBins, (i + 1) * 100 / gcBins, n)
+		}
+	}
+
+	fmt.Fprintf(w, "Longest homopolymer:\n")
+	for i, n := range rep.Homopolymers {
+		if n != 0 {
+			fmt.Fprintf(w, "\t%d: %d\n", i, n)
+		}
+	}
+
+	if rep.Dist != nil {
+		fmt.Fprintf(w, "Distance (%d oligos): min %d, mean min %.2f, mean %.2f\n", rep.Dist.Sampled, rep.Dist.MinDist, rep.Dist.MeanMinDist, rep.Dist.MeanDist)
+	}
+
+	if rep.Primers != nil {
+		fmt.Fprintf(w, "Primer cross-matches (%d errors): %d oligos\n", rep.Primers.MaxErrors, rep.Primers.Oligos)
+		for _, p := range []string{"p5", "p5rc", "p3", "p3rc"} {
+			if n, ok := rep.Primers.Matches[p]; ok {
+				fmt.Fprintf(w, "\t%s: %d\n", p, n)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Criteria violations:\n")
+	for _, c := range rep.Criteria {
+		fmt.Fprintf(w, "\t%s: %d\n", c.Name, c.Violations)
+	}
+
+	if len(rep.Failures) != 0 {
+		fmt.Fprintf(w, "FAILED:\n")
+		for _, f := range rep.Failures {
+			fmt.Fprintf(w, "\t%s\n", f)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"adscodex/criteria"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+	"adscodex/utils"
+)
+
+var testPool = []string{"ACGTACGTAC", "ACGTACGTAA", "GGGGCCCCAT"}
+
+func writeTestPool(t *testing.T) string {
+	fname := filepath.Join(t.TempDir(), "pool.fasta")
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range testPool {
+		f.WriteString(">" + s + "\n" + s + "\n")
+	}
+
+	f.Close()
+	return fname
+}
+
+func TestPoolReport(t *testing.T) {
+	var ols []oligo.Oligo
+	for _, s := range testPool {
+		ols = append(ols, long.FromString1(s))
+	}
+
+	pool := utils.NewPool(ols, false)
+	rep := poolReport(pool, long.FromString1("GTACG"), nil, 0, 0, 0)
+	if rep.Oligos != 3 || rep.MinLen != 10 || rep.MaxLen != 10 {
+		t.Fatalf("oligos %d length %d-%d", rep.Oligos, rep.MinLen, rep.MaxLen)
+	}
+
+	if rep.GC.Min != 0.4 || rep.GC.Max != 0.8 || rep.GC.Hist[8] != 1 || rep.GC.Hist[10] != 1 || rep.GC.Hist[16] != 1 {
+		t.Fatalf("GC content %+v", rep.GC)
+	}
+
+	if !reflect.DeepEqual(rep.Homopolymers, []int{0, 1, 1, 0, 1}) {
+		t.Fatalf("homopolymers %v", rep.Homopolymers)
+	}
+
+	if rep.Dist == nil || rep.Dist.Sampled != 3 || rep.Dist.MinDist != 1 {
+		t.Fatalf("distances %+v", rep.Dist)
+	}
+
+	// GTACG and its reverse complement CGTAC are in the first two
+	if rep.Primers == nil || rep.Primers.Oligos != 2 || rep.Primers.Matches["p5"] != 2 || rep.Primers.Matches["p5rc"] != 2 {
+		t.Fatalf("primers %+v", rep.Primers)
+	}
+
+	if len(rep.Criteria) != len(criteria.Names()) {
+		t.Fatalf("criteria %v", rep.Criteria)
+	}
+
+	// the same sample for the same seed
+	rep1 := poolReport(pool, nil, nil, 0, 2, 7)
+	rep2 := poolReport(pool, nil, nil, 0, 2, 7)
+	if rep1.Dist.Sampled != 2 || *rep1.Dist != *rep2.Dist || rep1.Primers != nil {
+		t.Fatalf("sample %+v %+v", rep1.Dist, rep2.Dist)
+	}
+
+	if rep := poolReport(pool, nil, nil, 0, -1, 0); rep.Dist != nil {
+		t.Fatalf("distances not skipped")
+	}
+}
+
+func TestPoolCmd(t *testing.T) {
+	fname := writeTestPool(t)
+	for _, tc := range []struct {
+		args	[]string
+		ret	int
+	} {
+		{ []string{}, 2 },
+		{ []string{"-crit", "nosuch"}, 2 },
+		{ []string{"-p5", "GTAXG"}, 2 },
+		{ []string{}, 0 },
+		{ []string{"-mingc", "0.4", "-maxgc", "0.8", "-maxhp", "4", "-mindist", "1"}, 0 },
+		{ []string{"-mingc", "0.5"}, 1 },
+		{ []string{"-maxgc", "0.7"}, 1 },
+		{ []string{"-maxhp", "3"}, 1 },
+		{ []string{"-mindist", "2"}, 1 },
+		{ []string{"-mindist", "2", "-sample", "-1"}, 0 },
+		{ []string{"-p5", "GTACG", "-pdist", "0", "-maxxmatch", "2"}, 0 },
+		{ []string{"-p5", "GTACG", "-pdist", "0", "-maxxmatch", "1"}, 1 },
+		{ []string{"-crit", "motifs:GAATTC"}, 0 },
+		{ []string{"-crit", "motifs:GGGG"}, 1 },
+	} {
+		// the first case has no pool file
+		args := tc.args
+		if tc.ret != 2 || len(args) != 0 {
+			args = append(append([]string(nil), args...), fname)
+		}
+
+		if ret := poolCmd(args); ret != tc.ret {
+			t.Errorf("%v: exit code %d expected %d", tc.args, ret, tc.ret)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"adscodex/l0"
+	"adscodex/l2"
+)
+
+var tblNames = flag.String("tbl", "../tbl/32-10.tbl", "table names, separated by commas (the search tries all of them)")
+var olen = flag.Int("olen", 0, "oligo length without the primers, instead of the table's (with -maxval)")
+var maxval = flag.Uint64("maxval", 0, "number of values in the table, instead of the table's (with -olen)")
+var size = flag.Uint64("size", 0, "input size in bytes (if no input file is specified)")
+var p5len = flag.Int("p5len", 20, "5'-end primer length")
+var p3len = flag.Int("p3len", 20, "3'-end primer length")
+var dseqnum = flag.Int("dseqnum", 3, "number of data oligos per erasure group")
+var rseqnum = flag.Int("rseqnum", 2, "number of erasure oligos per erasure group")
+var price = flag.Float64("price", 0, "synthesis price per nt")
+var redundancy = flag.Float64("redundancy", 0, "search for the configurations with at least this share of erasure oligos (0 - no search)")
+var maxdseqnum = flag.Int("maxdseqnum", 32, "maximum number of data oligos per erasure group for the search")
+var jsonOut = flag.Bool("json", false, "print the plans in JSON format")
+
+func main() {
+	flag.Parse()
+
+	var tables []l2.PlanTable
+	if *olen != 0 || *maxval != 0 {
+		if *olen == 0 || *maxval == 0 {
+			fmt.Fprintf(os.Stderr, "Both -olen and -maxval have to be specified\n")
+			os.Exit(1)
+		}
+
+		tables = append(tables, l2.PlanTable{OligoLen: *olen, MaxVal: *maxval})
+	} else {
+		for _, name := range strings.Split(*tblNames, ",") {
+			ol, mv, err := l0.ReadTableHeader(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading table '%s': %v\n", name, err)
+				os.Exit(1)
+			}
+
+			tables = append(tables, l2.PlanTable{Name: name, OligoLen: ol, MaxVal: mv})
+		}
+	}
+
+	p := l2.PlanParams{Size: *size, P5Len: *p5len, P3Len: *p3len, Dseqnum: *dseqnum, Rseqnum: *rseqnum, NtPrice: *price}
+	if flag.NArg() == 1 {
+		st, err := os.Stat(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		p.Size = uint64(st.Size())
+	}
+
+	if p.Size == 0 {
+		fmt.Fprintf(os.Stderr, "Expecting input file name or -size\n")
+		os.Exit(1)
+	}
+
+	var plans []l2.Plan
+	if *redundancy > 0 {
+		plans = l2.SearchPlans(p, tables, *redundancy, *maxdseqnum)
+		if len(plans) == 0 {
+			fmt.Fprintf(os.Stderr, "No configuration fits the input with redundancy %v\n", *redundancy)
+			os.Exit(1)
+		}
+	} else {
+		for _, t := range tables {
+			p.PlanTable = t
+			plans = append(plans, l2.NewPlan(p))
+		}
+	}
+
+	if *jsonOut {
+		b, err := json.MarshalIndent(plans, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s\n", b)
+	} else if *redundancy > 0 {
+		printPlans(plans)
+	} else {
+		for _, pl := range plans {
+			printPlan(pl)
+		}
+	}
+
+	for _, pl := range plans {
+		if !pl.Fits {
+			os.Exit(1)
+		}
+	}
+}
+
+func printPlan(pl l2.Plan) {
+	if pl.Name != "" {
+		fmt.Printf("Table:\t\t%s\n", pl.Name)
+	}
+
+	fmt.Printf("Oligo length:\t%d nts (%d + %d nts primers)\n", pl.OligoLen + pl.P5Len + pl.P3Len, pl.OligoLen, pl.P5Len + pl.P3Len)
+	fmt.Printf("Geometry:\t%d data + %d erasure oligos, redundancy %.1f%%\n", pl.Dseqnum, pl.Rseqnum, pl.Redundancy * 100)
+	fmt.Printf("Input size:\t%d bytes\n", pl.Size)
+	fmt.Printf("Maximum size:\t%d bytes (%d addresses)\n", pl.MaxSize, pl.MaxAddr)
+	if !pl.Fits {
+		fmt.Fprintf(os.Stderr, "Warning: the input doesn't fit\n")
+	}
+
+	fmt.Printf("Erasure groups:\t%d\n", pl.Groups)
+	fmt.Printf("Oligos:\t\t%d\n", pl.Oligos)
+	fmt.Printf("Nts:\t\t%d\n", pl.Nts)
+	fmt.Printf("Overhead:\t%d bytes (superblocks and padding)\n", pl.Overhead)
+	fmt.Printf("Bits per nt:\t%.4f\n", pl.BitsPerNt)
+	if pl.NtPrice != 0 {
+		fmt.Printf("Cost:\t\t%.2f\n", pl.Cost)
+	}
+}
+
+func printPlans(plans []l2.Plan) {
+	fmt.Printf("table\tolen\tdseqnum\trseqnum\tredundancy\tmaxsize\toligos\tnts\tbits/nt\tcost\n")
+	for _, pl := range plans {
+		fmt.Printf("%s\t%d\t%d\t%d\t%.3f\t%d\t%d\t%d\t%.4f\t%.2f\n", pl.Name, pl.OligoLen, pl.Dseqnum, pl.Rseqnum, pl.Redundancy, pl.MaxSize, pl.Oligos, pl.Nts, pl.BitsPerNt, pl.Cost)
+	}
+}
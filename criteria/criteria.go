@@ -4,6 +4,9 @@ package criteria
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"adscodex/oligo"
 )
 
@@ -24,9 +27,17 @@ type Criteria interface {
 	Check(o oligo.Oligo) bool
 }
 
+// the motif criteria can be registered by concurrent Finds
+var lock sync.Mutex
 var criterias map[string] Criteria
 
 func Register(name string, c Criteria) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return register(name, c)
+}
+
+func register(name string, c Criteria) (err error) {
 	if criterias == nil {
 		criterias = make(map[string] Criteria)
 	}
@@ -39,11 +50,47 @@ func Register(name string, c Criteria) (err error) {
 	return
 }
 
+// Prefix of the names of the motif criteria, the motifs are separated by +
+// (for example motifs:GAATTC+GGATCC)
+const motifsPrefix = "motifs:"
+
+// Returns the criteria with the name. The motif criteria are created (and
+// registered) the first time they are looked up (see NewMotifs).
 func Find(name string) Criteria {
-	return criterias[name]
+	lock.Lock()
+	defer lock.Unlock()
+	if c := criterias[name]; c != nil {
+		return c
+	}
+
+	if !strings.HasPrefix(name, motifsPrefix) {
+		return nil
+	}
+
+	c, err := NewMotifs(name, strings.Split(name[len(motifsPrefix):], "+")...)
+	if err != nil {
+		return nil
+	}
+
+	register(name, c)
+	return c
+}
+
+// Returns the names of the registered criteria, sorted
+func Names() (names []string) {
+	lock.Lock()
+	for n := range criterias {
+		names = append(names, n)
+	}
+	lock.Unlock()
+
+	sort.Strings(names)
+	return
 }
 
 func FindById(id uint64) Criteria {
+	lock.Lock()
+	defer lock.Unlock()
 	for _, c := range criterias {
 		if c.Id() == id {
 			return c
@@ -0,0 +1,75 @@
+package criteria
+
+import (
+	"fmt"
+	"hash/fnv"
+	"adscodex/oligo"
+)
+
+// Criteria that rejects the oligos containing any motif from a list
+// (for example restriction sites). The motifs can contain IUPAC codes.
+type motifs struct {
+	name	string
+	id	uint64
+	pats	[]*oligo.Pattern
+	maxlen	int
+}
+
+// Creates a new criteria that rejects the oligos that contain any of the
+// motifs. Find creates them for the names like motifs:GAATTC+GGATCC.
+func NewMotifs(name string, mlist ...string) (Criteria, error) {
+	m := &motifs{name: name}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	for _, s := range mlist {
+		p, ok := oligo.NewPattern(s)
+		if !ok || p.Len() == 0 {
+			return nil, fmt.Errorf("invalid motif: %s", s)
+		}
+
+		if p.Len() > m.maxlen {
+			m.maxlen = p.Len()
+		}
+
+		m.pats = append(m.pats, p)
+		h.Write([]byte{','})
+		h.Write([]byte(s))
+	}
+
+	m.id = 'M'<<40 | 'T'<<32 | uint64(h.Sum32())
+	return m, nil
+}
+
+func (m *motifs) Id() uint64 {
+	return m.id
+}
+
+func (m *motifs) FeatureLength() int {
+	return m.maxlen
+}
+
+func (m *motifs) String() string {
+	return m.name
+}
+
+func (m *motifs) Check(o oligo.Oligo) bool {
+	l := o.Len()
+	for _, p := range m.pats {
+		plen := p.Len()
+		for i := 0; i + plen <= l; i++ {
+			j := 0
+			for ; j < plen; j++ {
+				nt := o.At(i + j)
+				if nt == oligo.N || p.MaskAt(j) & (1<<uint(nt)) == 0 {
+					break
+				}
+			}
+
+			if j == plen {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+package criteria
+
+import (
+	"sync"
+	"testing"
+	"adscodex/oligo/long"
+)
+
+func TestMotifs(t *testing.T) {
+	c, err := NewMotifs("sites", "GAATTC", "GGWCC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.FeatureLength() != 6 || c.String() != "sites" {
+		t.Fatalf("feature length %d name %s", c.FeatureLength(), c)
+	}
+
+	for _, tc := range []struct {
+		s	string
+		ok	bool
+	} {
+		{ "ACGTACGTACGT", true },
+		{ "ACGAATTCACGT", false },
+		{ "ACGGACCACGT", false },
+		{ "ACGGTCCACGT", false },
+		{ "ACGGCCCACGT", true },
+		{ "GAATT", true },
+	} {
+		if ok := c.Check(long.FromString1(tc.s)); ok != tc.ok {
+			t.Errorf("%s: check %v expected %v", tc.s, ok, tc.ok)
+		}
+	}
+
+	// the unknown nts don't match the degenerate codes
+	ol, _ := long.FromStringN("ACGGNCCACGT")
+	if !c.Check(ol) {
+		t.Errorf("%v: N matched a motif", ol)
+	}
+
+	if _, err := NewMotifs("bad", "GAATTX"); err == nil {
+		t.Errorf("invalid motif accepted")
+	}
+}
+
+func TestFindMotifs(t *testing.T) {
+	name := "motifs:GAATTC+GGATCC"
+	c := Find(name)
+	if c == nil {
+		t.Fatalf("%s not found", name)
+	}
+
+	if Find(name) != c || FindById(c.Id()) != c {
+		t.Fatalf("%s not registered", name)
+	}
+
+	if c.Check(long.FromString1("ACGGATCCA")) || !c.Check(long.FromString1("ACGTACGTA")) {
+		t.Fatalf("%s checks fail", name)
+	}
+
+	if Find("motifs:GAATTC+") != nil || Find("motifs:GAAXTC") != nil || Find("nosuch") != nil {
+		t.Fatalf("invalid criteria found")
+	}
+}
+
+func TestFindMotifsConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	cs := make([]Criteria, 8)
+	for i := range cs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cs[i] = Find("motifs:GATC+CCGG")
+			Names()
+		}(i)
+	}
+	wg.Wait()
+
+	for _, c := range cs {
+		if c == nil || c != cs[0] {
+			t.Fatalf("concurrent finds return different criteria")
+		}
+	}
+}
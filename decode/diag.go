@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"adscodex/l2"
+)
+
+// Writes the diagnostics as JSON (prefix.json) and as CSV tables for
+// plotting: per-address (prefix-addr.csv), per-erasure group
+// (prefix-groups.csv), and the coverage histogram (prefix-coverage.csv)
+func writeDiag(prefix string, diag *l2.Diagnostics) error {
+	b, err := json.MarshalIndent(diag, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(prefix + ".json", append(b, '\n'), 0644); err != nil {
+		return err
+	}
+
+	err = writeCSV(prefix + "-addr.csv", "addr,ec,group,row,reads,best_dist,values", func(w *bufio.Writer) {
+		for _, a := range diag.Addrs {
+			fmt.Fprintf(w, "%d,%v,%d,%d,%d,%d,%d\n", a.Addr, a.EC, a.Group, a.Row, a.Reads, a.BestDist, a.Values)
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = writeCSV(prefix + "-groups.csv", "group,rows,erasures,erased,multi,verified,unverified,rs", func(w *bufio.Writer) {
+		for _, g := range diag.Groups {
+			fmt.Fprintf(w, "%d,%d,%d,%d,%d,%d,%d,%v\n", g.Group, g.Rows, g.Erasures, g.Erased, g.Multi, g.Verified, g.Unverified, g.RS)
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return writeCSV(prefix + "-coverage.csv", "reads,oligos", func(w *bufio.Writer) {
+		for n, c := range diag.Coverage {
+			fmt.Fprintf(w, "%d,%d\n", n, c)
+		}
+	})
+}
+
+func writeCSV(fname, header string, write func(w *bufio.Writer)) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "%s\n", header)
+	write(w)
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -1,16 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log"
 	"math"
 	"os"
+	"os/signal"
 	"runtime/pprof"
 	"adscodex/oligo"
-	"adscodex/oligo/long"
 	"adscodex/l1"
 	"adscodex/l2"
-	"adscodex/io/csv"
+	"adscodex/seqio"
 	"adscodex/io/fastq"
 )
 
@@ -24,58 +26,111 @@ var dseqnum = flag.Int("dseqnum", 3, "number of data oligos per erasure group")
 var rseqnum = flag.Int("rseqnum", 2, "number of erasure oligos per erasure group")
 
 var profname = flag.String("prof", "", "profile filename")
-var ftype = flag.String("ftype", "csv", "input file type")
+var ftype = flag.String("ftype", "auto", "input file type (auto, csv, fasta, fastq, sam, or l1dec)")
 var rndomize = flag.Bool("rndmz", false, "randomze data")
+var oldrndmz = flag.Bool("oldrndmz", false, "the data was randomized by an older version (math/rand instead of the keystream)")
 var verbose = flag.Bool("v", false, "verbose")
 var start = flag.Uint64("addr", 0, "start address")
+var r2name = flag.String("r2", "", "R2 file for paired-end fastq input, the overlapping read pairs are merged")
+var lowdist = flag.Int("lowdist", 0, "oligos decoded with at least this distance are low confidence, the data recovered with them is not verified (0 - disabled)")
+var erdist = flag.Int("erdist", 0, "oligos decoded with at least this distance are treated as erasures (0 - disabled)")
+var ambig = flag.Bool("ambig", false, "treat the oligos that are as close to more than one codeword as erasures")
+var reciters = flag.Int("reciters", 0, "maximum number of combinations to try when recovering a chunk that doesn't match its SHA1 (0 - default)")
+var rectime = flag.Duration("rectime", 0, "maximum time to spend recovering a chunk that doesn't match its SHA1 (0 - no limit)")
+var nprocs = flag.Int("procs", 0, "number of goroutines used for decoding (0 - one per CPU)")
+var reportName = flag.String("report", "", "write a JSON report of the extents, the SHA1 checks, and the parameters to the file")
+var diagPrefix = flag.String("diag", "", "write the decoding diagnostics to <prefix>.json and <prefix>-{addr,groups,coverage}.csv")
+var packedOligos = flag.Bool("packed", false, "store the reads as packed (2 bits per nt) oligos")
+var keepn = flag.Bool("keepn", false, "keep the reads with unknown nts (N) instead of dropping them")
+
+// exit codes, by the integrity of the decoded file
+const (
+	exitVerified = iota	// all data was recovered and verified
+	exitUnverified		// all data was recovered, but not all of it is verified
+	exitIncomplete		// not all data was recovered
+	exitError		// the file couldn't be decoded
+)
+
+var exitCodes = map[int]int {
+	l2.VerdictVerified:	exitVerified,
+	l2.VerdictUnverified:	exitUnverified,
+	l2.VerdictIncomplete:	exitIncomplete,
+}
 
 func main() {
 	flag.Parse()
+	os.Exit(decode())
+}
 
-	p5, ok := long.FromString(*p5str)
+// Returns the exit code, the deferred calls run before main exits
+func decode() int {
+	npolicy := oligo.NDrop
+	if *keepn {
+		npolicy = oligo.NKeep
+	}
+
+	p5, ok := oligo.NewPattern(*p5str)
 	if !ok {
 		fmt.Printf("Invalid 5'-end primer\n")
-		return
+		return exitError
 	}
 
-	p3, ok := long.FromString(*p3str)
+	p3, ok := oligo.NewPattern(*p3str)
 	if !ok {
 		fmt.Printf("Invalid 3'-end primer\n")
-		return
+		return exitError
 	}
 
 	cdc, err := l2.NewCodec(p5, p3, *tblName, *dseqnum, *rseqnum, *maxtime)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		return
+		return exitError
 	}
 
 	if flag.NArg() != 2 {
 		fmt.Printf("Expecting file name\n");
-		return
+		return exitError
 	}
 
-	cdc.SetRandomize(*rndomize)
+	cdc.SetRandomize(*rndomize || *oldrndmz)
+	cdc.SetOldRandomize(*oldrndmz)
 	cdc.SetVerbose(*verbose)
+	cdc.SetDistThresholds(*lowdist, *erdist)
+	cdc.SetAmbiguousErasures(*ambig)
+	cdc.SetRecoveryBudget(*reciters, *rectime)
+	cdc.SetParallel(*nprocs)
+	cdc.SetLogger(log.New(os.Stderr, "", 0))
+	cdc.SetProgress(func(p l2.Progress) {
+		if p.Kind == l2.ProgressReads && p.Done % 100000 == 0 {
+			fmt.Fprintf(os.Stderr, "*** %v%%\n", float32(p.Done*100)/float32(p.Total))
+		}
+	})
 
 	var oligos []oligo.Oligo
 	var entries []*l1.Entry
 
-	switch (*ftype) {
-	default:
-		err = fmt.Errorf("Unsupported input type: %s\n", *ftype)
-
-	case "csv":
-		oligos, err = csv.Read(flag.Arg(0), false)
-		fmt.Fprintf(os.Stderr, "%d oligos\n", len(oligos))
-
-	case "fastq":
-		oligos, err = fastq.Read(flag.Arg(0), false)
-		fmt.Fprintf(os.Stderr, "%d oligos\n", len(oligos))
-
-	case "l1dec":
+	if *ftype == "l1dec" {
 		entries, err = l1.ReadEntries(flag.Arg(0))
 		fmt.Fprintf(os.Stderr, "%d entries\n", len(entries))
+	} else {
+		var format seqio.Format
+
+		format, err = seqio.ParseFormatName(*ftype)
+		if err == nil && *r2name != "" {
+			if *packedOligos {
+				oligos, err = fastq.ReadPairedPacked(flag.Arg(0), *r2name, false, npolicy)
+			} else {
+				oligos, err = fastq.ReadPaired(flag.Arg(0), *r2name, false, npolicy)
+			}
+			fmt.Fprintf(os.Stderr, "%d oligos\n", len(oligos))
+		} else if err == nil {
+			if *packedOligos {
+				oligos, err = seqio.ReadPacked(flag.Arg(0), format, false, npolicy)
+			} else {
+				oligos, err = seqio.Read(flag.Arg(0), format, false, npolicy)
+			}
+			fmt.Fprintf(os.Stderr, "%d oligos\n", len(oligos))
+		}
 	}
 
 	if err != nil {
@@ -86,29 +141,49 @@ func main() {
 		f, err := os.Create(*profname)
 		if err != nil {
 			fmt.Printf("Error: creating '%s': %v\n", *profname, err)
-			return
+			return exitError
 		}
 		defer f.Close()
 
 		if err := pprof.StartCPUProfile(f); err != nil {
 			fmt.Printf("can't start CPU profile: %v\n", err)
-			return
+			return exitError
 		}
 		defer pprof.StopCPUProfile()
 	}
 
+	// on interrupt, stop decoding and write the data recovered so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	var data []l2.DataExtent
+	var fs l2.FileStatus
 
-	if oligos != nil {
-		data = cdc.Decode(*start, math.MaxUint64, oligos)
+	if oligos != nil && *diagPrefix != "" {
+		var diag *l2.Diagnostics
+
+		data, fs, diag = cdc.Diagnose(*start, math.MaxUint64, oligos)
+		if err := writeDiag(*diagPrefix, diag); err != nil {
+			fmt.Printf("Error writing the diagnostics: %v\n", err)
+		}
+	} else if oligos != nil {
+		data, fs, _ = cdc.DecodeContext(ctx, *start, math.MaxUint64, oligos)
 	} else {
-		data = cdc.DecodeL1(*start, math.MaxUint64, entries)
+		if *diagPrefix != "" {
+			fmt.Fprintf(os.Stderr, "Warning: diagnostics are not supported for l1dec input\n")
+		}
+
+		data, fs, _ = cdc.DecodeL1Context(ctx, *start, math.MaxUint64, entries)
+	}
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Warning: decoding interrupted, writing the data recovered so far\n")
 	}
 
 	of, err := os.Create(flag.Arg(1))
 	if err != nil {
 		fmt.Printf("Error creating the file: %s: %v\n", flag.Arg(1), err)
-		return
+		return exitError
 	}
 
 	var vsz, usz, bsz, hsz, off uint64
@@ -146,4 +221,28 @@ func main() {
 	}
 
 	fmt.Fprintf(os.Stderr, "%d bytes verified, %d unverified, %d best guess %d holes\n", vsz, usz, bsz, hsz)
+	// all extents have the statistics of the decode
+	var st l2.DecodeStats
+	if len(data) > 0 {
+		st = data[0].Stats
+	}
+
+	fmt.Fprintf(os.Stderr, "%d oligos, %d failed, %d low confidence, %d erased, %d ambiguous\n", st.Oligos, st.Failed, st.LowConf, st.Erased, st.Ambiguous)
+
+	if *reportName != "" {
+		rep := newReport(data, fs, st)
+		rep.Input = []string{flag.Arg(0)}
+		if *r2name != "" {
+			rep.Input = append(rep.Input, *r2name)
+		}
+
+		rep.Output = flag.Arg(1)
+		rep.Params = ReportParams{*tblName, *p5str, *p3str, *dseqnum, *rseqnum, *start, *rndomize || *oldrndmz, *oldrndmz, *maxtime, *lowdist, *erdist}
+		if err := rep.write(*reportName); err != nil {
+			fmt.Printf("Error writing the report: %v\n", err)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "verdict: %s\n", verdicts[fs.Verdict])
+	return exitCodes[fs.Verdict]
 }
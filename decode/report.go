@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"adscodex/l2"
+)
+
+// Machine-readable description of the decoded file: what parts of it can
+// be trusted, and the parameters it was decoded with
+type Report struct {
+	Input		[]string	`json:"input"`
+	Output		string		`json:"output"`
+	Params		ReportParams	`json:"params"`
+	Verdict		string		`json:"verdict"`		// verified, unverified, or incomplete
+	Size		uint64		`json:"size"`			// file size, 0 if not recovered
+	SHA1		string		`json:"sha1,omitempty"`		// whole-file hash from the superblocks
+	Hash		string		`json:"hash"`			// result of the whole-file hash check
+	Bytes		ReportBytes	`json:"bytes"`
+	Extents		[]ReportExtent	`json:"extents"`
+	Chunks		[]ReportChunk	`json:"chunks"`
+	Stats		ReportStats	`json:"stats"`
+}
+
+type ReportParams struct {
+	Table		string		`json:"table"`
+	P5		string		`json:"p5"`
+	P3		string		`json:"p3"`
+	Dseqnum		int		`json:"dseqnum"`
+	Rseqnum		int		`json:"rseqnum"`
+	Addr		uint64		`json:"addr"`
+	Randomize	bool		`json:"rndmz"`
+	OldRandomize	bool		`json:"oldrndmz"`
+	MaxTime		int64		`json:"maxtime"`
+	LowDist		int		`json:"lowdist"`
+	ErDist		int		`json:"erdist"`
+}
+
+type ReportBytes struct {
+	Verified	uint64		`json:"verified"`
+	Unverified	uint64		`json:"unverified"`
+	BestGuess	uint64		`json:"best_guess"`
+	Holes		uint64		`json:"holes"`
+}
+
+// Extent of the file, the holes are zeroes in the output file
+type ReportExtent struct {
+	Offset		uint64		`json:"offset"`
+	Length		uint64		`json:"length"`
+	Type		string		`json:"type"`		// verified, unverified, best-guess, or hole
+}
+
+type ReportChunk struct {
+	Offset		uint64		`json:"offset"`
+	Size		uint64		`json:"size"`
+	SHA1		string		`json:"sha1,omitempty"`
+	Seed		*uint16		`json:"seed,omitempty"`	// nil if not recovered
+	Hash		string		`json:"hash"`
+}
+
+type ReportStats struct {
+	Oligos		uint64		`json:"oligos"`
+	Failed		uint64		`json:"failed"`
+	LowConf		uint64		`json:"low_confidence"`
+	Erased		uint64		`json:"erased"`
+	Ambiguous	uint64		`json:"ambiguous"`
+}
+
+var extentTypes = map[int]string {
+	l2.FileVerified:	"verified",
+	l2.FileUnverified:	"unverified",
+	l2.FileBestGuess:	"best-guess",
+	l2.FileHole:		"hole",
+}
+
+var verdicts = map[int]string {
+	l2.VerdictVerified:	"verified",
+	l2.VerdictUnverified:	"unverified",
+	l2.VerdictIncomplete:	"incomplete",
+}
+
+var hashResults = map[int]string {
+	l2.HashUnknown:		"unknown",
+	l2.HashMatch:		"match",
+	l2.HashMismatch:	"mismatch",
+	l2.HashIncomplete:	"incomplete",
+}
+
+// Creates the report for the decoded data. The gaps between the extents,
+// and after the last one (if the file size is known) are holes.
+func newReport(data []l2.DataExtent, st l2.FileStatus, stats l2.DecodeStats) (rep *Report) {
+	rep = &Report{Verdict: verdicts[st.Verdict], Size: st.Size, Hash: hashResults[st.Hash], Extents: []ReportExtent{}, Chunks: []ReportChunk{}}
+	if st.SHA1 != nil {
+		rep.SHA1 = hex.EncodeToString(st.SHA1)
+	}
+
+	var off uint64
+	add := func(offset, length uint64, t int) {
+		switch t {
+		case l2.FileVerified:
+			rep.Bytes.Verified += length
+
+		case l2.FileUnverified:
+			rep.Bytes.Unverified += length
+
+		case l2.FileBestGuess:
+			rep.Bytes.BestGuess += length
+
+		case l2.FileHole:
+			rep.Bytes.Holes += length
+		}
+
+		rep.Extents = append(rep.Extents, ReportExtent{offset, length, extentTypes[t]})
+		off = offset + length
+	}
+
+	for _, d := range data {
+		if d.Offset > off {
+			add(off, d.Offset - off, l2.FileHole)
+		}
+
+		add(d.Offset, uint64(len(d.Data)), d.Type)
+	}
+
+	if st.Size > off {
+		add(off, st.Size - off, l2.FileHole)
+	}
+
+	for _, c := range st.Chunks {
+		rc := ReportChunk{Offset: c.Offset, Size: c.Size, Hash: hashResults[c.Hash]}
+		if c.SeedKnown {
+			seed := c.Seed
+			rc.Seed = &seed
+		}
+
+		if c.SHA1 != nil {
+			rc.SHA1 = hex.EncodeToString(c.SHA1)
+		}
+
+		rep.Chunks = append(rep.Chunks, rc)
+	}
+
+	rep.Stats = ReportStats{stats.Oligos, stats.Failed, stats.LowConf, stats.Erased, stats.Ambiguous}
+	return
+}
+
+func (rep *Report) write(fname string) error {
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fname, append(b, '\n'), 0644)
+}
@@ -3,9 +3,14 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
 	"math/rand"
 	"os"
+	"adscodex/oligo"
 	"adscodex/oligo/long"
+	"adscodex/io/csv"
+	"adscodex/io/fasta"
+	"adscodex/io/fastq"
 	"adscodex/l2"
 )
 
@@ -21,6 +26,9 @@ var rseqnum = flag.Int("rseqnum", 2, "number of erasure oligos per erasure group
 var rndomize = flag.Bool("rndmz", false, "randomze data")
 var shuffle = flag.Int("shuffle", 0, "random seed for shuffling the order of the oligos (0 disable)")
 var start = flag.Uint64("addr", 0, "start address")
+var ftype = flag.String("ftype", "csv", "output file type (csv, fasta, or fastq)")
+var seeds = flag.Int("seeds", 0, "number of whitening seeds to try for each chunk, implies -rndmz (0 - no search)")
+var nprocs = flag.Int("procs", 0, "number of goroutines used for encoding (0 - one per CPU)")
 
 func main() {
 	flag.Parse()
@@ -48,7 +56,37 @@ func main() {
 		return
 	}
 
-	cdc.SetRandomize(*rndomize)
+	cdc.SetRandomize(*rndomize || *seeds > 1)
+	if *seeds > 1 {
+		ss := l2.DefaultSeedSearch
+		ss.Seeds = *seeds
+		cdc.SetSeedSearch(&ss)
+	}
+	cdc.SetParallel(*nprocs)
+	cdc.SetLogger(log.New(os.Stderr, "", 0))
+
+	var write func(id string, ol oligo.Oligo) error
+	var flush func() error
+	switch *ftype {
+	default:
+		fmt.Printf("Unsupported output type: %s\n", *ftype)
+		return
+
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		write, flush = w.Write, w.Flush
+
+	case "fasta":
+		w := fasta.NewWriter(os.Stdout)
+		write, flush = w.Write, w.Flush
+
+	case "fastq":
+		w := fastq.NewWriter(os.Stdout)
+		write = func(id string, ol oligo.Oligo) error {
+			return w.Write(id, ol, nil)
+		}
+		flush = w.Flush
+	}
 
 	f, err := os.Open(flag.Arg(0))
 	if err != nil {
@@ -75,16 +113,36 @@ func main() {
 		return
 	}
 
+	// the names of the oligos contain the address and whether
+	// the oligo is data (D) or erasure (E)
+	names := make([]string, len(oligos))
+	for i := range oligos {
+		addr, ef := cdc.OligoAddress(*start, i)
+		t := 'D'
+		if ef {
+			t = 'E'
+		}
+
+		names[i] = fmt.Sprintf("L%d_%c", addr, t)
+	}
+
 	if *shuffle != 0 {
 		rand.Seed(int64(*shuffle))
 		rand.Shuffle(len(oligos),  func (i, j int) {
 			oligos[i], oligos[j] = oligos[j], oligos[i]
+			names[i], names[j] = names[j], names[i]
 		})
 	}
 
 	fmt.Fprintf(os.Stderr, "Address: %v::%v\n", *start, lastaddr)
-	saddr := (*start * uint64((*dseqnum + *rseqnum))) / uint64(*dseqnum)
 	for i, ol := range oligos {
-		fmt.Printf("%v,L%d\n", ol, uint64(i) + saddr)
+		if err := write(names[i], ol); err != nil {
+			fmt.Printf("Error while writing: %v\n", err)
+			return
+		}
+	}
+
+	if err := flush(); err != nil {
+		fmt.Printf("Error while writing: %v\n", err)
 	}
 }
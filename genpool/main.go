@@ -18,13 +18,13 @@ _	"sync"
 	"time"
 	"adscodex/oligo"
 	"adscodex/oligo/long"
-	"adscodex/utils"
+	"adscodex/search"
 	"adscodex/criteria"
 )
 
 type Pool struct {
 	ols	[]oligo.Oligo
-	trie	*utils.Trie
+	trie	*search.Trie
 	newpos	int
 }
 
@@ -50,7 +50,7 @@ var rtotal uint64		// total that were randomly generated
 
 func NewPool() *Pool {
 	ret := new(Pool)
-	ret.trie, _ = utils.NewTrie(nil)
+	ret.trie, _ = search.NewTrie(nil)
 
 	return ret
 }
@@ -15,12 +15,13 @@ _	"sync"
 	"adscodex/oligo/long"
 	"adscodex/io/csv"
 	"adscodex/utils"
+	"adscodex/search"
 	"adscodex/criteria"
 )
 
 type Pool struct {
 	ols	[]oligo.Oligo
-	trie	*utils.Trie
+	trie	*search.Trie
 	newpos	int
 }
 
@@ -37,7 +38,7 @@ var total uint64
 
 func NewPool() *Pool {
 	ret := new(Pool)
-	ret.trie, _ = utils.NewTrie(nil)
+	ret.trie, _ = search.NewTrie(nil)
 
 	return ret
 }
@@ -1,78 +1,30 @@
 package csv
 
 import (
-        "bufio"
-	"compress/gzip"
-	"fmt"
-	"io"
-	"os"
-	"strings"
 	"adscodex/oligo"
-	"adscodex/oligo/long"
+	"adscodex/seqio"
 )
 
+// Reads the oligos from the file. The sequences with unknown nts (N)
+// are dropped.
 func Read(fname string, ignoreBad bool) ([]oligo.Oligo, error) {
-	var oligos []oligo.Oligo
-
-	err := Parse(fname, func(id, sequence string, quality []byte, reverse bool) error {
-		ol, ok := long.FromString(sequence)
-		if !ok {
-			if ignoreBad {
-				// skip
-				return nil
-			} else {
-				return fmt.Errorf("invalid oligo: %s\n", sequence)
-			}
-		}
-
-		if reverse {
-			oligo.Reverse(ol)
-			oligo.Invert(ol)
-		}
+	return ReadPolicy(fname, ignoreBad, oligo.NDrop)
+}
 
-		oligos = append(oligos, ol)
-		return nil
-	})
+// Same as Read, but uses the specified policy for the sequences with
+// unknown nts
+func ReadPolicy(fname string, ignoreBad bool, np oligo.NPolicy) ([]oligo.Oligo, error) {
+	return seqio.Read(fname, seqio.CSV, ignoreBad, np)
+}
 
-	return oligos, err
+// Same as Read, but returns packed oligos (2 bits per nt). The sequences
+// with unknown nts kept by the policy are returned as long oligos.
+func ReadPacked(fname string, ignoreBad bool, np oligo.NPolicy) ([]oligo.Oligo, error) {
+	return seqio.ReadPacked(fname, seqio.CSV, ignoreBad, np)
 }
 
+// Parses the file, calling the process function for each sequence.
+// See adscodex/io for the details.
 func Parse(fname string, process func(id, sequence string, quality []byte, reverse bool) error) error {
-	var r io.Reader
-
-	f, err := os.Open(fname)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	if cf, err := gzip.NewReader(f); err == nil {
-		r = cf
-	} else {
-		f.Seek(0, 0)
-		r = f
-
-	}
-
-	sc := bufio.NewScanner(r)
-	for sc.Scan() {
-		var id string
-
-		l := sc.Text()
-		ls := strings.Split(l, ",")
-		if len(ls) == 1 {
-			ls = strings.Split(l, " ")
-		}
-
-		seq := ls[0]
-		if len(ls) > 1 {
-			id = ls[1]
-		}
-
-		if err := process(id, seq, nil, false); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return seqio.ParseFormat(fname, seqio.CSV, process)
 }
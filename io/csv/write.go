@@ -0,0 +1,27 @@
+package csv
+
+import (
+	"bufio"
+	"io"
+	"adscodex/oligo"
+)
+
+// Writes oligos in the format read by Parse, one oligo per line
+// followed by its id
+type Writer struct {
+	w	*bufio.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{bufio.NewWriter(w)}
+}
+
+func (w *Writer) Write(id string, ol oligo.Oligo) (err error) {
+	_, err = w.w.WriteString(ol.String() + "," + id + "\n")
+	return
+}
+
+// Writes any buffered data to the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
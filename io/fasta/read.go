@@ -0,0 +1,30 @@
+package fasta
+
+import (
+	"adscodex/oligo"
+	"adscodex/seqio"
+)
+
+// Reads the oligos from the file. The sequences with unknown nts (N)
+// are dropped.
+func Read(fname string, ignoreBad bool) ([]oligo.Oligo, error) {
+	return ReadPolicy(fname, ignoreBad, oligo.NDrop)
+}
+
+// Same as Read, but uses the specified policy for the sequences with
+// unknown nts
+func ReadPolicy(fname string, ignoreBad bool, np oligo.NPolicy) ([]oligo.Oligo, error) {
+	return seqio.Read(fname, seqio.FASTA, ignoreBad, np)
+}
+
+// Same as Read, but returns packed oligos (2 bits per nt). The sequences
+// with unknown nts kept by the policy are returned as long oligos.
+func ReadPacked(fname string, ignoreBad bool, np oligo.NPolicy) ([]oligo.Oligo, error) {
+	return seqio.ReadPacked(fname, seqio.FASTA, ignoreBad, np)
+}
+
+// Parses the file, calling the process function for each sequence.
+// See adscodex/io for the details.
+func Parse(fname string, process func(id, sequence string, quality []byte, reverse bool) error) error {
+	return seqio.ParseFormat(fname, seqio.FASTA, process)
+}
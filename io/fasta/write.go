@@ -0,0 +1,50 @@
+package fasta
+
+import (
+	"bufio"
+	"io"
+	"adscodex/oligo"
+)
+
+// Writes oligos in FASTA format
+type Writer struct {
+	w	*bufio.Writer
+	width	int	// maximum number of nts per line, 0 for no limit
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{bufio.NewWriter(w), 0}
+}
+
+// Sets the maximum number of nts per line. If the width is 0 (default),
+// each sequence is written on a single line.
+func (w *Writer) SetLineWidth(width int) {
+	w.width = width
+}
+
+func (w *Writer) Write(id string, ol oligo.Oligo) (err error) {
+	if _, err = w.w.WriteString(">" + id + "\n"); err != nil {
+		return
+	}
+
+	s := ol.String()
+	for len(s) > 0 {
+		n := len(s)
+		if w.width > 0 && n > w.width {
+			n = w.width
+		}
+
+		if _, err = w.w.WriteString(s[0:n] + "\n"); err != nil {
+			return
+		}
+
+		s = s[n:]
+	}
+
+	return
+}
+
+// Writes any buffered data to the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
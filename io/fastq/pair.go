@@ -0,0 +1,231 @@
+package fastq
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"adscodex/oligo"
+	"adscodex/seqio"
+)
+
+// Minimum number of overlapping nts for merging the mates
+var MinOverlap = 10
+
+// Maximum ratio of mismatches in the overlap
+var MaxMismatchRate = 0.1
+
+// Maximum quality score of a merged nt
+var MaxMergedQuality = 60
+
+// Minimum quality score of a merged nt, when the reads disagree on it.
+// 0 would look like a missing score.
+var MinMergedQuality = 2
+
+// Penalty for a mismatch when choosing the overlap
+const mismatchPenalty = 4
+
+// Merges the reads of a read pair. The reverse complement of the second
+// read (R2) is aligned to the end of the first read (R1). If the reads
+// overlap, the nts that agree get the sum of the quality scores, the
+// conflicts are resolved by taking the nt with the higher score.
+// The parts of the reads that extend past the other read's start
+// (adapters) are trimmed.
+// Returns the merged read (with the id and the orientation of R1), and
+// true if the reads overlap.
+func Merge(r1, r2 *seqio.Record) (m *seqio.Record, ok bool) {
+	s1, q1 := r1.Sequence, r1.Quality
+	s2, q2 := reverseComplement(r2.Sequence), reverseQuality(r2.Quality, len(r2.Sequence))
+	if q1 == nil {
+		q1 = make([]byte, len(s1))
+	}
+
+	// shift is the position of the start of the R2's reverse
+	// complement relative to the start of R1
+	bestScore, bestShift, bestOv := 0, 0, 0
+	for shift := MinOverlap - len(s2); shift <= len(s1) - MinOverlap; shift++ {
+		start, end := shift, shift + len(s2)
+		if start < 0 {
+			start = 0
+		}
+
+		if end > len(s1) {
+			end = len(s1)
+		}
+
+		ov := end - start
+		maxmm := int(MaxMismatchRate * float64(ov))
+		mm := 0
+		for i := start; i < end && mm <= maxmm; i++ {
+			if s1[i] != s2[i - shift] {
+				mm++
+			}
+		}
+
+		if mm > maxmm {
+			continue
+		}
+
+		score := ov - mismatchPenalty*mm
+		if score > bestScore || (score == bestScore && ov > bestOv) {
+			bestScore, bestShift, bestOv = score, shift, ov
+		}
+	}
+
+	if bestOv == 0 {
+		return nil, false
+	}
+
+	// the merged read spans from the start of R1 to the end of R2
+	mlen := bestShift + len(s2)
+	seq := make([]byte, mlen)
+	qual := make([]byte, mlen)
+	for i := 0; i < mlen; i++ {
+		j := i - bestShift
+		switch {
+		case i < len(s1) && j >= 0:
+			seq[i], qual[i] = mergeNt(s1[i], q1[i], s2[j], q2[j])
+
+		case i < len(s1):
+			seq[i], qual[i] = s1[i], q1[i]
+
+		default:
+			seq[i], qual[i] = s2[j], q2[j]
+		}
+	}
+
+	m = &seqio.Record{Id: r1.Id, Sequence: string(seq), Quality: qual, Orientation: r1.Orientation, Line: r1.Line}
+	return m, true
+}
+
+func mergeNt(nt1, q1, nt2, q2 byte) (byte, byte) {
+	if nt1 == nt2 {
+		q := int(q1) + int(q2)
+		if q > MaxMergedQuality {
+			q = MaxMergedQuality
+		}
+
+		return nt1, byte(q)
+	}
+
+	nt, q := nt1, int(q1) - int(q2)
+	if q < 0 {
+		nt, q = nt2, -q
+	}
+
+	if q < MinMergedQuality {
+		q = MinMergedQuality
+	}
+
+	return nt, byte(q)
+}
+
+func reverseComplement(s string) string {
+	r := make([]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[len(s) - i - 1]
+		switch c {
+		case 'A':
+			c = 'T'
+		case 'T':
+			c = 'A'
+		case 'C':
+			c = 'G'
+		case 'G':
+			c = 'C'
+		}
+
+		r[i] = c
+	}
+
+	return string(r)
+}
+
+func reverseQuality(q []byte, n int) []byte {
+	r := make([]byte, n)
+	if q != nil {
+		for i := 0; i < n; i++ {
+			r[i] = q[n - i - 1]
+		}
+	}
+
+	return r
+}
+
+// Returns the id of the read without the /1 or /2 suffix used by the
+// older Illumina formats
+func pairId(id string) string {
+	if strings.HasSuffix(id, "/1") || strings.HasSuffix(id, "/2") {
+		return id[0:len(id) - 2]
+	}
+
+	return id
+}
+
+// Parses paired-end files, R1 and R2 reads are matched by their ids
+// (the files are expected to have them in the same order).
+// The overlapping read pairs are merged (see Merge) and processed as
+// a single forward read. The reads that don't overlap are processed
+// separately, R1 as forward and R2 as reverse.
+func ParsePaired(fname1, fname2 string, process func(id, sequence string, quality []byte, reverse bool) error) (err error) {
+	r1, err := seqio.OpenFormat(fname1, seqio.FASTQ)
+	if err != nil {
+		return
+	}
+	defer r1.Close()
+
+	r2, err := seqio.OpenFormat(fname2, seqio.FASTQ)
+	if err != nil {
+		return
+	}
+	defer r2.Close()
+
+	for {
+		rec1, err1 := r1.Read()
+		rec2, err2 := r2.Read()
+		if err1 == io.EOF && err2 == io.EOF {
+			return nil
+		} else if err1 == io.EOF || err2 == io.EOF {
+			return fmt.Errorf("%s and %s have different number of reads", fname1, fname2)
+		} else if err1 != nil {
+			return fmt.Errorf("%s: %v", fname1, err1)
+		} else if err2 != nil {
+			return fmt.Errorf("%s: %v", fname2, err2)
+		}
+
+		if pairId(rec1.Id) != pairId(rec2.Id) {
+			return fmt.Errorf("%s:%d and %s:%d: read ids differ: %s %s", fname1, rec1.Line, fname2, rec2.Line, rec1.Id, rec2.Id)
+		}
+
+		if m, ok := Merge(rec1, rec2); ok {
+			err = process(m.Id, m.Sequence, m.Quality, false)
+		} else {
+			err = process(rec1.Id, rec1.Sequence, rec1.Quality, false)
+			if err == nil {
+				err = process(rec2.Id, rec2.Sequence, rec2.Quality, true)
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Returns a parse function (see adscodex/io Parser) that reads the R1 file
+// passed to it together with the specified R2 file
+func PairedParser(fname2 string) func(string, func(id, sequence string, quality []byte, reverse bool) error) error {
+	return func(fname1 string, process func(id, sequence string, quality []byte, reverse bool) error) error {
+		return ParsePaired(fname1, fname2, process)
+	}
+}
+
+// Reads the oligos from paired-end files, merging the overlapping pairs.
+// The sequences with unknown nts are handled according to the policy.
+func ReadPaired(fname1, fname2 string, ignoreBad bool, np oligo.NPolicy) ([]oligo.Oligo, error) {
+	return seqio.ReadFunc(PairedParser(fname2), fname1, ignoreBad, np)
+}
+
+// Same as ReadPaired, but returns packed oligos
+func ReadPairedPacked(fname1, fname2 string, ignoreBad bool, np oligo.NPolicy) ([]oligo.Oligo, error) {
+	return seqio.ReadFuncPacked(PairedParser(fname2), fname1, ignoreBad, np)
+}
@@ -0,0 +1,123 @@
+package fastq
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"adscodex/seqio"
+	"adscodex/oligo/long"
+)
+
+func randomSeq(n int) string {
+	s := make([]byte, n)
+	for i := range s {
+		s[i] = "ATCG"[rand.Intn(4)]
+	}
+
+	return string(s)
+}
+
+func quality(n int, q byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = q
+	}
+
+	return b
+}
+
+func TestMerge(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		insert := randomSeq(100 + rand.Intn(100))
+		rlen := 60 + rand.Intn(90)
+		if rlen > len(insert) {
+			rlen = len(insert)
+		}
+
+		r1 := &seqio.Record{Id: "r", Sequence: insert[0:rlen], Quality: quality(rlen, 30)}
+		r2 := &seqio.Record{Id: "r", Sequence: reverseComplement(insert[len(insert) - rlen:]), Quality: quality(rlen, 30)}
+		m, ok := Merge(r1, r2)
+		if 2*rlen - len(insert) < MinOverlap {
+			if ok && m.Sequence == insert {
+				t.Fatalf("merged reads that don't overlap")
+			}
+			continue
+		}
+
+		if !ok || m.Sequence != insert {
+			t.Fatalf("merge failed: %v %v", insert, m)
+		}
+	}
+
+	// read-through into the adapters
+	insert := randomSeq(80)
+	r1 := &seqio.Record{Sequence: insert + "AGATCGGAAGAGC", Quality: quality(93, 30)}
+	r2 := &seqio.Record{Sequence: reverseComplement("GCTCTTCCGATCT" + insert), Quality: quality(93, 30)}
+	if m, ok := Merge(r1, r2); !ok || m.Sequence != insert {
+		t.Fatalf("adapter trimming failed: %v %v", insert, m)
+	}
+
+	// conflicts are resolved by the quality score
+	insert = randomSeq(150)
+	s1 := []byte(insert[0:100])
+	s1[70] = 'A'
+	if insert[70] == 'A' {
+		s1[70] = 'T'
+	}
+
+	q1 := quality(100, 30)
+	q1[70] = 5
+	r1 = &seqio.Record{Sequence: string(s1), Quality: q1}
+	r2 = &seqio.Record{Sequence: reverseComplement(insert[50:]), Quality: quality(100, 20)}
+	m, ok := Merge(r1, r2)
+	if !ok || m.Sequence != insert {
+		t.Fatalf("conflict resolution failed: %v %v", insert, m)
+	}
+
+	if m.Quality[70] != 15 || m.Quality[60] != 50 || m.Quality[10] != 30 {
+		t.Fatalf("invalid merged quality: %v", m.Quality)
+	}
+
+	// the same quality on both sides, the merged one isn't 0
+	q1[70] = 20
+	r1 = &seqio.Record{Sequence: string(s1), Quality: q1}
+	m, ok = Merge(r1, r2)
+	if !ok || m.Quality[70] != byte(MinMergedQuality) {
+		t.Fatalf("invalid merged quality for a conflict: %v", m.Quality)
+	}
+}
+
+func TestParsePaired(t *testing.T) {
+	dir := t.TempDir()
+	fname1, fname2 := filepath.Join(dir, "r1.fastq"), filepath.Join(dir, "r2.fastq")
+	f1, _ := os.Create(fname1)
+	f2, _ := os.Create(fname2)
+	w1, w2 := NewWriter(f1), NewWriter(f2)
+	insert := randomSeq(150)
+	w1.Write("a/1", long.FromString1(insert[0:100]), nil)
+	w2.Write("a/2", long.FromString1(reverseComplement(insert[50:])), nil)
+	w1.Write("b/1", long.FromString1(strings.Repeat("AC", 50)), nil)
+	w2.Write("b/2", long.FromString1(strings.Repeat("AC", 50)), nil)
+	w1.Flush()
+	w2.Flush()
+	f1.Close()
+	f2.Close()
+
+	var seqs []string
+	var revs []bool
+	err := ParsePaired(fname1, fname2, func(id, sequence string, quality []byte, reverse bool) error {
+		seqs = append(seqs, sequence)
+		revs = append(revs, reverse)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(seqs) != 3 || seqs[0] != insert || revs[0] || revs[1] || !revs[2] {
+		t.Fatalf("invalid reads: %v %v", seqs, revs)
+	}
+}
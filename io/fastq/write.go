@@ -0,0 +1,49 @@
+package fastq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"adscodex/oligo"
+)
+
+// Quality score used when the quality of the nts is not known
+const DefaultQuality = 40
+
+// Writes oligos in FASTQ format
+type Writer struct {
+	w	*bufio.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{bufio.NewWriter(w)}
+}
+
+// Writes an oligo with the specified Phred quality scores (one per nt).
+// If quality is nil, all nts get the DefaultQuality, otherwise it has to
+// have a score for each nt.
+// The description of the oligo is set so Parse treats it as a forward read.
+func (w *Writer) Write(id string, ol oligo.Oligo, quality []byte) (err error) {
+	if quality != nil && len(quality) != ol.Len() {
+		return fmt.Errorf("%s: %d quality scores for %d nts", id, len(quality), ol.Len())
+	}
+
+	s := ol.String()
+	qual := make([]byte, len(s))
+	for i := range qual {
+		q := byte(DefaultQuality)
+		if quality != nil {
+			q = quality[i]
+		}
+
+		qual[i] = q + 33 // '!'
+	}
+
+	_, err = w.w.WriteString("@" + id + " 1\n" + s + "\n+\n" + string(qual) + "\n")
+	return
+}
+
+// Writes any buffered data to the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,30 @@
+package fastq
+
+import (
+	"bytes"
+	"testing"
+	"adscodex/oligo/long"
+)
+
+func TestWriteQuality(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	ol := long.FromString1("ACGT")
+	if err := w.Write("r1", ol, []byte{30, 31, 32, 33}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Write("r2", ol, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the quality has to have a score for each nt
+	if err := w.Write("r3", ol, []byte{30, 31}); err == nil {
+		t.Fatalf("short quality accepted")
+	}
+
+	w.Flush()
+	if s := buf.String(); s != "@r1 1\nACGT\n+\n?@AB\n@r2 1\nACGT\n+\nIIII\n" {
+		t.Fatalf("unexpected output: %q", s)
+	}
+}
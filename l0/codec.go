@@ -3,6 +3,7 @@ package l0
 // Functions for reading and writing lookup tables
 // TODO: describe the on-disk format
 import (
+	"context"
 	"errors"
 	"fmt"
 _	"math"
@@ -14,6 +15,7 @@ _	"math"
 	"adscodex/oligo/short"
 _	"adscodex/oligo/long"
 _	"adscodex/criteria"
+	"adscodex/search"
 )
 
 type Codec struct {
@@ -22,7 +24,7 @@ type Codec struct {
 	maxtime	int64
 	etbl	[]uint64
 	dmap	map[uint64]int
-	trie	*Trie
+	trie	*search.Trie
 	rnd	*rand.Rand
 }
 
@@ -38,18 +40,18 @@ func New(tblName string, maxtime int64) (c *Codec, err error) {
 		return
 	}
 
-	c.trie = new(Trie)
+	c.trie, _ = search.NewTrie(nil)
 	for n, o := range c.etbl {
 		c.dmap[o] = n
 		ol := short.Val(c.olen, o)
-		c.trie.add(ol, 0)
+		c.trie.Add(ol, 0)
 	}
 
 	c.rnd = rand.New(rand.NewSource(time.Now().UnixMilli()))
 	return
 }
 
-func (c *Codec) match(ol oligo.Oligo) (ret oligo.Oligo, dist int) {
+func (c *Codec) match(ctx context.Context, ol oligo.Oligo) (ret oligo.Oligo, dist int) {
 
 	// create random order of bps per position
 	bporder := make([]int, c.olen * 4)
@@ -71,7 +73,7 @@ func (c *Codec) match(ol oligo.Oligo) (ret oligo.Oligo, dist int) {
 		stoptime = time.Now().Add(time.Duration(c.maxtime) * time.Millisecond).UnixMilli()
 	}
 
-	m := c.trie.SearchMin(ol, bporder, stoptime)
+	m, _ := c.trie.SearchMinContext(ctx, ol, bporder, stoptime, 0)
 	if m != nil {
 		ret = m.Seq
 		dist = m.Dist
@@ -91,13 +93,23 @@ func (c *Codec) Encode(val uint64) (ret oligo.Oligo, err error) {
 }
 
 func (c *Codec) Decode(ol oligo.Oligo) (val uint64, dist int, err error) {
+	return c.DecodeContext(context.Background(), ol)
+}
+
+// Same as Decode, but gives up when the context is canceled. The search
+// returns the best match found so far, which is discarded.
+func (c *Codec) DecodeContext(ctx context.Context, ol oligo.Oligo) (val uint64, dist int, err error) {
 	var v int
 
 	if ol == nil {
 		panic("ol is nil")
 	}
 
-	match, d := c.match(ol)
+	match, d := c.match(ctx, ol)
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
 	if match == nil {
 		err = fmt.Errorf("no match")
 		return
@@ -123,6 +135,12 @@ func (c *Codec) Decode(ol oligo.Oligo) (val uint64, dist int, err error) {
 	return
 }
 
+// Returns true if more than one codeword is within dist from the oligo,
+// i.e. the value it was decoded to with distance dist is not certain
+func (c *Codec) Ambiguous(ol oligo.Oligo, dist int) bool {
+	return len(c.trie.Search(ol, dist)) > 1
+}
+
 func (c *Codec) MaxVal() uint64 {
 	return uint64(len(c.etbl))
 }
@@ -157,6 +175,33 @@ func WriteTable(olen int, tbl []uint64, fname string) (err error) {
 }
 
 
+// Reads only the header of the table file, returns the oligo length and
+// the number of values in the table (same as MaxVal)
+func ReadTableHeader(fname string) (olen int, maxval uint64, err error) {
+	var f *os.File
+	var n int
+
+	f, err = os.Open(fname)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	buf := make([]byte, 12)
+	n, err = f.Read(buf)
+	if err != nil {
+		return
+	} else if n != 12 {
+		err = errors.New("short read")
+		return
+	}
+
+	v, p := Gint32(buf)
+	olen = int(v)
+	maxval, _ = Gint64(p)
+	return
+}
+
 func ReadTable(fname string) (olen int, tbl []uint64, err error) {
 	var f *os.File
 	var n int
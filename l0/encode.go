@@ -124,7 +124,7 @@ func LoadOrGenerateEncodeTable(oligoLen int, c criteria.Criteria) (err error) {
 	if oligoLen > 10 {
 		// but warn if it will take a long time
 		// TODO: should we save it?
-		fmt.Fprintf(os.Stderr, "Warning: generation of lookup table for %d nt, it might take a long time...\n", oligoLen)
+		logf("Warning: generation of lookup table for %d nt, it might take a long time...", oligoLen)
 	}
 
 	err = RegisterEncodeTable(BuildEncodingLookupTable(c.FeatureLength(), oligoLen, oligoLen, c))
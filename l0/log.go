@@ -0,0 +1,21 @@
+package l0
+
+// Receives the warnings from the package (for example about slow lookup
+// table generation), *log.Logger implements it. The lookup tables are
+// shared by all codecs, so is the logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+var logger Logger
+
+// Sets the logger for the warnings, nil (the default) disables them
+func SetLogger(l Logger) {
+	logger = l
+}
+
+func logf(format string, v ...interface{}) {
+	if logger != nil {
+		logger.Printf(format, v...)
+	}
+}
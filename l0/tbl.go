@@ -109,13 +109,11 @@ func (lt *LookupTable) decodeLookup(prefix, oo oligo.Oligo) (o oligo.Oligo, val
 	}
 
 	if tbl == nil {
-		fmt.Printf("decodeLookup prefix %v oligo %v\n", prefix, oo)
-		panic("tbl is null")
+		panic(fmt.Sprintf("tbl is null: decodeLookup prefix %v oligo %v", prefix, oo))
 	}
 
 	if so == nil {
-		fmt.Printf("decodeLookup prefix %v oligo %v\n", prefix, oo)
-		panic("so is null")
+		panic(fmt.Sprintf("so is null: decodeLookup prefix %v oligo %v", prefix, oo))
 	}
 
 	idx := so.Uint64() >> tbl.bits
@@ -2,6 +2,7 @@ package l1
 
 import (
 _	"math/bits"
+	"context"
 	"errors"
 	"fmt"
 _	"os"
@@ -33,6 +34,7 @@ type Entry struct {
 	Dist	int
 	Data	[]byte
 	Count	int		// not set by the codec, provided externally
+	Conf	float64		// confidence of the value (see Vote), 0 if not known
 }
 
 var Eprimer = errors.New("primer mismatch")
@@ -86,13 +88,31 @@ func (c *Codec) MaxAddr() uint64 {
 // The ef parameter specifies whether the oligo is an erasure oligo (i.e. provides some erasure data 
 // instead of data data).
 func (c *Codec) Encode(address uint64, ef bool, data []byte) (ret oligo.Oligo, err error) {
-	var ol oligo.Oligo
+	ol, err := c.EncodePayload(address, ef, data)
+	if err != nil {
+		return
+	}
+
+//	fmt.Printf(">>> address %d ef %v data %d: %v\n", address, ef, data[0], ol)
+	// append the prefix
+	ret = c.prefix.Clone()
+	ret.Append(ol)
+
+	// append the suffix
+	// FIXME: we don't apply the criteria when appending p3,
+	// so theoretically we can have homopolymers etc.
+	ret.Append(c.suffix)
+	return
+}
 
+// Same as Encode, but returns only the part of the oligo between the
+// primers
+func (c *Codec) EncodePayload(address uint64, ef bool, data []byte) (ol oligo.Oligo, err error) {
 	if len(data) != 1 {
 		return nil, fmt.Errorf("L1: invalid data size %d:%d", len(data), 1)
 	}
 
-	if address > c.MaxAddr() {
+	if address >= c.MaxAddr() {
 		return nil, fmt.Errorf("address too big: %d: %d", address, c.MaxAddr())
 	}
 
@@ -104,22 +124,7 @@ func (c *Codec) Encode(address uint64, ef bool, data []byte) (ret oligo.Oligo, e
 	val <<= 8
 	val |= uint64(data[0])
 
-	ol, err = c.c0.Encode(val)
-	if err != nil {
-		ret = nil
-		return
-	}
-
-//	fmt.Printf(">>> address %d ef %v data %d: %d: %v\n", address, ef, data[0], val, ol)
-	// append the prefix
-	ret = c.prefix.Clone()
-	ret.Append(ol)
-
-	// append the suffix
-	// FIXME: we don't apply the criteria when appending p3,
-	// so theoretically we can have homopolymers etc.
-	ret.Append(c.suffix)
-	return
+	return c.c0.Encode(val)
 }
 
 // Decodes an oligo into the metadata and data it contains
@@ -127,6 +132,11 @@ func (c *Codec) Encode(address uint64, ef bool, data []byte) (ret oligo.Oligo, e
 // Returns a byte array for each data block that was recovered
 // (i.e. the parity for the block was correct)
 func (c *Codec) Decode(ol oligo.Oligo) (address uint64, ef bool, data []byte, errdist int, err error) {
+	return c.DecodeContext(context.Background(), ol)
+}
+
+// Same as Decode, but gives up when the context is canceled
+func (c *Codec) DecodeContext(ctx context.Context, ol oligo.Oligo) (address uint64, ef bool, data []byte, errdist int, err error) {
 	var val uint64
 
 	col := c.cutPrimers(ol)
@@ -135,14 +145,14 @@ func (c *Codec) Decode(ol oligo.Oligo) (address uint64, ef bool, data []byte, er
 		return
 	}
 
-	val, errdist, err = c.c0.Decode(col)
+	val, errdist, err = c.c0.DecodeContext(ctx, col)
 	if err != nil {
 		return
 	}
 
 	data = []byte { byte(val)}
 	address = val >> 8
-	if address > c.MaxAddr() {
+	if address >= c.MaxAddr() {
 		address -= c.MaxAddr()
 		ef = true
 	}
@@ -151,6 +161,17 @@ func (c *Codec) Decode(ol oligo.Oligo) (address uint64, ef bool, data []byte, er
 	return
 }
 
+// Returns true if the oligo is as close to other codewords as to the one
+// it was decoded to with distance errdist (see Decode)
+func (c *Codec) Ambiguous(ol oligo.Oligo, errdist int) bool {
+	col := c.cutPrimers(ol)
+	if col == nil {
+		return false
+	}
+
+	return c.c0.Ambiguous(col, errdist)
+}
+
 func (c *Codec) cutPrimers(ol oligo.Oligo) (ret oligo.Oligo) {
 	// First cut the primers
 	pos5, len5 := oligo.Find(ol, c.prefix, PrimerErrors)
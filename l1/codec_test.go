@@ -95,6 +95,32 @@ func TestEncode(t *testing.T) {
 	}
 }
 
+// The erasure flag is stored as MaxAddr added to the address, so the
+// erasure oligo at address 0 can't be confused with the data oligo at
+// MaxAddr
+func TestMaxAddr(t *testing.T) {
+	initTest(t)
+
+	maxaddr := cdc.MaxAddr()
+	for _, addr := range []uint64{0, maxaddr - 1} {
+		for _, ec := range []bool{false, true} {
+			ol, err := cdc.Encode(addr, ec, []byte{byte(addr)})
+			if err != nil {
+				t.Fatalf("address %d: %v", addr, err)
+			}
+
+			daddr, dec, _, _, err := cdc.Decode(ol)
+			if err != nil || daddr != addr || dec != ec {
+				t.Fatalf("address %d erasure %v: decoded %d %v: %v", addr, ec, daddr, dec, err)
+			}
+		}
+	}
+
+	if _, err := cdc.Encode(maxaddr, false, []byte{0}); err == nil {
+		t.Fatalf("address %d shouldn't be encoded", maxaddr)
+	}
+}
+
 /*
 func TestRecover2(t *testing.T) {
 	return
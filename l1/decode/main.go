@@ -11,6 +11,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"adscodex/oligo"
 	"adscodex/oligo/long"
@@ -23,6 +24,15 @@ var maxtime = flag.Int64("maxtime", 5000, "maximumm time (in ms) to spend decodi
 var p5 = flag.String("p5", "CGACATCTCGATGGCAGCAT", "5'-end primer")
 var p3 = flag.String("p3", "CAGTGAGCTGGCAACTTCCA", "3'-end primer")
 
+var efmt = flag.String("fmt", "text", "output format (text or binary)")
+var sorted = flag.Bool("sort", false, "sort the output by distance and address (keeps all entries in memory)")
+var verbose = flag.Bool("v", false, "print the decoded entries to stderr")
+
+type Seq struct {
+	seq	string
+	count	int
+}
+
 var cdc *l1.Codec
 var pr5, pr3 oligo.Oligo
 
@@ -58,29 +68,35 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 	}
 
-	ols, cntmap, err := csvRead(flag.Arg(0))
+	format, err := l1.ParseEntryFormat(*efmt)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		return
 	}
 
+	var fname string
+	if flag.NArg() == 2 {
+		fname = flag.Arg(1)
+	}
+
+	ew, err := l1.CreateEntries(fname, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
 
-	ch := make(chan string)
-	outch := make(chan []*l1.Entry)
+	ch := make(chan Seq, 1024)
+	outch := make(chan *l1.Entry, 1024)
 	nprocs := runtime.NumCPU()
+	var wg sync.WaitGroup
 	for i := 0; i < nprocs; i++ {
+		wg.Add(1)
 		go func() {
-			var ret []*l1.Entry
-
-			for {
-				seq := <- ch
-				if seq == "" {
-					break
-				}
-
-				ol, ok := long.FromString(seq)
+			defer wg.Done()
+			for s := range ch {
+				ol, ok := long.FromString(s.seq)
 				if !ok {
-					fmt.Fprintf(os.Stderr, "invalid oligo: %s\n", seq)
+					fmt.Fprintf(os.Stderr, "invalid oligo: %s\n", s.seq)
 					continue
 				}
 
@@ -89,69 +105,101 @@ func main() {
 					continue
 				}
 
-				fmt.Fprintf(os.Stderr, "%d *** %d %v %d %v\n", atomic.AddUint64(&total, 1), addr, ec, errdist, data)
-				ret = append(ret, &l1.Entry{addr, ec, errdist, data, cntmap[seq]})
-			}
+				n := atomic.AddUint64(&total, 1)
+				if *verbose {
+					fmt.Fprintf(os.Stderr, "%d *** %d %v %d %v\n", n, addr, ec, errdist, data)
+				}
 
-			outch <- ret
+				outch <- &l1.Entry{Addr: addr, EcFlag: ec, Dist: errdist, Data: data, Count: s.count}
+			}
 		}()
 	}
 
-	for _, ol := range ols {
-		ch <- ol
+	go func() {
+		wg.Wait()
+		close(outch)
+	}()
+
+	// write the entries as they are decoded, unless they need sorting
+	errch := make(chan error)
+	go func() {
+		var err error
+
+		for e := range outch {
+			if *sorted {
+				results = append(results, e)
+			} else if err == nil {
+				err = ew.Write(e)
+			}
+		}
+
+		errch <- err
+	}()
+
+	err = csvParse(flag.Arg(0), func(seq string, count int) {
+		ch <- Seq{seq, count}
+	})
+	close(ch)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 	}
 
-	// signal end of stream
-	for i := 0; i < nprocs; i++ {
-		ch <- ""
-		r := <- outch
-		results = append(results, r...)
+	if err = <-errch; err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 	}
 
-	sort.Slice(results, func (i, j int) bool {
-		if results[i].Dist < results[j].Dist {
-			return true
-		} else if results[i].Dist == results[j].Dist {
-			return results[i].Addr < results[j].Addr
-		}
+	if *sorted {
+		sort.Slice(results, func (i, j int) bool {
+			if results[i].Dist < results[j].Dist {
+				return true
+			} else if results[i].Dist == results[j].Dist {
+				return results[i].Addr < results[j].Addr
+			}
 
-		return false
-	})
+			return false
+		})
 
-	var fname string
-	if flag.NArg() == 2 {
-		fname = flag.Arg(1)
+		for _, e := range results {
+			if err = ew.Write(e); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				break
+			}
+		}
 	}
 
-	err = l1.WriteEntries(fname, results)
-	if err != nil {
+	if err = ew.Close(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 	}
 }
 
-// Reads csv file produced by utils/select
+// Parses csv file produced by utils/select, "-" reads from stdin
 // Format is "seq qubu count ..."
-func csvRead(fname string) (ols []string, cntmap map[string]int, err error) {
+func csvParse(fname string, process func(seq string, count int)) (err error) {
 	var f *os.File
 	var r io.Reader
 	var n int
 	var v64 uint64
 
-	f, err = os.Open(fname)
-	if err != nil {
-		return
+	if fname == "-" {
+		f = os.Stdin
+	} else {
+		f, err = os.Open(fname)
+		if err != nil {
+			return
+		}
+		defer f.Close()
 	}
-	defer f.Close()
 
-	if cf, err := gzip.NewReader(f); err == nil {
-		r = cf
+	br := bufio.NewReader(f)
+	if b, _ := br.Peek(2); len(b) == 2 && b[0] == 0x1f && b[1] == 0x8b {
+		if r, err = gzip.NewReader(br); err != nil {
+			return
+		}
 	} else {
-		r = f
-		f.Seek(0, 0)
+		r = br
 	}
 
-	cntmap = make(map[string]int)
-
 	sc := bufio.NewScanner(r)
 	n = 0
 	for sc.Scan() {
@@ -167,16 +215,14 @@ func csvRead(fname string) (ols []string, cntmap map[string]int, err error) {
 			return
 		}
 
-		seq := ls[0]
 		v64, err = strconv.ParseUint(ls[2], 10, 32)
 		if err != nil {
 			err = fmt.Errorf("%d: invalid count: %v: %v", n, ls[2], err)
 			return
 		}
 
-		cntmap[seq] = int(v64)
-		ols = append(ols, seq)
+		process(ls[0], int(v64))
 	}
 
-	return
+	return sc.Err()
 }
@@ -3,123 +3,403 @@ package l1
 import (
 	"bufio"
 	"compress/gzip"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strconv"
 	"strings"
 )
 
-func ReadEntries(fname string) (ret []*Entry, err error) {
-	var f *os.File
-	var r io.Reader
-	var n int
-	var v64 uint64
+// The entries can be stored in two formats: text (one entry per line,
+// space or comma separated: address, EC flag, distance, count, the
+// data bytes, and optionally the confidence as conf=<value>), and
+// binary. The binary format starts with a header (magic and version),
+// followed by the records. Each record contains the address (uvarint),
+// flags (byte), distance, count, and data length (uvarints), followed
+// by the data, and the confidence (float64 bits, little endian) if the
+// flags say so.
+const (
+	confPrefix = "conf="
+
+	binaryMagic = "ADL1"
+	binaryVersion = 1
+
+	flagEc = 0x1
+	flagConf = 0x2
+)
+
+// Entry formats
+type EntryFormat int
+
+const (
+	TextEntries EntryFormat = iota
+	BinaryEntries
+)
+
+var Eversion = errors.New("unsupported entry file version")
+
+// Streaming reader of entries. Detects gzip compression and the format.
+type EntryReader struct {
+	r	*bufio.Reader
+	c	io.Closer
+	format	EntryFormat
+	n	int		// line or record number
+}
+
+// Streaming writer of entries
+type EntryWriter struct {
+	w	*bufio.Writer
+	c	io.Closer
+	format	EntryFormat
+	buf	[]byte	// record header
+}
+
+// Creates a new entry reader
+func NewEntryReader(r io.Reader) (er *EntryReader, err error) {
+	er = new(EntryReader)
+	br := bufio.NewReader(r)
+	if b, _ := br.Peek(2); len(b) == 2 && b[0] == 0x1f && b[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+
+		br = bufio.NewReader(gr)
+	}
 
-	f, err = os.Open(fname)
+	er.r = br
+	if b, _ := br.Peek(len(binaryMagic)); string(b) == binaryMagic {
+		var hdr [len(binaryMagic) + 1]byte
+
+		if _, err = io.ReadFull(br, hdr[:]); err != nil {
+			return nil, err
+		}
+
+		if hdr[len(binaryMagic)] != binaryVersion {
+			return nil, Eversion
+		}
+
+		er.format = BinaryEntries
+	}
+
+	return
+}
+
+// Opens an entry file, "-" reads from stdin
+func OpenEntries(fname string) (er *EntryReader, err error) {
+	if fname == "-" {
+		return NewEntryReader(os.Stdin)
+	}
+
+	f, err := os.Open(fname)
 	if err != nil {
 		return
 	}
-	defer f.Close()
 
-	if cf, err := gzip.NewReader(f); err == nil {
-		r = cf
-	} else {
-		r = f
-		f.Seek(0, 0)
+	er, err = NewEntryReader(f)
+	if err != nil {
+		f.Close()
+		return
 	}
 
-	sc := bufio.NewScanner(r)
-	n = 0
-	for sc.Scan() {
-		n++
-		line := sc.Text()
-		if line == "" {
-			continue
-		}
+	er.c = f
+	return
+}
+
+// Returns the format of the entries
+func (er *EntryReader) Format() EntryFormat {
+	return er.format
+}
+
+// Returns the next entry, io.EOF if there are no more entries
+func (er *EntryReader) Next() (*Entry, error) {
+	if er.format == BinaryEntries {
+		return er.nextBinary()
+	}
 
-		en := new(Entry)
-		ls := strings.Split(line, " ")
-		if len(ls) == 1 {
-			// support both space-separated and comma-separated
-			ls = strings.Split(line, ",")
+	return er.nextText()
+}
+
+// Closes the underlying file (if the reader was created by OpenEntries)
+func (er *EntryReader) Close() error {
+	if er.c != nil {
+		return er.c.Close()
+	}
+
+	return nil
+}
+
+func (er *EntryReader) nextText() (en *Entry, err error) {
+	var v64 uint64
+	var line string
+
+	for line == "" {
+		line, err = er.r.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		} else if err != nil {
+			return
 		}
 
-		if len(ls) < 5 {
-			err = fmt.Errorf("%d: invalid line: %s", n, line)
+		er.n++
+		line = strings.TrimRight(line, "\r\n")
+	}
+
+	n := er.n
+	en = new(Entry)
+	ls := strings.Split(line, " ")
+	if len(ls) == 1 {
+		// support both space-separated and comma-separated
+		ls = strings.Split(line, ",")
+	}
+
+	if len(ls) < 4 {
+		return nil, fmt.Errorf("%d: invalid line: %s", n, line)
+	}
+
+	v64, err = strconv.ParseUint(ls[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%d: invalid address: %v: %v", n, ls[0], err)
+	}
+	en.Addr = v64
+
+	switch ls[1] {
+	case "true":
+		en.EcFlag = true
+
+	case "false":
+		en.EcFlag = false
+
+	default:
+		return nil, fmt.Errorf("%d: invalid EC flag: %v", n, ls[1])
+	}
+
+	v64, err = strconv.ParseUint(ls[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%d: invalid distance: %v: %v", n, ls[2], err)
+	}
+	en.Dist = int(v64)
+
+	v64, err = strconv.ParseUint(ls[3], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%d: invalid count: %v: %v", n, ls[3], err)
+	}
+	en.Count = int(v64)
+
+	ls = ls[4:]
+	if l := len(ls) - 1; l >= 0 && strings.HasPrefix(ls[l], confPrefix) {
+		en.Conf, err = strconv.ParseFloat(ls[l][len(confPrefix):], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%d: invalid confidence: %v: %v", n, ls[l], err)
 		}
 
-		v64, err = strconv.ParseUint(ls[0], 10, 64)
+		ls = ls[:l]
+	}
+
+	en.Data = make([]byte, len(ls))
+	for i := 0; i < len(ls); i++ {
+		v64, err = strconv.ParseUint(ls[i], 10, 8)
 		if err != nil {
-			err = fmt.Errorf("%d: invalid address: %v: %v", n, ls[0], err)
-			return
+			return nil, fmt.Errorf("%d: invalid data: %v: %v", n, ls[i], err)
 		}
-		en.Addr = v64
 
-		switch ls[1] {
-		case "true":
-			en.EcFlag = true
+		en.Data[i] = byte(v64)
+	}
 
-		case "false":
-			en.EcFlag = false
+	return
+}
 
-		default:
-			err = fmt.Errorf("%d: invalid EC flag: %v", n, ls[2])
-			return
+func (er *EntryReader) nextBinary() (en *Entry, err error) {
+	var v [4]uint64
+	var flags byte
+
+	en = new(Entry)
+	v[0], err = binary.ReadUvarint(er.r)
+	if err != nil {
+		// io.EOF only if there are no more records
+		return nil, err
+	}
+
+	er.n++
+	flags, err = er.r.ReadByte()
+	for i := 1; err == nil && i < len(v); i++ {
+		v[i], err = binary.ReadUvarint(er.r)
+	}
+
+	if err == nil {
+		en.Data = make([]byte, v[3])
+		_, err = io.ReadFull(er.r, en.Data)
+	}
+
+	if err == nil && flags & flagConf != 0 {
+		var b [8]byte
+
+		_, err = io.ReadFull(er.r, b[:])
+		en.Conf = math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+	}
+
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
 		}
 
-		v64, err = strconv.ParseUint(ls[2], 10, 32)
-		if err != nil {
-			err = fmt.Errorf("%d: invalid distance: %v: %v", n, ls[2], err)
-			return
+		return nil, fmt.Errorf("%d: invalid record: %v", er.n, err)
+	}
+
+	en.Addr = v[0]
+	en.EcFlag = flags & flagEc != 0
+	en.Dist = int(v[1])
+	en.Count = int(v[2])
+	return
+}
+
+// Creates a new entry writer. The binary format header is written
+// immediately.
+func NewEntryWriter(w io.Writer, format EntryFormat) (ew *EntryWriter, err error) {
+	ew = &EntryWriter{w: bufio.NewWriter(w), format: format}
+	if format == BinaryEntries {
+		ew.buf = make([]byte, 4*binary.MaxVarintLen64 + 1)
+		if _, err = ew.w.WriteString(binaryMagic); err == nil {
+			err = ew.w.WriteByte(binaryVersion)
 		}
-		en.Dist = int(v64)
+	}
 
-		v64, err = strconv.ParseUint(ls[3], 10, 32)
-		if err != nil {
-			err = fmt.Errorf("%d: invalid count: %v: %v", n, ls[3], err)
-			return
+	return
+}
+
+// Creates an entry file, "-" or "" writes to stdout
+func CreateEntries(fname string, format EntryFormat) (ew *EntryWriter, err error) {
+	if fname == "" || fname == "-" {
+		return NewEntryWriter(os.Stdout, format)
+	}
+
+	f, err := os.Create(fname)
+	if err != nil {
+		return
+	}
+
+	ew, err = NewEntryWriter(f, format)
+	if err != nil {
+		f.Close()
+		return
+	}
+
+	ew.c = f
+	return
+}
+
+// Writes an entry
+func (ew *EntryWriter) Write(e *Entry) (err error) {
+	if ew.format == TextEntries {
+		fmt.Fprintf(ew.w, "%d %v %d %d", e.Addr, e.EcFlag, e.Dist, e.Count)
+		for _, d := range e.Data {
+			fmt.Fprintf(ew.w, " %d", d)
 		}
-		en.Count = int(v64)
-
-		ls = ls[4:]
-		en.Data = make([]byte, len(ls))
-		for i := 0; i < len(ls); i++ {
-			v64, err = strconv.ParseUint(ls[i], 10, 8)
-			if err != nil {
-				err = fmt.Errorf("%d: invalid data: %v: %v", n, ls[i], err)
-				return
-			}
-
-			en.Data[i] = byte(v64)
+
+		if e.Conf != 0 {
+			fmt.Fprintf(ew.w, " %s%s", confPrefix, strconv.FormatFloat(e.Conf, 'g', -1, 64))
 		}
+		_, err = fmt.Fprintf(ew.w, "\n")
+		return
+	}
 
-		ret = append(ret, en)
+	var flags byte
+	if e.EcFlag {
+		flags |= flagEc
+	}
+
+	if e.Conf != 0 {
+		flags |= flagConf
+	}
+
+	b := ew.buf
+	n := binary.PutUvarint(b, e.Addr)
+	b[n] = flags
+	n++
+	n += binary.PutUvarint(b[n:], uint64(e.Dist))
+	n += binary.PutUvarint(b[n:], uint64(e.Count))
+	n += binary.PutUvarint(b[n:], uint64(len(e.Data)))
+	if _, err = ew.w.Write(b[0:n]); err == nil {
+		_, err = ew.w.Write(e.Data)
+	}
+
+	if err == nil && e.Conf != 0 {
+		binary.LittleEndian.PutUint64(b, math.Float64bits(e.Conf))
+		_, err = ew.w.Write(b[0:8])
 	}
 
 	return
 }
 
-func WriteEntries(fname string, entries []*Entry) (err error) {
-	var f *os.File
+// Flushes the buffered data and closes the underlying file (if the
+// writer was created by CreateEntries)
+func (ew *EntryWriter) Close() (err error) {
+	err = ew.w.Flush()
+	if ew.c != nil {
+		if e := ew.c.Close(); err == nil {
+			err = e
+		}
+	}
 
-	if fname != "" {
-		f, err = os.Create(fname)
-		if err != nil {
+	return
+}
+
+// Parses the format name (text or binary)
+func ParseEntryFormat(name string) (EntryFormat, error) {
+	switch name {
+	case "text":
+		return TextEntries, nil
+
+	case "binary":
+		return BinaryEntries, nil
+	}
+
+	return TextEntries, fmt.Errorf("invalid entry format: %s", name)
+}
+
+// Reads all entries from the file (in any format)
+func ReadEntries(fname string) (ret []*Entry, err error) {
+	er, err := OpenEntries(fname)
+	if err != nil {
+		return
+	}
+	defer er.Close()
+
+	for {
+		var en *Entry
+
+		en, err = er.Next()
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
 			return
 		}
-		defer f.Close()
-	} else {
-		f = os.Stdout
+
+		ret = append(ret, en)
+	}
+}
+
+// Writes the entries in text format. If fname is empty, writes to stdout.
+func WriteEntries(fname string, entries []*Entry) error {
+	return WriteEntriesFormat(fname, entries, TextEntries)
+}
+
+// Same as WriteEntries, in the specified format
+func WriteEntriesFormat(fname string, entries []*Entry, format EntryFormat) (err error) {
+	ew, err := CreateEntries(fname, format)
+	if err != nil {
+		return
 	}
 
 	for _, e := range entries {
-		fmt.Fprintf(f, "%d %v %d %d", e.Addr, e.EcFlag, e.Dist, e.Count)
-		for _, d := range e.Data {
-			fmt.Fprintf(f, " %d", d)
+		if err = ew.Write(e); err != nil {
+			ew.Close()
+			return
 		}
-		fmt.Fprintf(f, "\n")
 	}
 
-	return
+	return ew.Close()
 }
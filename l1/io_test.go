@@ -0,0 +1,105 @@
+package l1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestEntryIO(t *testing.T) {
+	var ents []*Entry
+	for i := 0; i < 1000; i++ {
+		e := &Entry{Addr: rand.Uint64() >> uint(rand.Intn(64)), EcFlag: rand.Intn(2) == 0, Dist: rand.Intn(20), Count: rand.Intn(1000)}
+		if i % 2 == 0 {
+			e.Conf = rand.Float64()
+		}
+
+		e.Data = make([]byte, rand.Intn(10))
+		rand.Read(e.Data)
+		ents = append(ents, e)
+	}
+
+	for _, format := range []EntryFormat{ TextEntries, BinaryEntries } {
+		for _, compress := range []bool{ false, true } {
+			var buf bytes.Buffer
+			var w io.Writer = &buf
+			var gw *gzip.Writer
+
+			if compress {
+				gw = gzip.NewWriter(&buf)
+				w = gw
+			}
+
+			ew, err := NewEntryWriter(w, format)
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+
+			for _, e := range ents {
+				if err := ew.Write(e); err != nil {
+					t.Fatalf("error: %v", err)
+				}
+			}
+
+			ew.Close()
+			if gw != nil {
+				gw.Close()
+			}
+
+			er, err := NewEntryReader(&buf)
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+
+			if er.Format() != format {
+				t.Fatalf("format not detected: %v", er.Format())
+			}
+
+			for i := 0; ; i++ {
+				e, err := er.Next()
+				if err == io.EOF {
+					if i != len(ents) {
+						t.Fatalf("expected %d entries, got %d", len(ents), i)
+					}
+					break
+				} else if err != nil {
+					t.Fatalf("error: %v", err)
+				}
+
+				if !reflect.DeepEqual(e, ents[i]) {
+					t.Fatalf("entry %d: %v expected %v", i, e, ents[i])
+				}
+			}
+		}
+	}
+
+	// truncated binary record
+	var buf bytes.Buffer
+	ew, _ := NewEntryWriter(&buf, BinaryEntries)
+	ew.Write(&Entry{Addr: 1, Data: []byte{1, 2, 3}})
+	ew.Close()
+	er, _ := NewEntryReader(bytes.NewReader(buf.Bytes()[0:buf.Len() - 1]))
+	if _, err := er.Next(); err == nil || err == io.EOF {
+		t.Fatalf("truncated record not detected: %v", err)
+	}
+
+	er, _ = NewEntryReader(bytes.NewBufferString("1 true 2"))
+	if _, err := er.Next(); err == nil {
+		t.Fatalf("invalid line not detected")
+	}
+
+	// the confidence is optional in the text format
+	er, _ = NewEntryReader(bytes.NewBufferString("1,false,2,3,4,conf=0.25\n5 true 0 1 6\n7 true 0 1 8 conf=x\n"))
+	for _, c := range []float64{0.25, 0} {
+		if e, err := er.Next(); err != nil || e.Conf != c || len(e.Data) != 1 {
+			t.Fatalf("entry %v confidence expected %v: %v", e, c, err)
+		}
+	}
+
+	if _, err := er.Next(); err == nil {
+		t.Fatalf("invalid confidence not detected")
+	}
+}
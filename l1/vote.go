@@ -0,0 +1,130 @@
+package l1
+
+import (
+	"math"
+	"sort"
+)
+
+// Parameters of the consensus voting
+type VoteParams struct {
+	DistFactor	float64	// likelihood factor for each error (distance) of an entry
+	Margin		float64	// keep the values with weight at least Margin times the winner's weight
+	MaxDist		int	// ignore the entries with larger distance (0 - no limit)
+}
+
+var DefaultVoteParams = VoteParams{DistFactor: 0.5, Margin: 0.5}
+
+// Returns the weight of the entry's vote: the count of the reads,
+// multiplied by the likelihood that the value is correct given the
+// distance
+func (p *VoteParams) Weight(e *Entry) float64 {
+	cnt := e.Count
+	if cnt == 0 {
+		cnt = 1
+	}
+
+	return float64(cnt) * math.Pow(p.DistFactor, float64(e.Dist))
+}
+
+type voteKey struct {
+	addr	uint64
+	ec	bool
+}
+
+type voteValue struct {
+	e	*Entry
+	w	float64
+}
+
+// Collects the votes of the entries for the values of the addresses
+// (data and erasure code entries are voted separately). Only the
+// distinct values are kept in memory.
+type Voter struct {
+	p	VoteParams
+	votes	map[voteKey]map[string]*voteValue
+}
+
+// Creates a new voter
+func NewVoter(p *VoteParams) *Voter {
+	return &Voter{*p, make(map[voteKey]map[string]*voteValue)}
+}
+
+// Adds the entry's vote for its value, with the entry's weight
+func (vt *Voter) Add(e *Entry) {
+	if vt.p.MaxDist > 0 && e.Dist > vt.p.MaxDist {
+		return
+	}
+
+	k := voteKey{e.Addr, e.EcFlag}
+	vm := vt.votes[k]
+	if vm == nil {
+		vm = make(map[string]*voteValue)
+		vt.votes[k] = vm
+	}
+
+	v := vm[string(e.Data)]
+	if v == nil {
+		v = &voteValue{&Entry{Addr: e.Addr, EcFlag: e.EcFlag, Dist: e.Dist, Data: e.Data}, 0}
+		vm[string(e.Data)] = v
+	}
+
+	v.w += vt.p.Weight(e)
+	v.e.Count += e.Count
+	if e.Dist < v.e.Dist {
+		v.e.Dist = e.Dist
+	}
+}
+
+// Returns the winning values, and the runner-ups that are within the
+// margin of the winner, ordered by address and confidence.
+// The Conf field of the returned entries is the value's share of the
+// total weight for the address, the Count is the sum of the counts, and
+// the Dist is the minimum distance of the entries that voted for it.
+func (vt *Voter) Results() (ret []*Entry) {
+	for _, vm := range vt.votes {
+		var total, max float64
+
+		for _, v := range vm {
+			total += v.w
+			if v.w > max {
+				max = v.w
+			}
+		}
+
+		for _, v := range vm {
+			if v.w < max * vt.p.Margin {
+				continue
+			}
+
+			v.e.Conf = v.w / total
+			ret = append(ret, v.e)
+		}
+	}
+
+	sort.Slice(ret, func (i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.Addr != b.Addr {
+			return a.Addr < b.Addr
+		} else if a.EcFlag != b.EcFlag {
+			return !a.EcFlag
+		}
+
+		if a.Conf != b.Conf {
+			return a.Conf > b.Conf
+		}
+
+		return string(a.Data) < string(b.Data)
+	})
+
+	return
+}
+
+// Finds the consensus values for the entries (see Voter)
+func Vote(entries []*Entry, p *VoteParams) []*Entry {
+	vt := NewVoter(p)
+	for _, e := range entries {
+		vt.Add(e)
+	}
+
+	return vt.Results()
+}
@@ -0,0 +1,37 @@
+package l1
+
+import (
+	"testing"
+)
+
+func TestVote(t *testing.T) {
+	ents := []*Entry{
+		&Entry{Addr: 1, Dist: 0, Count: 3, Data: []byte{1}},
+		&Entry{Addr: 1, Dist: 4, Count: 10, Data: []byte{2}},	// many reads, but far
+		&Entry{Addr: 1, Dist: 1, Count: 2, Data: []byte{3}},
+		&Entry{Addr: 1, EcFlag: true, Dist: 0, Count: 1, Data: []byte{4}},
+		&Entry{Addr: 0, Dist: 9, Count: 1, Data: []byte{5}},
+	}
+
+	p := VoteParams{DistFactor: 0.5, Margin: 0.3}
+	ret := Vote(ents, &p)
+	if len(ret) != 4 {
+		t.Fatalf("expected 4 entries, got %d: %v", len(ret), ret)
+	}
+
+	// addr 1: weights 3, 0.625, 1
+	if ret[0].Addr != 0 || ret[1].Data[0] != 1 || ret[2].Data[0] != 3 || !ret[3].EcFlag {
+		t.Fatalf("invalid order: %v %v %v %v", ret[0], ret[1], ret[2], ret[3])
+	}
+
+	if c := ret[1].Conf; c < 3/4.625 - 1e-9 || c > 3/4.625 + 1e-9 {
+		t.Fatalf("invalid confidence: %v", c)
+	}
+
+	// only the winners
+	p.Margin, p.MaxDist = 1, 5
+	ret = Vote(ents, &p)
+	if len(ret) != 2 || ret[0].Data[0] != 1 || ret[0].Count != 3 || ret[1].Conf != 1 {
+		t.Fatalf("invalid winners: %v", ret)
+	}
+}
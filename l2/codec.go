@@ -1,15 +1,16 @@
 package l2
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"math/rand"
 	"runtime"
 	"crypto/sha1"
 	"hash/crc64"
 	"math"
-	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 	"adscodex/oligo"
 	"adscodex/l0"
 	"adscodex/l1"
@@ -25,11 +26,32 @@ type Codec struct {
 	rseqnum	int		// number of erasure sequences
 	compat	bool		// if true, use the 0.9 file format
 	rndmz	bool		// if true, randomize the data
+	oldrndmz bool		// if true, the data was randomized by math/rand (versions before the keystream)
+	seeds	*SeedSearch	// whitening seed search parameters (nil - disabled)
 
 	c1	*l1.Codec
 	ec	reedsolomon.Encoder
 
-	verbose	bool		// print information about sequences
+	verbose	bool		// log information about the recovery
+	log	Logger		// logger for the messages (nil - none, see SetLogger)
+	prog	*progress	// progress callback (nil - none, see SetProgress)
+	nprocs	int		// number of goroutines for encoding and decoding (0 - one per CPU)
+
+	lowdist	int		// oligos decoded with distance >= lowdist are low confidence (0 - disabled)
+	erdist	int		// oligos decoded with distance >= erdist are erasures (0 - disabled)
+	ambig	bool		// the ambiguous oligos are erasures
+
+	reciters int		// maximum number of combinations tried to recover a chunk
+	rectime	time.Duration	// maximum time spent recovering a chunk (0 - no limit)
+}
+
+// Statistics of the oligos (or L1 entries) processed by the decoder
+type DecodeStats struct {
+	Oligos		uint64	// number of oligos (or entries) processed
+	Failed		uint64	// oligos that failed to decode
+	LowConf		uint64	// oligos downgraded to low confidence because of their distance
+	Erased		uint64	// oligos treated as erasures because of their distance
+	Ambiguous	uint64	// oligos treated as erasures because more than one codeword is as close
 }
 
 // Data extent
@@ -38,6 +60,7 @@ type DataExtent struct {
 	Offset		uint64
 	Data		[]byte
 	Type		int		// FileVerified, FileUnverified, or FileBestGuess
+	Stats		DecodeStats	// statistics of the decode that returned the extent
 }
 
 // for debugging
@@ -50,6 +73,7 @@ type DecRecord struct {
 	Data	[][]byte
 	Oaddr	int64			// the actual address in the oligo, negative if EC oligo
 	Offset	int64			// file offset (-1 for non-data oligoes like EC oligos or superblocks)
+	Dist	int			// L1 decoding distance
 }
 
 var Eec = errors.New("parity blocks don't match")
@@ -94,10 +118,110 @@ func (c *Codec) SetRandomize(rndmz bool) {
 	c.rndmz = rndmz
 }
 
+// Sets the parameters for the whitening seed search (see SeedSearch),
+// nil disables it. The search is done only if the data is randomized.
+func (c *Codec) SetSeedSearch(ss *SeedSearch) {
+	c.seeds = ss
+}
+
+// Decode the data randomized by the older versions of the codec, that
+// used math/rand instead of the keystream. Only for decoding.
+func (c *Codec) SetOldRandomize(oldrndmz bool) {
+	c.oldrndmz = oldrndmz
+}
+
+// Sets the number of goroutines used for encoding and decoding, zero
+// (the default) uses one per CPU
+func (c *Codec) SetParallel(nprocs int) {
+	c.nprocs = nprocs
+}
+
+func (c *Codec) procs() int {
+	if c.nprocs > 0 {
+		return c.nprocs
+	}
+
+	return runtime.NumCPU()
+}
+
 func (c *Codec) SetVerbose(v bool) {
 	c.verbose = v
 }
 
+// Sets the distance thresholds for the soft decoding. The blocks from the
+// oligos decoded with distance at least lowdist are used for erasure
+// recovery, but the data recovered using them is not verified. The
+// blocks from the oligos decoded with distance at least erdist are not
+// used for the recovery at all, only as a best guess. Zero disables the
+// threshold.
+func (c *Codec) SetDistThresholds(lowdist, erdist int) {
+	c.lowdist = lowdist
+	c.erdist = erdist
+}
+
+// Sets the budget for the recovery of the chunks that don't match their
+// SHA1 hash. The combinations of the candidate blocks are tried from the
+// most probable, until one matches, iters combinations are tried, or
+// the time runs out. Zero iters uses the default, zero maxtime disables
+// the time limit.
+func (c *Codec) SetRecoveryBudget(iters int, maxtime time.Duration) {
+	c.reciters = iters
+	c.rectime = maxtime
+}
+
+// If set, the oligos that have more than one codeword at the distance
+// they were decoded with are treated as erasures. Checking it takes
+// another search of the Level 0 table for each oligo that doesn't match
+// a codeword exactly. The L1 entries (see DecodeL1) are not checked.
+func (c *Codec) SetAmbiguousErasures(ambig bool) {
+	c.ambig = ambig
+}
+
+// Returns the confidence of the blocks decoded from the oligo (nil for
+// L1 entries) with the specified distance and updates the statistics
+func (c *Codec) blkConf(st *DecodeStats, ol oligo.Oligo, dist int) int {
+	atomic.AddUint64(&st.Oligos, 1)
+	switch {
+	case c.erdist > 0 && dist >= c.erdist:
+		atomic.AddUint64(&st.Erased, 1)
+		return blkErased
+
+	case c.ambig && ol != nil && dist > 0 && c.c1.Ambiguous(ol, dist):
+		atomic.AddUint64(&st.Ambiguous, 1)
+		return blkErased
+
+	case c.lowdist > 0 && dist >= c.lowdist:
+		atomic.AddUint64(&st.LowConf, 1)
+		return blkLow
+	}
+
+	return blkHigh
+}
+
+// Sets the statistics of the decode in the extents it returns
+func setStats(data []DataExtent, st *DecodeStats) {
+	for i := range data {
+		data[i].Stats = *st
+	}
+}
+
+// Returns the weight of a block decoded with the specified distance,
+// used to order the candidates when recovering the chunks
+func blkWeight(dist int) float64 {
+	return math.Pow(l1.DefaultVoteParams.DistFactor, float64(dist))
+}
+
+func (c *Codec) newFile(ctx context.Context) (f *File) {
+	f = newFile(c.dseqnum + c.rseqnum, c.c1.BlockNum(), c.c1.BlockSize(), c.rseqnum, c.ec, c.compat, c.rndmz)
+	f.ctx = ctx
+	f.setRecoveryBudget(c.reciters, c.rectime)
+	f.oldrndmz = c.oldrndmz
+	f.log = c.log
+	f.verbose = c.verbose
+	f.prog = c.prog
+	return
+}
+
 func (c *Codec) MaxAddr() uint64 {
 	return c.c1.MaxAddr()
 }
@@ -107,9 +231,18 @@ func (c *Codec) MaxAddr() uint64 {
 // The data parameter points to the data to encode.
 // The function returns the next available address as well as an
 // array of oligos that encode the data.
-// If the data is not aligned, it is padded with random values at
-// the end.
+// If the data is not aligned, it is padded at the end with pseudo-random
+// values (see keystream), the same data is always encoded the same way.
+// The erasure groups are encoded in parallel (see SetParallel), the
+// oligos are returned in the order of their addresses.
 func (c *Codec) Encode(addr uint64, data []byte) (nextaddr uint64, oligos []oligo.Oligo, err error) {
+	return c.EncodeContext(context.Background(), addr, data)
+}
+
+// Same as Encode, but stops when the context is canceled or its deadline
+// passes. The oligos for the erasure groups encoded before that are
+// returned with the context's error.
+func (c *Codec) EncodeContext(ctx context.Context, addr uint64, data []byte) (nextaddr uint64, oligos []oligo.Oligo, err error) {
 	blknum := c.c1.BlockNum()
 	blksz := c.c1.BlockSize()
 
@@ -118,87 +251,201 @@ func (c *Codec) Encode(addr uint64, data []byte) (nextaddr uint64, oligos []olig
 		return
 	}
 
-	// first add the superblocks
-	nd, super := c.addSupers(data)
-
-	// then pad the data at the back so it's multiple of the data per erasure group
 	egsz := ecGroupDataSize(blksz, blknum, c.dseqnum)
-	if (len(nd) + len(super))%egsz != 0 {
-		n := egsz - ((len(nd) + len(super)) % egsz)
-		for i := 0; i < n; i++ {
-			nd = append(nd, byte(rand.Int31n(256)))
+	nd, err := c.layout(ctx, addr, data, egsz)
+	if err != nil {
+		return
+	}
+
+	ecgrpaddr := c.groupAddrs()
+	c.logf("original size: %d bytes, new size %d bytes, erasure groups size %d", len(data), len(nd), egsz)
+
+	// the erasure groups are encoded in parallel, each group knows
+	// where its oligos go, so the order is the same as the addresses
+	egnum := len(nd) / egsz
+	olnum := c.dseqnum + c.rseqnum
+	oligos = make([]oligo.Oligo, egnum * olnum)
+	errs := make([]error, egnum)
+
+	var failed int32
+	var ndone uint64
+	var wg sync.WaitGroup
+	ch := make(chan int)
+	nprocs := c.procs()
+	for i := 0; i < nprocs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range ch {
+				errs[g] = c.encodeGroup(addr + uint64(g * ecgrpaddr), nd[g*egsz:(g+1)*egsz], oligos[g*olnum:(g+1)*olnum])
+				if errs[g] != nil {
+					atomic.StoreInt32(&failed, 1)
+				} else {
+					c.prog.step(ProgressGroups, &ndone, uint64(egnum))
+				}
+			}
+		}()
+	}
+
+	// number of groups sent to the goroutines
+	n := 0
+feed:
+	for ; n < egnum && atomic.LoadInt32(&failed) == 0; n++ {
+		select {
+		case ch <- n:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	// on error, return the oligos for the groups before the first failed one
+	for g, e := range errs[0:n] {
+		if e != nil {
+			oligos = oligos[0:g*olnum]
+			err = e
+			return
 		}
 	}
 
-	ecgrpaddr := c.dseqnum
-	if ecgrpaddr < c.rseqnum {
-		ecgrpaddr = c.rseqnum
+	if n < egnum {
+		oligos = oligos[0:n*olnum]
+		err = ctx.Err()
+		return
 	}
 
-	// repeat the starting super at the end
-	nd = append(nd, super...)
-	fmt.Fprintf(os.Stderr, "original size: %d bytes, new size %d bytes, erasure groups size %d\n", len(data), len(nd), egsz)
-	for len(nd) > 0 {
-		var dblks [][][]byte
+	nextaddr = addr + uint64(egnum * ecgrpaddr)
+	return
+}
 
-		d := nd[0:egsz]
-		if len(d) != egsz {
-			panic("internal error")
+// Encodes the data for an erasure group starting at addr into the oligos
+func (c *Codec) encodeGroup(addr uint64, d []byte, oligos []oligo.Oligo) (err error) {
+	dblks, err := ecGroupEncode(c.c1.BlockSize(), c.c1.BlockNum(), c.dseqnum, c.rseqnum, c.ec, d)
+	if err != nil {
+		return
+	}
+
+	for i, rblk := range dblks {
+		a := addr + uint64(i)
+		e := false
+		if i >= c.dseqnum {
+			a -= uint64(c.dseqnum)
+			e = true
+		}
+
+		// FIXME: we know that blksz is 1
+		buf := make([]byte, len(rblk))
+		for i, bb := range(rblk) {
+			buf[i] = bb[0]
 		}
 
-		dblks, err = ecGroupEncode(blksz, blknum, c.dseqnum, c.rseqnum, c.ec, d)
+		oligos[i], err = c.c1.Encode(a, e, buf)
 		if err != nil {
+			err = fmt.Errorf("address %d: %v", a, err)
 			return
 		}
+//		fmt.Fprintf(os.Stderr, "%d %v %v\n", a, e, oligos[i])
+	}
 
-		for i, rblk := range dblks {
-			var o oligo.Oligo
+	return
+}
 
-			a := addr + uint64(i)
-			e := false
-			if i >= c.dseqnum {
-				a -= uint64(c.dseqnum)
-				e = true
-			}
+// Returns the data as it is stored in the erasure groups: randomized (if
+// enabled), with the superblocks, padded to a multiple of the erasure
+// group size egsz, and the starting superblock repeated at the end.
+// The addr is the address of the first oligo (used by the seed search).
+func (c *Codec) layout(ctx context.Context, addr uint64, data []byte, egsz int) (nd []byte, err error) {
+	// first add the superblocks
+	nd, super, err := c.addSupers(ctx, addr, data)
+	if err != nil {
+		return
+	}
 
-			// FIXME: we know that blksz is 1
-			buf := make([]byte, len(rblk))
-			for i, bb := range(rblk) {
-				buf[i] = bb[0]
-			}
+	// then pad the data at the back so it's multiple of the data per erasure group
+	// the padding is the continuation of the keystream after the data,
+	// so the encoding is reproducible
+	if (len(nd) + len(super))%egsz != 0 {
+		n := egsz - ((len(nd) + len(super)) % egsz)
+		pad := make([]byte, n)
+		newKeystreamAt(uint64(len(data)), uint64(len(data))).read(pad)
+		nd = append(nd, pad...)
+	}
 
-			o, err = c.c1.Encode(a, e, buf)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %d %v\n", a, err)
-				return
-			}
+	// repeat the starting super at the end
+	nd = append(nd, super...)
+	return
+}
 
-			oligos = append(oligos, o)
-//			fmt.Fprintf(os.Stderr, "%d %v %v\n", a, e, o)
-		}
+// Returns the number of L1 addresses used by each erasure group, the data
+// and the erasure oligos have separate address spaces
+func (c *Codec) groupAddrs() int {
+	if c.dseqnum < c.rseqnum {
+		return c.rseqnum
+	}
+
+	return c.dseqnum
+}
 
-		addr += uint64(ecgrpaddr)
-		nd = nd[egsz:]
+// Returns the L1 address and the erasure flag of the oligo at position idx
+// in the array returned by Encode called with the starting address addr.
+func (c *Codec) OligoAddress(addr uint64, idx int) (oaddr uint64, ef bool) {
+	ecgrpaddr := c.groupAddrs()
+	n := c.dseqnum + c.rseqnum
+	i := idx % n
+	oaddr = addr + uint64(idx / n) * uint64(ecgrpaddr)
+	if i >= c.dseqnum {
+		i -= c.dseqnum
+		ef = true
 	}
 
-	nextaddr = addr
+	oaddr += uint64(i)
 	return
 }
 
-func (c *Codec) addSupers(data []byte) (nd []byte, super []byte) {
+func (c *Codec) addSupers(ctx context.Context, addr uint64, data []byte) (nd []byte, super []byte, err error) {
 	datasz := uint64(len(data))
 
+	// whitening seed for each chunk
+	seeds := make([]uint16, (len(data) + superChunkSize - 1) / superChunkSize)
 	if c.rndmz {
-		rnd := rand.New(rand.NewSource(int64(datasz)))
-		for i := 0; i < len(data); i++ {
-			r := byte(rnd.Int31n(256))
-			data[i] ^= r
+		// don't modify the caller's data
+		data = append([]byte(nil), data...)
+
+		var kmers map[string]bool
+		if c.seeds != nil && c.seeds.Seeds > 1 {
+			kmers = c.primerKmers(c.seeds.PrimerK)
 		}
 
+		for i := range seeds {
+			off := i * superChunkSize
+			end := off + superChunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+
+			if kmers != nil {
+				// the chunk follows the superblocks before it
+				pos := superSize + i * (superSize + superChunkSize)
+				seeds[i], err = c.findSeed(ctx, c.seeds, addr, pos, data[off:end], datasz, uint64(off), kmers)
+				if err != nil {
+					return
+				}
+			}
+
+			newKeystreamAt(chunkKey(datasz, seeds[i]), uint64(off)).xor(data[off:end])
+		}
+	}
+
+	var flags uint64
+	for _, seed := range seeds {
+		if seed != 0 {
+			flags |= superSeeded
+		}
 	}
 
 	// start with the superblock
-	super = l0.Pint64(datasz, super)			// "file" size
+	super = l0.Pint64(datasz | flags << superSeedShift, super)	// "file" size and the flags
 	s := sha1.Sum(data)
 	super = append(super, s[:]...)				// SHA1 sum for the whole "file"
 	crc := crc64.Checksum(super, crctbl)
@@ -216,7 +463,8 @@ func (c *Codec) addSupers(data []byte) (nd []byte, super []byte) {
 
 		// append the intermediate superblock
 		p := len(nd)
-		nd = l0.Pint64(datasz, nd)				// "file" size
+		nd = l0.Pint64(datasz | uint64(seeds[0]) << superSeedShift, nd)	// "file" size and the chunk's whitening seed
+		seeds = seeds[1:]
 		s = sha1.Sum(data[0:sz])
 		nd = append(nd, s[:]...)				// SHA1 sum for the data chunk
 		crc = crc64.Checksum(nd[p:], crctbl)
@@ -232,10 +480,37 @@ func (c *Codec) addSupers(data []byte) (nd []byte, super []byte) {
 // The oligos array may contain extra oligo sequences that are not used.
 // Return all data that we recovered in data extents
 func (c *Codec) Decode(start, end uint64, oligos []oligo.Oligo) (data []DataExtent) {
+	data, _, _ = c.DecodeContext(context.Background(), start, end, oligos)
+	return
+}
+
+// Same as Decode, but stops when the context is canceled or its deadline
+// passes. The decoding goroutines and the recovery are shut down, and the
+// data recovered so far is returned with the context's error.
+// Also returns the integrity of the recovered file (see FileStatus).
+func (c *Codec) DecodeContext(ctx context.Context, start, end uint64, oligos []oligo.Oligo) (data []DataExtent, fs FileStatus, err error) {
+	var st DecodeStats
+
+	f := c.newFile(ctx)
+	c.decodeOligos(ctx, f, start, end, oligos, &st, f.add)
+
+	data = f.close()
+	setStats(data, &st)
+	fs = f.status()
+	c.logf("%d extents", len(data))
+	err = ctx.Err()
+
+	return
+}
+
+// Decodes the oligos in parallel, and passes the blocks of the ones with
+// addresses from start to end to add (with the address relative to start).
+// Updates the statistics st. Returns after all oligos are fed, or the
+// context is canceled.
+func (c *Codec) decodeOligos(ctx context.Context, f *File, start, end uint64, oligos []oligo.Oligo, st *DecodeStats, add func(addr uint64, ef bool, dblks []Blk) bool) {
 	// spin up goroutines to decode
 	ch := make(chan oligo.Oligo)
-	f := newFile(c.dseqnum + c.rseqnum, c.c1.BlockNum(), c.c1.BlockSize(), c.rseqnum, c.ec, c.compat, c.rndmz)
-	nprocs := runtime.NumCPU()
+	nprocs := c.procs()
 	for i := 0; i < nprocs; i++ {
 		go func() {
 			blknum := c.c1.BlockNum()
@@ -247,12 +522,21 @@ func (c *Codec) Decode(start, end uint64, oligos []oligo.Oligo) (data []DataExte
 					break
 				}
 
-				addr, ef, data, _, err := c.c1.Decode(ol)
+				addr, ef, data, dist, err := c.c1.DecodeContext(ctx, ol)
 				if err != nil {
+					if ctx.Err() != nil {
+						// canceled, not a failure
+						continue
+					}
+
 //					fmt.Fprintf(os.Stderr, "--- ? ? %v %v\n", ol, err)
+					atomic.AddUint64(&st.Oligos, 1)
+					atomic.AddUint64(&st.Failed, 1)
 					continue
 				}
 
+				conf := c.blkConf(st, ol, dist)
+
 //				fmt.Fprintf(os.Stderr, "--- %d %v %v\n", addr, ef, ol)
 				if addr < start || addr > end {
 					continue
@@ -261,19 +545,30 @@ func (c *Codec) Decode(start, end uint64, oligos []oligo.Oligo) (data []DataExte
 				for i := 0; i < len(dblks); i++ {
 					dblks[i].b = []byte { data[i] }
 					dblks[i].n = 1
+					dblks[i].w = blkWeight(dist)
+					dblks[i].conf = conf
 				}
 
-				f.add(addr - start, ef, dblks)
+				add(addr - start, ef, dblks)
 			}
 		}()
 	}
 
 	// feed the oligos in the order we got them
+feed:
 	for i, ol := range oligos {
 //		fmt.Fprintf(os.Stderr, "sending %v\n", ol)
-		ch <- ol
+		select {
+		case ch <- ol:
+		case <-ctx.Done():
+			break feed
+		}
+
+		if i != 0 && i%10000 == 0 {
+			c.prog.report(ProgressReads, uint64(i), uint64(len(oligos)))
+		}
+
 		if i != 0 && i%100000==0 {
-			fmt.Fprintf(os.Stderr, "*** %v%%\n", float32(i*100)/float32(len(oligos)))
 			if f.sync() {
 				// we got the whole file, no need to continue
 				break
@@ -285,20 +580,24 @@ func (c *Codec) Decode(start, end uint64, oligos []oligo.Oligo) (data []DataExte
 	for i := 0; i < nprocs; i++ {
 		ch <- nil
 	}
-
-	data = f.close()
-	fmt.Fprintf(os.Stderr, "%d extents\n", len(data))
-
-	return
 }
 
 // Same as Decode, but gets an array of L1 entries that were decoded using l1/decode.
 // Return all data that we recovered in data extents
 func (c *Codec) DecodeL1(start, end uint64, entries []*l1.Entry) (data []DataExtent) {
+	data, _, _ = c.DecodeL1Context(context.Background(), start, end, entries)
+	return
+}
+
+// Same as DecodeL1, but stops when the context is canceled (see
+// DecodeContext)
+func (c *Codec) DecodeL1Context(ctx context.Context, start, end uint64, entries []*l1.Entry) (data []DataExtent, fs FileStatus, err error) {
+	var st DecodeStats
+
 	// spin up goroutines to decode
 	ch := make(chan *l1.Entry)
-	f := newFile(c.dseqnum + c.rseqnum, c.c1.BlockNum(), c.c1.BlockSize(), c.rseqnum, c.ec, c.compat, c.rndmz)
-	nprocs := runtime.NumCPU()
+	f := c.newFile(ctx)
+	nprocs := c.procs()
 	for i := 0; i < nprocs; i++ {
 		go func() {
 			blknum := c.c1.BlockNum()
@@ -315,9 +614,20 @@ func (c *Codec) DecodeL1(start, end uint64, entries []*l1.Entry) (data []DataExt
 					continue
 				}
 
+				// the entries from l1/consensus have a confidence
+				// that is used to order the candidate blocks, for
+				// the others use their count and distance
+				conf := c.blkConf(&st, nil, en.Dist)
+				w := en.Conf
+				if w == 0 {
+					w = l1.DefaultVoteParams.Weight(en)
+				}
+
 				for i := 0; i < len(dblks); i++ {
 					dblks[i].b = []byte { en.Data[i] }
 					dblks[i].n = 1
+					dblks[i].w = w
+					dblks[i].conf = conf
 				}
 
 				f.add(en.Addr - start, en.EcFlag, dblks)
@@ -326,11 +636,17 @@ func (c *Codec) DecodeL1(start, end uint64, entries []*l1.Entry) (data []DataExt
 	}
 
 	// feed the oligos in the order we got them
+feed:
 	for i, en := range entries {
 //		fmt.Fprintf(os.Stderr, "sending %v\n", ol)
-		ch <- en
+		select {
+		case ch <- en:
+		case <-ctx.Done():
+			break feed
+		}
+
 		if i != 0 && i%10000==0 {
-			fmt.Fprintf(os.Stderr, "*** %v%%\n", float32(i*100)/float32(len(entries)))
+			c.prog.report(ProgressReads, uint64(i), uint64(len(entries)))
 			if f.sync() {
 				// we got the whole file, no need to continue
 				break
@@ -344,7 +660,10 @@ func (c *Codec) DecodeL1(start, end uint64, entries []*l1.Entry) (data []DataExt
 	}
 
 	data = f.close()
-	fmt.Fprintf(os.Stderr, "%d extents\n", len(data))
+	setStats(data, &st)
+	fs = f.status()
+	c.logf("%d extents", len(data))
+	err = ctx.Err()
 
 	return
 }
@@ -353,12 +672,21 @@ func (c *Codec) DecodeL1(start, end uint64, entries []*l1.Entry) (data []DataExt
 // The oligos array may contain extra oligo sequences that are not used.
 // Return all data that we recovered in data extents
 func (c *Codec) DecodeVerbose(start, end uint64, oligos []oligo.Oligo) (data []DataExtent, recs []DecRecord) {
+	data, recs, _ = c.decodeVerbose(start, end, oligos)
+	return
+}
+
+// Same as DecodeVerbose, also returns the file with the erasure groups
+func (c *Codec) decodeVerbose(start, end uint64, oligos []oligo.Oligo) (data []DataExtent, recs []DecRecord, f *File) {
+	var st DecodeStats
 	var lck sync.Mutex
 
+	gaddrs := uint64(c.groupAddrs())
+
 	// spin up goroutines to decode
 	ch := make(chan oligo.Oligo)
-	f := newFile(c.dseqnum + c.rseqnum, c.c1.BlockNum(), c.c1.BlockSize(), c.rseqnum, c.ec, c.compat, c.rndmz)
-	nprocs := runtime.NumCPU()
+	f = c.newFile(context.Background())
+	nprocs := c.procs()
 	for i := 0; i < nprocs; i++ {
 		go func() {
 			blknum := c.c1.BlockNum()
@@ -370,34 +698,44 @@ func (c *Codec) DecodeVerbose(start, end uint64, oligos []oligo.Oligo) (data []D
 					break
 				}
 
-				var l int
+				var l, dist int
 				var addr uint64
 				var ef bool
 				var data []byte
 				var err error
-				addr, ef, data, _, err = c.c1.Decode(ol)
+				addr, ef, data, dist, err = c.c1.Decode(ol)
+				if err == nil && !ef && addr % gaddrs >= uint64(c.dseqnum) {
+					// no data oligos at the addresses used only by
+					// the erasure oligos of the group
+					err = fmt.Errorf("invalid data address: %d", addr)
+				}
+
 				if err != nil {
+					atomic.AddUint64(&st.Oligos, 1)
+					atomic.AddUint64(&st.Failed, 1)
 					lck.Lock()
-					recs = append(recs, DecRecord{math.MaxUint64, 0, 0, -1, ol, nil, math.MaxInt64, -1})
+					recs = append(recs, DecRecord{math.MaxUint64, 0, 0, -1, ol, nil, math.MaxInt64, -1, -1})
 					lck.Unlock()
 
 					continue
 				}
 
 				oaddr := int64(addr)
-				ecgrp := addr / uint64(c.dseqnum)
-				ecrow := addr % uint64(c.dseqnum)
+				ecgrp := addr / gaddrs
+				ecrow := addr % gaddrs
 				if ef {
 					ecrow += uint64(c.dseqnum)
 					oaddr = -oaddr
 				}
 
 				a := ecgrp * uint64(c.dseqnum + c.rseqnum) + ecrow
-				o := oaddr * int64(c.dblknum * 4)
-				cnum := o / (superSize + superChunkSize)
 				off := int64(-1)
-				if o >= superSize + cnum * (superSize + superChunkSize) {
-					off = o - (cnum + 1) * superSize
+				if !ef {
+					o := int64(ecgrp * uint64(c.dseqnum) + ecrow) * int64(c.dblknum * 4)
+					cnum := o / (superSize + superChunkSize)
+					if o >= superSize + cnum * (superSize + superChunkSize) {
+						off = o - (cnum + 1) * superSize
+					}
 				}
 
 				// FIXME
@@ -407,16 +745,19 @@ func (c *Codec) DecodeVerbose(start, end uint64, oligos []oligo.Oligo) (data []D
 				}
 
 				lck.Lock()
-				recs = append(recs, DecRecord{a, ecgrp, ecrow, l, ol, d, oaddr, off})
+				recs = append(recs, DecRecord{a, ecgrp, ecrow, l, ol, d, oaddr, off, dist})
 				lck.Unlock()
 
 				if addr < start || addr > end {
 					continue
 				}
 
+				conf := c.blkConf(&st, ol, dist)
 				for i := 0; i < len(dblks); i++ {
 					dblks[i].b = d[i]
 					dblks[i].n = 1
+					dblks[i].w = blkWeight(dist)
+					dblks[i].conf = conf
 				}
 
 				f.add(addr, ef, dblks)
@@ -428,6 +769,10 @@ func (c *Codec) DecodeVerbose(start, end uint64, oligos []oligo.Oligo) (data []D
 	for i, ol := range oligos {
 //		fmt.Fprintf(os.Stderr, "sending %v\n", ol)
 		ch <- ol
+		if i != 0 && i%10000 == 0 {
+			c.prog.report(ProgressReads, uint64(i), uint64(len(oligos)))
+		}
+
 		if i != 0 && i%100000==0 {
 			if f.sync() {
 				// we got the whole file, no need to continue
@@ -442,11 +787,12 @@ func (c *Codec) DecodeVerbose(start, end uint64, oligos []oligo.Oligo) (data []D
 	}
 
 	data = f.close()
+	setStats(data, &st)
 //	if c.verbose {
 //		f.dumpECGroups()
 //	}
 
-	fmt.Fprintf(os.Stderr, "%d extents\n", len(data))
+	c.logf("%d extents", len(data))
 
 /*
 	if c.verbose {
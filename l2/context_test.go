@@ -0,0 +1,66 @@
+package l2
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestEncodeContext(t *testing.T) {
+	c := newTestCodec(t)
+	data := testData(c, 1000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ols, err := c.EncodeContext(ctx, 0, data); err != context.Canceled || len(ols) != 0 {
+		t.Fatalf("canceled encode: %d oligos, error %v", len(ols), err)
+	}
+
+	// cancel after the first group
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	c.SetParallel(1)
+	c.SetProgress(func(p Progress) {
+		cancel()
+	})
+
+	_, ols, err := c.EncodeContext(ctx, 0, data)
+	rows := c.dseqnum + c.rseqnum
+	if err != context.Canceled || len(ols) == 0 || len(ols) % rows != 0 {
+		t.Fatalf("encode canceled after a group: %d oligos, error %v", len(ols), err)
+	}
+}
+
+func TestDecodeContext(t *testing.T) {
+	c := newTestCodec(t)
+	data := testData(c, 1000)
+	_, ols, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ngr := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := c.DecodeContext(ctx, 0, c.MaxAddr(), ols); err != context.Canceled {
+		t.Fatalf("canceled decode: error %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	de, _, err := c.DecodeContext(ctx, 0, c.MaxAddr(), ols)
+	if err != nil || len(de) != 1 || !bytes.Equal(de[0].Data, data) {
+		t.Fatalf("decode: %d extents, error %v", len(de), err)
+	}
+
+	// the decoding and recovery goroutines are shut down
+	for i := 0; i < 100 && runtime.NumGoroutine() > ngr; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := runtime.NumGoroutine(); n > ngr {
+		t.Fatalf("%d goroutines left, %d before", n, ngr)
+	}
+}
@@ -0,0 +1,134 @@
+package l2
+
+import (
+	"adscodex/oligo"
+)
+
+// Diagnostics of a decoding run. They show why parts of the data were
+// not recovered: the oligos with no reads (dropouts), with low coverage,
+// or with reads that failed to decode.
+type Diagnostics struct {
+	Reads		int		`json:"reads"`
+	Failed		int		`json:"failed"`		// reads that failed to decode
+	Addrs		[]AddrStats	`json:"addresses"`
+	Groups		[]EcGroupStats	`json:"groups"`
+	Coverage	[]int		`json:"coverage"`	// number of oligos by their read count
+}
+
+// Reads for an oligo address
+type AddrStats struct {
+	Addr		uint64		`json:"addr"`		// L1 address
+	EC		bool		`json:"ec"`		// erasure oligo
+	Group		uint64		`json:"group"`		// erasure group
+	Row		int		`json:"row"`		// row in the erasure group
+	Reads		int		`json:"reads"`		// number of reads decoded to the address
+	BestDist	int		`json:"best_dist"`	// the lowest L1 decoding distance (-1 if no reads)
+	Values		int		`json:"values"`		// number of distinct values decoded
+}
+
+// State of an erasure group after the decoding
+type EcGroupStats struct {
+	Group		uint64		`json:"group"`
+	Rows		int		`json:"rows"`		// rows with at least one read
+	Erasures	int		`json:"erasures"`	// rows without reads
+	Erased		int		`json:"erased"`		// rows with only reads that are too distant (see SetDistThresholds)
+	Multi		int		`json:"multi"`		// rows with more than one value
+	Verified	int		`json:"verified"`	// data rows recovered and verified
+	Unverified	int		`json:"unverified"`	// data rows recovered, but not verified
+	RS		bool		`json:"rs"`		// the erasure code recovered all rows
+}
+
+// Decodes the oligos (see DecodeVerbose) and collects the diagnostics
+// for the addresses from start to end. The addresses and erasure groups
+// without any reads are included, up to the end of the file (if the
+// size of the file is recovered) or the last erasure group with reads.
+// Also returns the integrity of the recovered file (see FileStatus).
+func (c *Codec) Diagnose(start, end uint64, oligos []oligo.Oligo) (data []DataExtent, fs FileStatus, diag *Diagnostics) {
+	data, recs, f := c.decodeVerbose(start, end, oligos)
+	fs = f.status()
+
+	rows := c.dseqnum + c.rseqnum
+	gaddrs := uint64(c.groupAddrs())
+	ngrps := uint64(len(f.egrps))
+	if maxaddr := uint64(f.maxaddr); maxaddr != 0 {
+		ngrps = (maxaddr + gaddrs - 1) / gaddrs
+	}
+
+	sgrp := start / gaddrs
+	if egrp := end / gaddrs + 1; egrp < ngrps {
+		ngrps = egrp
+	}
+
+	diag = &Diagnostics{Reads: len(recs)}
+	if sgrp >= ngrps {
+		return
+	}
+
+	diag.Addrs = make([]AddrStats, (ngrps - sgrp) * uint64(rows))
+	for i := range diag.Addrs {
+		as := &diag.Addrs[i]
+		as.Group = sgrp + uint64(i / rows)
+		as.Row = i % rows
+		as.Addr = as.Group * gaddrs + uint64(as.Row)
+		if as.Row >= c.dseqnum {
+			as.EC = true
+			as.Addr -= uint64(c.dseqnum)
+		}
+		as.BestDist = -1
+	}
+
+	values := make(map[int]map[string]bool)
+	for _, r := range recs {
+		if r.Data == nil {
+			diag.Failed++
+			continue
+		}
+
+		if r.Ecgrp < sgrp || r.Ecgrp >= ngrps {
+			continue
+		}
+
+		i := int(r.Ecgrp - sgrp) * rows + int(r.Ecrow)
+		as := &diag.Addrs[i]
+		as.Reads++
+		if as.BestDist < 0 || r.Dist < as.BestDist {
+			as.BestDist = r.Dist
+		}
+
+		var v []byte
+		for _, d := range r.Data {
+			v = append(v, d...)
+		}
+
+		if values[i] == nil {
+			values[i] = make(map[string]bool)
+		}
+		values[i][string(v)] = true
+	}
+
+	for i, v := range values {
+		diag.Addrs[i].Values = len(v)
+	}
+
+	for _, as := range diag.Addrs {
+		for len(diag.Coverage) <= as.Reads {
+			diag.Coverage = append(diag.Coverage, 0)
+		}
+		diag.Coverage[as.Reads]++
+	}
+
+	for g := sgrp; g < ngrps; g++ {
+		var st EcGroupStats
+
+		if g < uint64(len(f.egrps)) && f.egrps[g] != nil {
+			st = f.egrps[g].stats(c.dseqnum)
+		} else {
+			st.Erasures = rows
+		}
+
+		st.Group = g
+		diag.Groups = append(diag.Groups, st)
+	}
+
+	return
+}
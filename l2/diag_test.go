@@ -0,0 +1,62 @@
+package l2
+
+import (
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	testDiagnose(t, newTestCodec(t), 300)
+}
+
+// more erasure than data oligos, the groups are spaced by the erasure oligos
+func TestDiagnoseGeometry(t *testing.T) {
+	testDiagnose(t, newTestCodecGeom(t, 2, 3), 64)
+}
+
+func testDiagnose(t *testing.T, c *Codec, size int) {
+	data := testData(c, size)
+	_, ols, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// drop the first oligo, and read the second one twice
+	rows := c.dseqnum + c.rseqnum
+	reads := append(ols[1:], ols[1])
+	_, fs, diag := c.Diagnose(0, c.MaxAddr(), reads)
+	if diag.Reads != len(reads) || diag.Failed != 0 || fs.Verdict != VerdictVerified {
+		t.Fatalf("reads %d failed %d verdict %d", diag.Reads, diag.Failed, fs.Verdict)
+	}
+
+	if len(diag.Addrs) != len(ols) || len(diag.Groups) != len(ols) / rows {
+		t.Fatalf("%d addresses %d groups, expected %d %d", len(diag.Addrs), len(diag.Groups), len(ols), len(ols) / rows)
+	}
+
+	for i, as := range diag.Addrs {
+		addr, ef := c.OligoAddress(0, i)
+		if as.Addr != addr || as.EC != ef {
+			t.Fatalf("%d: address %d %v, expected %d %v", i, as.Addr, as.EC, addr, ef)
+		}
+
+		nreads := 1
+		switch i {
+		case 0:
+			nreads = 0
+		case 1:
+			nreads = 2
+		}
+
+		if as.Reads != nreads || (nreads > 0 && (as.BestDist != 0 || as.Values != 1)) {
+			t.Fatalf("%d: %+v", i, as)
+		}
+	}
+
+	if diag.Coverage[0] != 1 || diag.Coverage[1] != len(ols) - 2 || diag.Coverage[2] != 1 {
+		t.Fatalf("coverage %v", diag.Coverage)
+	}
+
+	g := diag.Groups[0]
+	if g.Rows != rows - 1 || g.Erasures != 1 || !g.RS || g.Verified != c.dseqnum {
+		t.Fatalf("group 0: %+v", g)
+	}
+}
@@ -2,7 +2,7 @@ package l2
 
 import (
 	"fmt"
-	"os"
+	"sort"
 	"sync"
 	"github.com/klauspost/reedsolomon"
 )
@@ -10,13 +10,23 @@ import (
 type Blk struct {
 	b	[]byte
 	n	int
+	w	float64		// weight of the block, if 0 the count is used
+	conf	int		// confidence: blkHigh, blkLow, or blkErased
 }
 
+// Block confidence, based on the distance of the oligo it was decoded from
+const (
+	blkHigh = iota		// used for the erasure code recovery
+	blkLow			// used, but the recovered data is not verified
+	blkErased		// not used, only as a best guess
+)
+
 type Blkset []Blk
 
 // Represent a data block from the original encoding
 type EcElem struct {
 	bset	Blkset		// blocks collected so far
+	eset	Blkset		// erased blocks (decoded with too many errors)
 	vdata	Blkset		// verified data
 	uvdata	Blkset		// unverified data
 }
@@ -33,7 +43,7 @@ type EcCol struct {
 type EcGroup struct {
 	sync.Mutex
 	cols	[]EcCol
-	verbose	bool		// for debugging
+	dbg	Logger		// for debugging, logs the changes (nil - disabled)
 }
 
 const (
@@ -49,6 +59,16 @@ func (db Blk) Count() int {
 	return db.n
 }
 
+// Returns the weight of the block (the sum of the confidence of the
+// entries that had it, or the count if the confidence is not known)
+func (db Blk) Weight() float64 {
+	if db.w == 0 {
+		return float64(db.n)
+	}
+
+	return db.w
+}
+
 
 // Checks if db is in the set of blocks
 func (ds Blkset) Find(d []byte) *Blk {
@@ -76,32 +96,50 @@ func (ds Blkset) Find(d []byte) *Blk {
 
 // Add block to the set of blocks
 func (ds Blkset) Add(d []byte) (ret Blkset, added bool) {
+	return ds.AddWeighted(d, 1)
+}
+
+// Same as Add, but the block's weight is increased by w
+func (ds Blkset) AddWeighted(d []byte, w float64) (ret Blkset, added bool) {
 	if b := ds.Find(d); b != nil {
+		b.w = b.Weight() + w
 		b.n++
 		ret = ds
 	} else {
-		ret = append([]Blk(ds), Blk{d, 1})
+		ret = append([]Blk(ds), Blk{d, 1, w, blkHigh})
 		added = true
 	}
 
 	return
 }
 
+// Returns the blocks sorted by their weight, the most probable first
+func (ds Blkset) Sorted() []Blk {
+	ret := append([]Blk(nil), ds...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Weight() > ret[j].Weight()
+	})
+
+	return ret
+}
+
 // Convert the set to slice of blocks
 func (ds Blkset) Blks() []Blk {
 	return []Blk(ds)
 }
 
+// Returns the blocks with the highest weight
 func (ds Blkset) Best() (ret []Blk) {
-	max := 0
+	max := 0.0
 	for idx := range ds {
 		b := &ds[idx]
-		if b.n < max {
+		w := b.Weight()
+		if w < max {
 			continue
 		}
 
-		if b.n > max {
-			max = b.n
+		if w > max {
+			max = w
 			ret = nil
 		}
 
@@ -125,8 +163,8 @@ func newEcGroup(rows, cols int) (eg *EcGroup) {
 // Add data from another oligo to the EC group
 // Returns true if there is a change in the EC group data
 func (eg *EcGroup) addEntry(row int, dblks []Blk, ecnum int, rsenc reedsolomon.Encoder) (ret bool) {
-	if eg.verbose {
-		fmt.Fprintf(os.Stderr, "--- addEntry %d %v\n", row, dblks)
+	if eg.dbg != nil {
+		eg.dbg.Printf("--- addEntry %d %v", row, dblks)
 	}
 
 	eg.Lock()
@@ -154,28 +192,43 @@ func (eg *EcGroup) addBlock(row, col int, db Blk, ecnum int, rsenc reedsolomon.E
 
 	c := eg.cols[col]
 
+	// the erased blocks are only used if there is nothing better
+	if db.conf == blkErased {
+		c.elems[row].eset, _ = c.elems[row].eset.AddWeighted(db.b, db.Weight())
+		return false
+	}
+
 	// check if the block is already present
-	if bs, added := c.elems[row].bset.Add(db.b); !added {
-//		if eg.verbose {
-//			fmt.Fprintf(os.Stderr, "-+- row %d col %d %v %v\n", row, col, db, c.elems[row].bset)
-//		}
+	if b := c.elems[row].bset.Find(db.b); b != nil {
+		b.w = b.Weight() + db.Weight()
+		b.n++
+		if b.conf != blkLow || db.conf != blkHigh {
+//			if eg.dbg != nil {
+//				fmt.Fprintf(os.Stderr, "-+- row %d col %d %v %v\n", row, col, db, c.elems[row].bset)
+//			}
 
-		return false
+			return false
+		}
+
+		// low confidence block confirmed, try the combinations again
+		b.conf = blkHigh
 	} else {
-		c.elems[row].bset = bs
+		c.elems[row].bset = append(c.elems[row].bset, Blk{db.b, 1, db.Weight(), db.conf})
 	}
 
-	if eg.verbose {
-		fmt.Fprintf(os.Stderr, "+++ column %d\n", col)
+	if eg.dbg != nil {
+		eg.dbg.Printf("+++ column %d", col)
 		for i := 0; i < len(c.elems); i++ {
 			eblks := c.elems[i].bset.Blks()
-			fmt.Fprintf(os.Stderr, "\t%v\n", eblks)
+			eg.dbg.Printf("\t%v", eblks)
 		}
 	}
 	// go over all combinations of data blocks
 	shards := make([][]byte, len(c.elems))
 	idx := make([]int, len(c.elems))
 	shards[row] = db.Bytes()		// always use db at the row position
+	weights := make([]float64, len(c.elems))
+	weights[row] = db.Weight()
 	rownum := len(c.elems)
 	dseqnum := rownum - ecnum		// number of data sequences
 	for done := false; !done; {
@@ -183,6 +236,7 @@ func (eg *EcGroup) addBlock(row, col int, db Blk, ecnum int, rsenc reedsolomon.E
 		// collect the data from the current combination
 		nshards := 1	// non-nil shards
 		nec := 0
+		lowconf := db.conf == blkLow
 		if row >= dseqnum {
 			nec++
 		}
@@ -195,15 +249,25 @@ func (eg *EcGroup) addBlock(row, col int, db Blk, ecnum int, rsenc reedsolomon.E
 			eblks := c.elems[i].bset.Blks()
 			if m < len(eblks) {
 				shards[i] = eblks[m].Bytes()
+				weights[i] = eblks[m].Weight()
+				lowconf = lowconf || eblks[m].conf == blkLow
 				nshards++
 				if i >= dseqnum {
 					nec++
 				}
 			} else {
 				shards[i] = nil
+				weights[i] = 0
 			}
 		}
 
+		// the weight of the recovered data is the sum of the weights
+		// of the blocks it is recovered from
+		cw := 0.0
+		for _, w := range weights {
+			cw += w
+		}
+
 		// setup the indices for the next combination
 		for i := 0; i < len(idx); i++ {
 			if i == row {
@@ -232,6 +296,11 @@ func (eg *EcGroup) addBlock(row, col int, db Blk, ecnum int, rsenc reedsolomon.E
 			verified = false
 		}
 
+		if lowconf {
+			// some blocks might be wrong, even if the parity matches
+			verified = false
+		}
+
 /*
 		if nec == 0 {
 			// We don't have any erasure shards, so we can't check if the
@@ -264,8 +333,8 @@ func (eg *EcGroup) addBlock(row, col int, db Blk, ecnum int, rsenc reedsolomon.E
 			}
 		}
 
-		if eg.verbose {
-			fmt.Fprintf(os.Stderr, "=== col %d %v %v %v\n", col, shards, verified, err)
+		if eg.dbg != nil {
+			eg.dbg.Printf("=== col %d %v %v %v", col, shards, verified, err)
 		}
 
 		if err != nil {
@@ -277,16 +346,16 @@ func (eg *EcGroup) addBlock(row, col int, db Blk, ecnum int, rsenc reedsolomon.E
 		for i := 0; i < len(c.elems); i++ {
 			if verified {
 				var added bool
-				c.elems[i].vdata, added = c.elems[i].vdata.Add(shards[i])
-				if added && eg.verbose {
-					fmt.Fprintf(os.Stderr, "\tVVV %d %v\n", i, c.elems[i].vdata)
+				c.elems[i].vdata, added = c.elems[i].vdata.AddWeighted(shards[i], cw)
+				if added && eg.dbg != nil {
+					eg.dbg.Printf("\tVVV %d %v", i, c.elems[i].vdata)
 				}
 			} else {
 				var added bool
-				c.elems[i].uvdata, added = c.elems[i].uvdata.Add(shards[i])
+				c.elems[i].uvdata, added = c.elems[i].uvdata.AddWeighted(shards[i], cw)
 
-				if added && eg.verbose {
-					fmt.Fprintf(os.Stderr, "\tUUU %d %v\n", i, c.elems[i].uvdata)
+				if added && eg.dbg != nil {
+					eg.dbg.Printf("\tUUU %d %v", i, c.elems[i].uvdata)
 				}
 			}
 		}
@@ -308,11 +377,11 @@ func (eg *EcGroup) getVerified(row, col int) (blks []Blk) {
 	cidx := ecGroupReverseColumn(col, row, len(eg.cols))
 	c := eg.cols[cidx]
 	el := &c.elems[row]
-	blks = el.vdata.Blks()
+	blks = el.vdata.Sorted()
 	eg.Unlock()
 
-	if eg.verbose {
-		fmt.Fprintf(os.Stderr, "getVerified %d %d: cidx %d: %v\n", row, col, cidx, blks)
+	if eg.dbg != nil {
+		eg.dbg.Printf("getVerified %d %d: cidx %d: %v", row, col, cidx, blks)
 	}
 	return
 }
@@ -327,11 +396,11 @@ func (eg *EcGroup) getUnverified(row, col int) (blks []Blk) {
 	cidx := ecGroupReverseColumn(col, row, len(eg.cols))
 	c := eg.cols[cidx]
 	el := &c.elems[row]
-	blks = el.uvdata.Blks()
+	blks = el.uvdata.Sorted()
 	eg.Unlock()
 
-	if eg.verbose {
-		fmt.Fprintf(os.Stderr, "getUnverified %d %d: cidx %d: %v\n", row, col, cidx, blks)
+	if eg.dbg != nil {
+		eg.dbg.Printf("getUnverified %d %d: cidx %d: %v", row, col, cidx, blks)
 	}
 	return
 }
@@ -349,10 +418,13 @@ func (eg *EcGroup) getBestGuess(row, col int) (blks []Blk) {
 	c := eg.cols[cidx]
 	el := &c.elems[row]
 	blks = el.bset.Best()
+	if len(blks) == 0 {
+		blks = el.eset.Best()
+	}
 	eg.Unlock()
 
-	if eg.verbose {
-		fmt.Fprintf(os.Stderr, "getBestGuess %d %d: cidx %d: %v\n", row, col, cidx, blks)
+	if eg.dbg != nil {
+		eg.dbg.Printf("getBestGuess %d %d: cidx %d: %v", row, col, cidx, blks)
 	}
 	return
 }
@@ -379,6 +451,53 @@ func (eg *EcGroup) isComplete(verified bool) bool {
 	return true
 }
 
+// Returns the state of the rows of the erasure group for the decode
+// diagnostics (see EcGroupStats), the first drows rows are data rows
+func (eg *EcGroup) stats(drows int) (st EcGroupStats) {
+	eg.Lock()
+	defer eg.Unlock()
+
+	st.RS = true
+	for r := 0; r < len(eg.cols[0].elems); r++ {
+		read, erased, multi, vd, uvd := false, false, false, true, true
+		for _, c := range eg.cols {
+			el := &c.elems[r]
+			read = read || len(el.bset) > 0
+			erased = erased || len(el.eset) > 0
+			multi = multi || len(el.bset) > 1
+			vd = vd && len(el.vdata) > 0
+			uvd = uvd && (len(el.vdata) > 0 || len(el.uvdata) > 0)
+		}
+
+		switch {
+		case read:
+			st.Rows++
+
+		case erased:
+			st.Erased++
+
+		default:
+			st.Erasures++
+		}
+
+		if multi {
+			st.Multi++
+		}
+
+		if r < drows {
+			if vd {
+				st.Verified++
+			} else if uvd {
+				st.Unverified++
+			}
+		}
+
+		st.RS = st.RS && uvd
+	}
+
+	return
+}
+
 // Calculate the column of a block with position p from row r, with up to maxblks per row
 func ecGroupGetColumn(p, r, maxblks int) int {
 	return  (p + r) % maxblks	// combine blocks diagonally (standard for ADS Codex)
@@ -428,7 +547,7 @@ func ecGroupEncode(dblksz, dblknum, dseqnum, eseqnum int, rsenc reedsolomon.Enco
 		for row := 0; row < dseqnum; row++ {
 			pos := ecGroupGetColumn(col, row, dblknum)
 			n := (row * dblknum + pos) * dblksz
-			dblk := data[n:n+4]
+			dblk := data[n:n+dblksz]
 			copy(shards[row], dblk)
 		}
 
@@ -459,7 +578,7 @@ func ecGroupDecode(offset uint64, elems [][][]byte) (dss []DataExtent) {
 	for _, row := range elems {
 		for _, el := range row {
 			if len(d) != 0 && off+uint64(len(d)) != offset {
-				dss = append(dss, DataExtent{ off, d, FileHole })
+				dss = append(dss, DataExtent{ Offset: off, Data: d, Type: FileHole })
 				off = offset
 				d = nil
 			}
@@ -0,0 +1,49 @@
+package l2
+
+import (
+	"bytes"
+	"testing"
+	"github.com/klauspost/reedsolomon"
+)
+
+func TestEcGroupEncode(t *testing.T) {
+	dseqnum, eseqnum, dblknum := 3, 2, 2
+	ec, err := reedsolomon.New(dseqnum, eseqnum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dblksz := range []int{1, 2, 4, 8} {
+		sz := ecGroupDataSize(dblksz, dblknum, dseqnum)
+		data := make([]byte, sz)
+		for i := range data {
+			data[i] = byte(i * 13)
+		}
+
+		// no room after the data, the blocks can't be read past its end
+		dblks, err := ecGroupEncode(dblksz, dblknum, dseqnum, eseqnum, ec, data[:sz:sz])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for r := 0; r < dseqnum; r++ {
+			for c := 0; c < dblknum; c++ {
+				n := (r * dblknum + c) * dblksz
+				if !bytes.Equal(dblks[r][c], data[n:n + dblksz]) {
+					t.Fatalf("block size %d: data block %d:%d doesn't match", dblksz, r, c)
+				}
+			}
+		}
+
+		for col := 0; col < dblknum; col++ {
+			shards := make([][]byte, dseqnum + eseqnum)
+			for r := range shards {
+				shards[r] = dblks[r][ecGroupGetColumn(col, r, dblknum)]
+			}
+
+			if ok, err := ec.Verify(shards); !ok || err != nil {
+				t.Fatalf("block size %d: column %d: erasure blocks don't match: %v", dblksz, col, err)
+			}
+		}
+	}
+}
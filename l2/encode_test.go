@@ -0,0 +1,109 @@
+package l2
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+	"adscodex/oligo/long"
+)
+
+var tblname = flag.String("tbl", "../tbl/32-10.tbl", "table name")
+
+func newTestCodec(tb testing.TB) *Codec {
+	return newTestCodecGeom(tb, 3, 2)
+}
+
+// Same as newTestCodec, with the specified number of data and erasure
+// oligos per erasure group
+func newTestCodecGeom(tb testing.TB, dseqnum, rseqnum int) *Codec {
+	p5, _ := long.FromString("CGACATCTCGATGGCAGCAT")
+	p3, _ := long.FromString("CAGTGAGCTGGCAACTTCCA")
+	c, err := NewCodec(p5, p3, *tblname, dseqnum, rseqnum, 1000)
+	if err != nil {
+		tb.Skipf("can't create codec: %v", err)
+	}
+
+	return c
+}
+
+// Returns random data, limited by the address space of the codec
+func testData(c *Codec, size int) []byte {
+	if max := int(c.MaxAddr() / 2); size > max {
+		size = max
+	}
+
+	data := make([]byte, size)
+	rand.Read(data)
+	return data
+}
+
+func TestEncodeParallel(t *testing.T) {
+	c := newTestCodec(t)
+	data := testData(c, 1<<16)
+
+	c.SetParallel(1)
+	naddr1, ols1, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.SetParallel(8)
+	naddr2, ols2, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if naddr1 != naddr2 || len(ols1) != len(ols2) {
+		t.Fatalf("next address %d %d, oligos %d %d", naddr1, naddr2, len(ols1), len(ols2))
+	}
+
+	for i := range ols1 {
+		if ols1[i].String() != ols2[i].String() {
+			t.Fatalf("oligo %d differs: %v %v", i, ols1[i], ols2[i])
+		}
+
+	}
+}
+
+func TestDecodeGeometry(t *testing.T) {
+	for _, g := range [][2]int{{3, 2}, {2, 3}, {1, 4}} {
+		c := newTestCodecGeom(t, g[0], g[1])
+		data := testData(c, 64)
+		_, ols, err := c.Encode(0, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// lose as many oligos from the first group as there are
+		// erasure oligos
+		ds := c.Decode(0, c.MaxAddr(), ols[g[1]:])
+		if len(ds) != 1 || !bytes.Equal(ds[0].Data, data) {
+			t.Fatalf("%d data %d erasure oligos: %d extents", g[0], g[1], len(ds))
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, nprocs int) {
+	c := newTestCodec(b)
+	c.SetParallel(nprocs)
+	data := testData(c, 1<<20)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := c.Encode(0, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, n := range []int{1, 2, 4, runtime.NumCPU()} {
+		b.Run(fmt.Sprintf("procs%d", n), func(b *testing.B) {
+			benchmarkEncode(b, n)
+		})
+	}
+}
@@ -1,14 +1,16 @@
 package l2
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha1"
-	"fmt"
+	"encoding"
 	"hash/crc64"
 	"math"
 	"math/rand"
-	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 	"adscodex/l0"
 	"github.com/klauspost/reedsolomon"
 )
@@ -18,6 +20,7 @@ type File struct {
 
 	compat	bool   		// if true, use the 0.9 format (no superblocks)
 	rndmz	bool		// if true, the data was randomized with the size of the file as a seed
+	oldrndmz bool		// if true, the data was randomized with math/rand instead of the keystream
 
 	// initial parameters
 	rows	int		// total number of rows
@@ -38,13 +41,25 @@ type File struct {
 	totalsz	uint64		// total size, including the padding and the supers
 	maxaddr	int64		// maximum address (totalsz / (elsz * cols))
 	sha1	[]byte		// SHA1 hash of the whole file
+	seeded	bool		// the chunks use whitening seeds (see SeedSearch)
+	hash	int		// result of the check of the whole file (HashUnknown, etc.)
 	chunks	[]*FileChunk
+	maxiters int		// maximum number of combinations to try to match the chunk SHA1
+	maxtime	time.Duration	// maximum time to try to match the chunk SHA1 (0 - no limit)
+	ctx	context.Context	// stop matching the chunk SHA1 when it is canceled
 	synch	chan bool	// trigger the recovery goroutine to try to recover the file, if false is sent, the goroutine exits
 	closech	chan bool	// sent by the recovery goroutine before it finishes
+
+	log	Logger		// logger for the messages (nil - none)
+	verbose	bool		// log the debugging messages
+	prog	*progress	// progress callback (nil - none)
 }
 
 type FileChunk struct {
 	sha1	[]byte		// SHA1 hash for the chunk (if recovered)
+	seed	uint16		// whitening seed for the chunk (see SeedSearch)
+	seedKnown bool		// the seed was recovered from the chunk's superblock
+	verified bool		// the data matches the SHA1 hash
 	dss	[]DataExtent	// data for the chunk
 }
 
@@ -60,8 +75,7 @@ const (
 const (
 	superSize = 8 + 20 + 8			// superblock: size, sha1, crc64
 	superChunkSize = 512 * 1024		// superblock at every 512k
-	maxRecoveryIterations = 65536		// maximum number of iterations to try to match the chunk SHA1
-	maxRecoveryIterationsForce = /*256**/65536		// maximum number of iterations to try to match the chunk SHA1 when the chunk recovery is forced
+	maxRecoveryIterations = 65536		// default maximum number of combinations to try to match the chunk SHA1
 )
 
 func newFile(egrows, egcols, elsz, ecnum int, rsenc reedsolomon.Encoder, compat bool, rndmz bool) (f *File) {
@@ -81,6 +95,8 @@ func newFile(egrows, egcols, elsz, ecnum int, rsenc reedsolomon.Encoder, compat
 	f.egrpsz = f.drows * f.cols * f.elsz
 	f.ec = rsenc
 
+	f.maxiters = maxRecoveryIterations
+	f.ctx = context.Background()
 	f.synch = make(chan bool)
 	f.closech = make(chan bool)
 	go f.recoverproc()
@@ -88,6 +104,15 @@ func newFile(egrows, egcols, elsz, ecnum int, rsenc reedsolomon.Encoder, compat
 	return
 }
 
+// Sets the budget for matching the chunk SHA1, zero iters keeps the default
+func (f *File) setRecoveryBudget(iters int, maxtime time.Duration) {
+	if iters > 0 {
+		f.maxiters = iters
+	}
+
+	f.maxtime = maxtime
+}
+
 // triggers the recovery goroutine to try to recover more data
 // if all data is recovered already, returns true
 func (f *File) sync() (ret bool) {
@@ -119,12 +144,49 @@ func (f *File) close() (data []DataExtent) {
 		if len(c.dss) != 1 {
 			f.recoverData(i, c, true)
 		}
+	}
+
+	// check the hash of the whole file, before the data is unwhitened
+	f.hash = f.checkHash()
+	if f.hash == HashMismatch && f.recoverFile() {
+		f.hash = HashMatch
+	}
+
+	if f.hash == HashMatch {
+		// all data is verified by the hash
+		for i := 0; i < f.chunkNum(); i++ {
+			f.setVerified(i)
+		}
+	}
 
+	for i, c := range f.chunks {
 		ds := c.dss
 		if ds == nil {
 			continue
 		}
 
+		// undo the whitening, each chunk can use a different seed
+		if f.rndmz && !f.oldrndmz && f.size != 0 {
+			if !f.seedKnown(c) {
+				if f.seeded {
+					// the data can't be unwhitened, leave a hole
+					f.logf("chunk %d: whitening seed not recovered", i)
+					continue
+				}
+
+				// most likely the default seed, but we can't
+				// tell without the file superblock
+				ds = append([]DataExtent(nil), ds...)
+				for j := range ds {
+					ds[j].Type = FileBestGuess | (ds[j].Type & FileMulti)
+				}
+			}
+
+			for _, d := range ds {
+				newKeystreamAt(chunkKey(f.size, c.seed), d.Offset).xor(d.Data)
+			}
+		}
+
 		if data != nil {
 			last := &data[len(data) - 1]
 			if last.Offset + uint64(len(last.Data)) == ds[0].Offset && last.Type == ds[0].Type {
@@ -158,22 +220,22 @@ func (f *File) close() (data []DataExtent) {
 			return nil
 		}
 
-		rnd := rand.New(rand.NewSource(int64(f.size)))
-		rndata := make([]byte, f.size)
-		for i := 0; i < len(rndata); i++ {
-			rndata[i] = byte(rnd.Int31n(256))
-		}
+		if f.oldrndmz {
+			rnd := rand.New(rand.NewSource(int64(f.size)))
+			rndata := make([]byte, f.size)
+			for i := 0; i < len(rndata); i++ {
+				rndata[i] = byte(rnd.Int31n(256))
+			}
 
-		for i := 0; i < len(data); i++ {
-			ds := &data[i]
-			for j := 0; j < len(ds.Data); j++ {
-				ds.Data[j] ^= rndata[ds.Offset + uint64(j)]
+			for i := 0; i < len(data); i++ {
+				ds := &data[i]
+				for j := 0; j < len(ds.Data); j++ {
+					ds.Data[j] ^= rndata[ds.Offset + uint64(j)]
+				}
 			}
 		}
 	}
 
-	// TODO: check the sha1 for the whole file
-
 	return
 }
 
@@ -188,10 +250,14 @@ func (f *File) add(addr uint64, ef bool, dblks []Blk) bool {
 		return false
 	}
 
-	idx := int(addr / uint64(f.drows))
-	row := int(addr % uint64(f.drows))
+	// each erasure group uses mrows addresses, the data and the erasure
+	// oligos have separate address spaces (see Codec.groupAddrs)
+	idx := int(addr / uint64(f.mrows))
+	row := int(addr % uint64(f.mrows))
 	if ef {
 		row += f.drows
+	} else if row >= f.drows {
+		return false
 	}
 
 	if row >= f.rows {
@@ -222,7 +288,7 @@ func (f *File) add(addr uint64, ef bool, dblks []Blk) bool {
 	if eg == nil {
 		eg = newEcGroup(f.rows, f.cols)
 //		if idx == 27 {
-//			eg.verbose = true
+//			eg.dbg = f.log
 //		}
 
 		f.egrps[idx] = eg
@@ -273,7 +339,7 @@ func (f *File) visit(offset uint64, count uint64, v func(addr uint64, size int,
 					ft = FileVerified | FileMulti
 				}
 
-//				if eg != nil && eg.verbose {
+//				if eg != nil && eg.dbg != nil {
 //					fmt.Fprintf(os.Stderr, "!!! row %d col %d %d\n", row, col, len(blks))
 //				}
 
@@ -413,6 +479,12 @@ func (f *File) read(offset, count uint64) (rtype int, data []byte, cnt uint64) {
 
 // Just return the data from one element
 func (f *File) readMulti(offset, count uint64) (rtype int, data [][]byte, cnt uint64) {
+	rtype, data, _, cnt = f.readWeighted(offset, count)
+	return
+}
+
+// Same as readMulti, also returns the weights of the alternatives
+func (f *File) readWeighted(offset, count uint64) (rtype int, data [][]byte, weights []float64, cnt uint64) {
 	f.visit(offset, count, func(addr uint64, sz int, dtype int, blks []Blk) bool {
 		if sz == 0 {
 			return false
@@ -434,6 +506,7 @@ func (f *File) readMulti(offset, count uint64) (rtype int, data [][]byte, cnt ui
 				d := make([]byte, sz)
 				copy(d, b.b[start:end])
 				data = append(data, d)
+				weights = append(weights, b.Weight())
 			}
 		}
 
@@ -443,6 +516,18 @@ func (f *File) readMulti(offset, count uint64) (rtype int, data [][]byte, cnt ui
 	return
 }
 
+// Returns true if the whitening seed of the chunk is known, either from
+// its superblock, or because the file superblock says that the file
+// doesn't use the seeds (all of them are 0). Always true if the data
+// isn't whitened with the keystream.
+func (f *File) seedKnown(c *FileChunk) bool {
+	if !f.rndmz || f.oldrndmz || f.compat {
+		return true
+	}
+
+	return c.seedKnown || (f.sha1 != nil && !f.seeded)
+}
+
 func (f *File) chunkStart(n int) uint64 {
 	if f.compat {
 		return uint64(n) * superChunkSize
@@ -451,21 +536,21 @@ func (f *File) chunkStart(n int) uint64 {
 	}
 }
 
-func (f *File) readSuper(offset uint64) (size uint64, sha1 []byte) {
+func (f *File) readSuper(offset uint64) (size uint64, seed uint16, sha1 []byte) {
 	if f.compat {
-		return 0, nil
+		return 0, 0, nil
 	}
 
 	// Check if there are any holes
 	n := 0
-	fmt.Fprintf(os.Stderr, "readSuper offset %d\n", offset)
+	f.debugf("readSuper offset %d", offset)
 
 	for o := offset; o < offset + superSize; {
 		t, sz := f.check(o, superSize)
 //		fmt.Fprintf(os.Stderr, "readSuper: offset %d: type %x size %d\n", o, t, sz)
 		if sz == 0 || t == FileHole {
-			fmt.Fprintf(os.Stderr, "\tfailed %d sz %d\n", t, sz)
-			return 0, nil
+			f.debugf("\tfailed %d sz %d", t, sz)
+			return 0, 0, nil
 		}
 
 		o += sz
@@ -522,14 +607,16 @@ func (f *File) readSuper(offset uint64) (size uint64, sha1 []byte) {
 
 		// it looks that we got it
 		size, _ = l0.Gint64(data)
+		seed = uint16(size >> superSeedShift)
+		size &= superSizeMask
 		sha1 = data[8:superSize - 8]
-		fmt.Fprintf(os.Stderr, "\tsuccess\n")
+		f.debugf("\tsuccess")
 
 		return
 	}
 
 	// no luck
-	fmt.Fprintf(os.Stderr, "\tfailed\n")
+	f.debugf("\tfailed")
 
 	return
 }
@@ -553,15 +640,52 @@ func (f *File) updateMaxAddr() {
 	atomic.StoreInt64(&f.maxaddr, int64(maxaddr))
 }
 
+// Collects the data and the costs of the alternatives for each part of
+// the range from offset to end. Returns false if there are holes.
+func (f *File) alternatives(offset, end uint64) (dss [][][]byte, costs [][]float64, vmulti, uvmulti int, ok bool) {
+	for o := offset; o < end; {
+		t, sz := f.check(o, end - o)
+		if sz == 0 || t & FileHole != 0 {
+			f.logf("\thole at %d size %d", o, sz)
+			return
+		}
+
+		if t & FileMulti != 0 {
+			var d [][]byte
+			var w []float64
+
+			_, d, w, sz = f.readWeighted(o, end - o)
+			dss = append(dss, d)
+			costs = append(costs, weightCosts(w))
+			if t & FileVerified != 0 {
+				vmulti++
+			} else {
+				uvmulti++
+			}
+		} else {
+			var d []byte
+
+			_, d, sz = f.read(o, end - o)
+			dss = append(dss, [][]byte{d})
+			costs = append(costs, []float64{0})
+		}
+
+		o += sz
+	}
+
+	ok = true
+	return
+}
+
 func (f *File) recoverData(cnum int, c *FileChunk, force bool) (complete bool) {
 	var ds []DataExtent
 	var dss [][][]byte
-	var idx []int
+	var costs [][]float64
 	var data []byte
 	var end uint64
-	var maxidx int
 	var vmulti, uvmulti int		// for statistics only
-	var maxi int
+	var res searchResult
+	var ok bool
 
 	offset := f.chunkStart(cnum)
 	if f.totalsz != 0 && offset > uint64(f.totalsz) {
@@ -574,98 +698,49 @@ func (f *File) recoverData(cnum int, c *FileChunk, force bool) (complete bool) {
 		chunklen = f.size - origOff
 	}
 
-	fmt.Fprintf(os.Stderr, "recover data for chunk %d force %v offset %d %x\n", cnum, force, offset, c.sha1)
+	f.logf("recover data for chunk %d force %v offset %d %x", cnum, force, offset, c.sha1)
 	if c.sha1 == nil || f.compat {
 //		fmt.Fprintf(os.Stderr, "\tnot recovered\n")
 		goto nosha1
 	}
 
 	// at this point we know there is sha1 so we do our best to match it
-	// collect the data
+	// collect the data and the weights of the alternatives for each part
 	end = offset + chunklen
-	for o := offset; o < end; {
-		t, sz := f.check(o, end - o)
-		if sz == 0 || t & FileHole != 0 {
-			fmt.Fprintf(os.Stderr, "\thole at %d size %d\n", o, sz)
-			// if there are holes revert to the same case as if sha1 is nil
-			goto nosha1
-		}
-
-		if t & FileMulti != 0 {
-			var d [][]byte
-
-			_, d, sz = f.readMulti(o, end - o)
-			dss = append(dss, d)
-			if t & FileVerified != 0 {
-				vmulti++
-			} else {
-				uvmulti++
-			}
-		} else {
-			var d []byte
-
-			_, d, sz = f.read(o, end - o)
-			dss = append(dss, [][]byte{d})
-		}
-
-		o += sz
+	dss, costs, vmulti, uvmulti, ok = f.alternatives(offset, end)
+	if !ok {
+		// if there are holes revert to the same case as if sha1 is nil
+		goto nosha1
 	}
 
-	if len(dss) > 32 {	// FIXME: not sure what number to put here
-		fmt.Fprintf(os.Stderr, "\t%d false positives: %d verified %d unverified\n", vmulti+uvmulti, vmulti, uvmulti)
-		maxidx = 2	// FIXME: not sure what number to put here either
+	if vmulti + uvmulti > 0 {
+		f.logf("\t%d false positives: %d verified %d unverified", vmulti+uvmulti, vmulti, uvmulti)
 	}
 
-	idx = make([]int, len(dss))
+	// try the combinations, the most probable first, until one matches the checksum
 	data = make([]byte, end - offset)
-	maxi = maxRecoveryIterations
-	if force {
-		maxi = maxRecoveryIterationsForce
-	}
-
-	for n, done := 0, false; !done && n < maxi; n++ {
-		// collect the data for the current combination
+	res = bestFirstSearch(f.ctx, costs, f.maxiters, f.maxtime, func(idx []int) bool {
 		for i, o := 0, 0; i < len(dss); i++ {
 			o += copy(data[o:], dss[i][idx[i]])
 		}
 
-		// prepare the indices for the next combination
-		for i := 0; i < len(idx); i++ {
-			idx[i]++
-			if (maxidx==0 || idx[i]<maxidx) && idx[i] < len(dss[i]) {
-				break
-			} else {
-				idx[i] = 0
-
-				// check if we exhausted all combinations
-				done = i+1 == len(idx)
-			}
-		}
-
-		// now check if the combination is valid, i.e. the content matches the checksum
-		ash1 := sha1.Sum(data)
-		sh1 := ash1[:]
-		match := true
-		for i, v := range sh1 {
-			if v != c.sha1[i] {
-				match = false
-				break
-			}
-		}
-
-		if !match {
-			continue
-		}
+		sh1 := sha1.Sum(data)
+		return bytes.Equal(sh1[:], c.sha1)
+	})
 
+	if res.found {
 		// we got it
-		c.dss = []DataExtent{ DataExtent{ origOff, data, FileVerified } }
-		fmt.Fprintf(os.Stderr, "\trecovered\n")
+		c.dss = []DataExtent{ DataExtent{ Offset: origOff, Data: data, Type: FileVerified } }
+		c.verified = true
+		f.logf("\trecovered after %d combinations", res.tried)
 		return true
 	}
 
+	f.logf("\tno match: tried %d of %g combinations, %.2f%% of the probability mass", res.tried, res.total, 100*res.mass)
+
 nosha1:
 	if !force {
-		fmt.Fprintf(os.Stderr, "\tfailed\n")
+		f.logf("\tfailed")
 		return false
 	}
 
@@ -689,8 +764,15 @@ nosha1:
 		}
 
 		if t & FileMulti != 0 {
-			_, ds, _ := f.readMulti(offset + o, chunklen - o)
-			d = ds[0]	// just pick the first?
+			_, ds, w, _ := f.readWeighted(offset + o, chunklen - o)
+
+			// the most probable one
+			d = ds[0]
+			for i := range ds {
+				if w[i] > w[0] {
+					d, w[0] = ds[i], w[i]
+				}
+			}
 
 			// if there were multiple values, we can't be sure we are returning the correct one
 			if t & FileVerified != 0 {
@@ -703,7 +785,7 @@ nosha1:
 		// try to combine
 		if (off + uint64(len(data))) != o || vt != t {
 			if len(data) != 0 {
-				ds = append(ds, DataExtent{ origOff + off, data, vt })
+				ds = append(ds, DataExtent{ Offset: origOff + off, Data: data, Type: vt })
 			}
 
 			vt = t
@@ -717,23 +799,140 @@ nosha1:
 	}
 
 	if data != nil {
-		ds = append(ds, DataExtent{ origOff + off, data, vt })	// append the last extent
+		ds = append(ds, DataExtent{ Offset: origOff + off, Data: data, Type: vt })	// append the last extent
 	}
 	c.dss = ds
 
-	fmt.Fprintf(os.Stderr, "\trecovered %d extents\n", len(c.dss))
+	f.logf("\trecovered %d extents", len(c.dss))
 //	for i := 0; i < len(ds); i++ {
 //		fmt.Fprintf(os.Stderr, "\t\t%d %d %v\n", ds[i].Offset, len(ds[i].Data), ds[i].Verified)
 //	}
 	return false
 }
 
+// Called if all chunks are recovered, but the file doesn't match its
+// SHA1 hash. Tries the combinations of the alternatives for the chunks
+// that are not verified by their own hash, until the whole file matches.
+// Returns true if it does.
+func (f *File) recoverFile() bool {
+	var dss [][][]byte
+	var costs [][]float64
+	var chunks []int		// the chunks that are not verified
+	var parts []int			// number of parts with alternatives for each of them
+
+	n := f.chunkNum()
+	for i := 0; i < n; i++ {
+		if f.chunks[i].verified {
+			continue
+		}
+
+		offset := f.chunkStart(i)
+		d, cs, _, _, ok := f.alternatives(offset, offset + f.chunkSize(i))
+		if !ok {
+			return false
+		}
+
+		chunks = append(chunks, i)
+		parts = append(parts, len(d))
+		dss = append(dss, d...)
+		costs = append(costs, cs...)
+	}
+
+	if len(chunks) == 0 {
+		return false
+	}
+
+	f.logf("recover file: %d chunks not verified", len(chunks))
+
+	// the verified chunks before the first one that is not don't change,
+	// hash them only once
+	h := sha1.New()
+	for i := 0; i < chunks[0]; i++ {
+		for _, d := range f.chunks[i].dss {
+			h.Write(d.Data)
+		}
+	}
+
+	prefix, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return false
+	}
+
+	data := make([][]byte, len(chunks))
+	for j, i := range chunks {
+		data[j] = make([]byte, f.chunkSize(i))
+	}
+
+	res := bestFirstSearch(f.ctx, costs, f.maxiters, f.maxtime, func(idx []int) bool {
+		h := sha1.New()
+		h.(encoding.BinaryUnmarshaler).UnmarshalBinary(prefix)
+		for i, j, p := chunks[0], 0, 0; i < n; i++ {
+			if j < len(chunks) && chunks[j] == i {
+				for k, o := 0, 0; k < parts[j]; k++ {
+					o += copy(data[j][o:], dss[p][idx[p]])
+					p++
+				}
+
+				h.Write(data[j])
+				j++
+			} else {
+				for _, d := range f.chunks[i].dss {
+					h.Write(d.Data)
+				}
+			}
+		}
+
+		return bytes.Equal(h.Sum(nil), f.sha1)
+	})
+
+	if !res.found {
+		f.logf("\tno match: tried %d of %g combinations, %.2f%% of the probability mass", res.tried, res.total, 100*res.mass)
+		return false
+	}
+
+	f.logf("\trecovered after %d combinations", res.tried)
+	for j, i := range chunks {
+		f.chunks[i].dss = []DataExtent{ DataExtent{ Offset: uint64(i) * superChunkSize, Data: data[j], Type: FileVerified } }
+	}
+
+	return true
+}
+
+// Marks the data of a complete chunk as verified (by the hash of the
+// whole file), merging its extents into one
+func (f *File) setVerified(n int) {
+	c := f.chunks[n]
+	if len(c.dss) == 1 && c.dss[0].Type == FileVerified {
+		return
+	}
+
+	var data []byte
+	for _, d := range c.dss {
+		data = append(data, d.Data...)
+	}
+
+	c.dss = []DataExtent{ DataExtent{ Offset: uint64(n) * superChunkSize, Data: data, Type: FileVerified } }
+}
+
+// Reports the number of chunks that are verified by their SHA1 hash.
+// The total is known only after the file size is recovered.
+func (f *File) reportChunks() {
+	var n uint64
+	for _, c := range f.chunks {
+		if c.verified {
+			n++
+		}
+	}
+
+	f.prog.report(ProgressChunks, n, uint64(f.chunkNum()))
+}
+
 func (f *File) recoverproc() {
 	done := false
 	for !done {
 		done = !<-f.synch
 
-		fmt.Fprintf(os.Stderr, "starting recover\n")
+		f.debugf("starting recover")
 
 		f.RLock()
 		// calculate number of chunks based on it and resize the chunks array if needed
@@ -758,8 +957,8 @@ func (f *File) recoverproc() {
 		if !f.compat {
 			// try to recover the file header and footer, if not recovered already
 			if f.sha1 == nil {
-				fmt.Fprintf(os.Stderr, "try to recover file header\n")
-				sz, sha1 := f.readSuper(0)
+				f.debugf("try to recover file header")
+				sz, flags, sha1 := f.readSuper(0)
 				if sha1 == nil {
 					tsz := f.totalsz
 					if tsz == 0 {
@@ -768,14 +967,15 @@ func (f *File) recoverproc() {
 					}
 
 					if tsz != 0 {
-						fmt.Fprintf(os.Stderr, "try to recover file footer\n")
-						sz, sha1 = f.readSuper(tsz - superSize)
+						f.debugf("try to recover file footer")
+						sz, flags, sha1 = f.readSuper(tsz - superSize)
 					}
 				}
 
 				if sha1 != nil {
-					fmt.Fprintf(os.Stderr, "got file information\n")
+					f.debugf("got file information")
 					f.sha1 = sha1
+					f.seeded = f.seeded || flags & superSeeded != 0
 					if f.size == 0 {
 						f.size = sz
 						f.updateMaxAddr()
@@ -797,7 +997,7 @@ func (f *File) recoverproc() {
 		}
 
 		// try to recover superblocks for each chunk that doesn't have it recovered
-		fmt.Fprintf(os.Stderr, "%d chunks totalsz %d\n", len(f.chunks), f.totalsz)
+		f.debugf("%d chunks totalsz %d", len(f.chunks), f.totalsz)
 		for i, c := range f.chunks {
 			last := false
 			if c.sha1 != nil {
@@ -811,10 +1011,13 @@ func (f *File) recoverproc() {
 				last = true
 			}
 
-			fmt.Fprintf(os.Stderr, "try to recover information for chunk %d\n", i)
-			sz, sha1 := f.readSuper(offset)
+			f.debugf("try to recover information for chunk %d", i)
+			sz, seed, sha1 := f.readSuper(offset)
 			if sha1 != nil {
 				c.sha1 = sha1
+				c.seed = seed
+				c.seedKnown = true
+				f.seeded = f.seeded || seed != 0
 				if f.size == 0 {
 					f.size = sz
 					f.updateMaxAddr()
@@ -836,6 +1039,8 @@ func (f *File) recoverproc() {
 			}
 		}
 
+		f.reportChunks()
+
 		if cmpl {
 			f.Lock()
 			f.complete = true
@@ -0,0 +1,94 @@
+package l2
+
+import (
+	"sync"
+)
+
+// Logging and progress reporting
+//
+// The codec doesn't write to stdout or stderr. The messages about the
+// encoding and the recovery go to the logger set with SetLogger (none by
+// default), the debugging messages are logged only if the codec is
+// verbose. The progress of the encoding and decoding is reported to the
+// callback set with SetProgress.
+
+// Receives the messages from the codec, *log.Logger implements it.
+// It is called from multiple goroutines.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+const (
+	// kinds of progress events
+	ProgressReads = iota	// oligos (or L1 entries) processed by the decoder
+	ProgressGroups		// erasure groups encoded
+	ProgressChunks		// chunks verified by their SHA1 hash while decoding
+)
+
+// Progress event
+type Progress struct {
+	Kind	int		// ProgressReads, ProgressGroups, or ProgressChunks
+	Done	uint64		// number of items processed
+	Total	uint64		// total number of items (0 - not known yet)
+}
+
+// progress reporting shared by the codec and its files, the callback is
+// never called concurrently
+type progress struct {
+	sync.Mutex
+	fn	func(p Progress)
+}
+
+// Sets the logger for the messages from the codec, nil disables them
+func (c *Codec) SetLogger(l Logger) {
+	c.log = l
+}
+
+// Sets the callback for the progress events, nil disables them.
+// The callback is called synchronously, it should return quickly.
+func (c *Codec) SetProgress(fn func(p Progress)) {
+	c.prog = &progress{fn: fn}
+}
+
+func (c *Codec) logf(format string, v ...interface{}) {
+	if c.log != nil {
+		c.log.Printf(format, v...)
+	}
+}
+
+func (p *progress) report(kind int, done, total uint64) {
+	if p == nil || p.fn == nil {
+		return
+	}
+
+	p.Lock()
+	p.fn(Progress{kind, done, total})
+	p.Unlock()
+}
+
+// Increments the done counter and reports it. The counter is updated
+// under the same lock as the callback, so the reported counts never go
+// backwards.
+func (p *progress) step(kind int, done *uint64, total uint64) {
+	if p == nil || p.fn == nil {
+		return
+	}
+
+	p.Lock()
+	*done++
+	p.fn(Progress{kind, *done, total})
+	p.Unlock()
+}
+
+func (f *File) logf(format string, v ...interface{}) {
+	if f.log != nil {
+		f.log.Printf(format, v...)
+	}
+}
+
+// Logs only if the codec is verbose
+func (f *File) debugf(format string, v ...interface{}) {
+	if f.verbose {
+		f.logf(format, v...)
+	}
+}
@@ -0,0 +1,59 @@
+package l2
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testLogger struct {
+	bytes.Buffer
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	fmt.Fprintf(&l.Buffer, format + "\n", v...)
+}
+
+func TestProgress(t *testing.T) {
+	c := newTestCodec(t)
+	data := testData(c, 1000)
+
+	var log testLogger
+	var evs []Progress
+	c.SetParallel(4)
+	c.SetLogger(&log)
+	c.SetProgress(func(p Progress) {
+		evs = append(evs, p)
+	})
+
+	_, ols, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	egnum := uint64(len(ols) / (c.dseqnum + c.rseqnum))
+	if n := len(evs); n != int(egnum) || evs[n-1] != (Progress{ProgressGroups, egnum, egnum}) {
+		t.Fatalf("%d groups, events %v", egnum, evs)
+	}
+
+	for i, p := range evs {
+		if p.Done != uint64(i + 1) {
+			t.Fatalf("event %d: %v", i, p)
+		}
+	}
+
+	evs = nil
+	de := c.Decode(0, c.MaxAddr(), ols)
+	if len(de) != 1 || !bytes.Equal(de[0].Data, data) {
+		t.Fatalf("decoded %d extents", len(de))
+	}
+
+	if n := len(evs); n == 0 || evs[n-1] != (Progress{ProgressChunks, 1, 1}) {
+		t.Fatalf("events %v", evs)
+	}
+
+	if !strings.Contains(log.String(), "1 extents") {
+		t.Fatalf("log:\n%s", log.String())
+	}
+}
@@ -0,0 +1,130 @@
+package l2
+
+import (
+	"sort"
+)
+
+// Level 0 table for the capacity planning
+type PlanTable struct {
+	Name		string		// table file name (informational only)
+	OligoLen	int		// length of the oligo without the primers
+	MaxVal		uint64		// number of values in the table (see l0.Codec.MaxVal)
+}
+
+// Codec configuration for the capacity planning
+type PlanParams struct {
+	PlanTable
+	Size		uint64		// input size in bytes
+	P5Len		int		// length of the 5'-end primer
+	P3Len		int		// length of the 3'-end primer
+	Dseqnum		int		// number of data oligos per erasure group
+	Rseqnum		int		// number of erasure oligos per erasure group
+	NtPrice		float64		// synthesis price per nt (0 - no cost estimate)
+}
+
+// Capacity and cost of a codec configuration
+type Plan struct {
+	PlanParams
+	MaxAddr		uint64		// number of L1 addresses
+	MaxSize		uint64		// maximum file size that fits in the addresses
+	Fits		bool		// the input fits in the addresses
+	Groups		uint64		// number of erasure groups
+	Oligos		uint64		// number of oligos, data and erasure
+	Nts		uint64		// total number of nts, including the primers
+	Overhead	uint64		// bytes of superblocks and padding in the data oligos
+	Redundancy	float64		// share of the erasure oligos in an erasure group
+	BitsPerNt	float64		// input bits per nt
+	Cost		float64		// synthesis cost (Nts * NtPrice)
+}
+
+// Returns the number of addresses for the table. Level 1 keeps a byte of
+// the value for the data, and a bit for the erasure flag (see l1.Codec).
+func planMaxAddr(maxval uint64) uint64 {
+	return maxval / 256 / 2
+}
+
+// Returns the size of the data as it is stored in the erasure groups with
+// egsz bytes of data each, with the superblocks and the padding (see
+// layout)
+func planStoredSize(size uint64, egsz uint64) uint64 {
+	n := (size + superChunkSize - 1) / superChunkSize
+	sz := (n + 2) * superSize + size
+	return (sz + egsz - 1) / egsz * egsz
+}
+
+// Calculates the capacity and cost of the configuration. Each oligo holds
+// a byte of data (see l1.Codec.DataLen).
+func NewPlan(p PlanParams) (pl Plan) {
+	pl.PlanParams = p
+	pl.MaxAddr = planMaxAddr(p.MaxVal)
+	if p.Dseqnum <= 0 || p.Rseqnum < 0 {
+		return
+	}
+
+	egsz := uint64(p.Dseqnum)
+	gaddrs := uint64(p.Dseqnum)
+	if p.Rseqnum > p.Dseqnum {
+		gaddrs = uint64(p.Rseqnum)
+	}
+
+	// the biggest file that fits in the erasure groups we can address
+	maxgrps := pl.MaxAddr / gaddrs
+	maxsz := maxgrps * egsz
+	pl.MaxSize = uint64(sort.Search(int(maxsz), func(n int) bool {
+		return planStoredSize(uint64(n) + 1, egsz) > maxsz
+	}))
+
+	pl.Fits = p.Size != 0 && p.Size <= pl.MaxSize
+	stored := planStoredSize(p.Size, egsz)
+	pl.Groups = stored / egsz
+	pl.Oligos = pl.Groups * uint64(p.Dseqnum + p.Rseqnum)
+	pl.Nts = pl.Oligos * uint64(p.OligoLen + p.P5Len + p.P3Len)
+	pl.Overhead = stored - p.Size
+	pl.Redundancy = float64(p.Rseqnum) / float64(p.Dseqnum + p.Rseqnum)
+	if pl.Nts != 0 {
+		pl.BitsPerNt = float64(p.Size * 8) / float64(pl.Nts)
+	}
+
+	pl.Cost = float64(pl.Nts) * p.NtPrice
+	return
+}
+
+// Searches for the configurations with at least the target redundancy.
+// For each table and number of data oligos per erasure group up to maxd,
+// the one with the fewest erasure oligos is tried. Returns the ones the
+// input fits in, the fewest nts first. The geometry in p is ignored.
+func SearchPlans(p PlanParams, tables []PlanTable, target float64, maxd int) (plans []Plan) {
+	if target >= 1 {
+		return
+	}
+
+	for _, t := range tables {
+		p.PlanTable = t
+		for d := 1; d <= maxd; d++ {
+			r := 1
+			for float64(r) / float64(d + r) < target {
+				r++
+			}
+
+			// the Reed-Solomon encoder supports up to 256 shards
+			if d + r > 256 {
+				break
+			}
+
+			p.Dseqnum, p.Rseqnum = d, r
+			if pl := NewPlan(p); pl.Fits {
+				plans = append(plans, pl)
+			}
+		}
+	}
+
+	sort.SliceStable(plans, func(i, j int) bool {
+		if plans[i].Nts != plans[j].Nts {
+			return plans[i].Nts < plans[j].Nts
+		}
+
+		return plans[i].Redundancy > plans[j].Redundancy
+	})
+
+	return
+}
@@ -0,0 +1,63 @@
+package l2
+
+import (
+	"testing"
+	"adscodex/l0"
+)
+
+func TestPlan(t *testing.T) {
+	c := newTestCodec(t)
+	olen, maxval, err := l0.ReadTableHeader(*tblname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := PlanParams{PlanTable: PlanTable{OligoLen: olen, MaxVal: maxval}, P5Len: c.p5.Len(), P3Len: c.p3.Len(), Dseqnum: c.dseqnum, Rseqnum: c.rseqnum, NtPrice: 0.1}
+	pl := NewPlan(p)
+	if pl.MaxAddr != c.MaxAddr() {
+		t.Fatalf("max address %d expected %d", pl.MaxAddr, c.MaxAddr())
+	}
+
+	// the plan matches what the codec encodes
+	for _, size := range []uint64{1, 100, pl.MaxSize} {
+		p.Size = size
+		pl := NewPlan(p)
+		_, ols, err := c.Encode(0, make([]byte, size))
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+
+		if !pl.Fits || pl.Oligos != uint64(len(ols)) || pl.Nts != pl.Oligos * uint64(ols[0].Len()) || pl.Cost != float64(pl.Nts) * 0.1 {
+			t.Fatalf("size %d: %d oligos: %+v", size, len(ols), pl)
+		}
+	}
+
+	// one more byte doesn't fit
+	if _, _, err := c.Encode(0, make([]byte, pl.MaxSize + 1)); err == nil {
+		t.Fatalf("size %d shouldn't fit", pl.MaxSize + 1)
+	}
+}
+
+func TestSearchPlans(t *testing.T) {
+	tables := []PlanTable{{"small", 12, 1 << 20}, {"big", 16, 1 << 24}}
+	p := PlanParams{Size: 4000, P5Len: 20, P3Len: 20}
+	plans := SearchPlans(p, tables, 0.3, 10)
+	if len(plans) == 0 {
+		t.Fatalf("no plans")
+	}
+
+	for i, pl := range plans {
+		if !pl.Fits || pl.Redundancy < 0.3 || pl.Dseqnum > 10 {
+			t.Fatalf("plan %+v", pl)
+		}
+
+		if i > 0 && pl.Nts < plans[i-1].Nts {
+			t.Fatalf("plans not sorted: %d after %d nts", pl.Nts, plans[i-1].Nts)
+		}
+
+		// the fewest erasure oligos for the redundancy
+		if r := pl.Rseqnum - 1; r > 0 && float64(r) / float64(pl.Dseqnum + r) >= 0.3 {
+			t.Fatalf("plan %+v has too many erasure oligos", pl)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package l2
+
+import (
+	"context"
+	"sync"
+	"adscodex/oligo"
+)
+
+// Sorts the reads for DecodeRange by the erasure group of their address.
+// The reads for the groups with the range, the file superblock, and the
+// superblock after the last chunk of the range are added to the file.
+// If the range is in the last chunk of the file, its superblock is
+// somewhere between the range and the end of the chunk, we know where
+// only after the file size is recovered, so the reads for these groups
+// are kept until then.
+type rangeFilter struct {
+	sync.Mutex
+	c	*Codec
+	f	*File
+	gaddrs	uint64		// number of addresses per erasure group
+	super	uint64		// last group of the file superblock
+	first	uint64		// first group of the range
+	last	uint64		// last group of the range
+	chunk	uint64		// last chunk of the range
+	sfirst	uint64		// first group of the superblock after a full chunk
+	slast	uint64		// last group of the superblock after a full chunk
+	pending	[]rangeRead	// reads for the groups from last to sfirst
+}
+
+type rangeRead struct {
+	addr	uint64
+	ef	bool
+	dblks	[]Blk
+}
+
+// Returns the position of the file offset in the data as it is stored in
+// the erasure groups, after the file superblock and the superblocks of
+// the chunks before it (see addSupers)
+func (c *Codec) storedOffset(offset uint64) uint64 {
+	if c.compat {
+		return offset
+	}
+
+	n := offset / superChunkSize
+	return superSize + n * (superSize + superChunkSize) + offset % superChunkSize
+}
+
+// Returns the index of the erasure group with the stored position
+func (c *Codec) storedGroup(pos uint64) uint64 {
+	return pos / uint64(ecGroupDataSize(c.c1.BlockSize(), c.c1.BlockNum(), c.dseqnum))
+}
+
+// Returns the first and the last L1 address of the erasure groups that
+// hold the file bytes from offset to offset + length (length > 0), if
+// the file was encoded starting at address start. The superblocks needed
+// to decode them are not included (see DecodeRange).
+func (c *Codec) RangeAddr(start, offset, length uint64) (saddr, eaddr uint64) {
+	gaddrs := uint64(c.groupAddrs())
+	saddr = start + c.storedGroup(c.storedOffset(offset)) * gaddrs
+	eaddr = start + (c.storedGroup(c.storedOffset(offset + length - 1)) + 1) * gaddrs - 1
+	return
+}
+
+// Decodes only the file bytes from offset to offset + length. The reads
+// with addresses outside of the erasure groups that hold them, and the
+// superblocks needed to unwhiten them, are not used. Returns the extents
+// of the range that were recovered, the gaps are holes.
+// The data in a range is verified by the SHA1 of its chunk only if the
+// range covers the whole chunk, otherwise it is verified by the erasure
+// codes only.
+func (c *Codec) DecodeRange(start, offset, length uint64, oligos []oligo.Oligo) (data []DataExtent) {
+	data, _, _ = c.DecodeRangeContext(context.Background(), start, offset, length, oligos)
+	return
+}
+
+// Same as DecodeRange, but stops when the context is canceled (see
+// DecodeContext)
+func (c *Codec) DecodeRangeContext(ctx context.Context, start, offset, length uint64, oligos []oligo.Oligo) (data []DataExtent, fs FileStatus, err error) {
+	var st DecodeStats
+
+	if length == 0 {
+		return
+	}
+
+	f := c.newFile(ctx)
+	r := c.newRangeFilter(f, offset, length)
+	c.decodeOligos(ctx, f, start, start + (r.slast + 1) * r.gaddrs - 1, oligos, &st, r.add)
+	r.flush()
+
+	data = clipExtents(f.close(), offset, offset + length)
+	setStats(data, &st)
+	fs = f.status()
+	c.logf("%d extents in range %d:%d", len(data), offset, offset + length)
+	err = ctx.Err()
+
+	return
+}
+
+func (c *Codec) newRangeFilter(f *File, offset, length uint64) (r *rangeFilter) {
+	r = new(rangeFilter)
+	r.c = c
+	r.f = f
+	r.gaddrs = uint64(c.groupAddrs())
+	r.super = c.storedGroup(superSize - 1)
+	r.first = c.storedGroup(c.storedOffset(offset))
+	r.last = c.storedGroup(c.storedOffset(offset + length - 1))
+	r.chunk = (offset + length - 1) / superChunkSize
+
+	// the superblock after the last chunk, if it is full
+	pos := c.storedOffset(r.chunk * superChunkSize) + superChunkSize
+	r.sfirst = c.storedGroup(pos)
+	r.slast = c.storedGroup(pos + superSize - 1)
+	return
+}
+
+// Adds the blocks to the file if they are in the groups we need, keeps
+// them if they may be
+func (r *rangeFilter) add(addr uint64, ef bool, dblks []Blk) bool {
+	g := addr / r.gaddrs
+	switch {
+	case g <= r.super, g >= r.first && g <= r.last, g >= r.sfirst && g <= r.slast:
+		return r.f.add(addr, ef, dblks)
+
+	case g > r.last && g < r.sfirst:
+		r.Lock()
+		r.pending = append(r.pending, rangeRead{addr, ef, append([]Blk(nil), dblks...)})
+		r.Unlock()
+	}
+
+	return false
+}
+
+// Adds the kept reads for the groups with the superblock of the last
+// chunk of the file, if the range is in it. If the file size can't be
+// recovered, we don't know where the superblock is, and all of them are
+// added.
+func (r *rangeFilter) flush() {
+	lo, hi := r.last + 1, r.sfirst
+	size, _, sha1 := r.f.readSuper(0)
+	if sha1 != nil {
+		if size <= r.chunk * superChunkSize || size >= (r.chunk + 1) * superChunkSize {
+			// not the last chunk, its superblock is after a full chunk
+			return
+		}
+
+		pos := r.c.storedOffset(size)
+		lo, hi = r.c.storedGroup(pos), r.c.storedGroup(pos + superSize - 1)
+	}
+
+	for _, rd := range r.pending {
+		if g := rd.addr / r.gaddrs; g >= lo && g <= hi {
+			r.f.add(rd.addr, rd.ef, rd.dblks)
+		}
+	}
+
+	r.pending = nil
+}
+
+// Returns the parts of the extents from offset to end
+func clipExtents(data []DataExtent, offset, end uint64) (ds []DataExtent) {
+	for _, d := range data {
+		s, e := d.Offset, d.Offset + uint64(len(d.Data))
+		if e <= offset || s >= end {
+			continue
+		}
+
+		if s < offset {
+			s = offset
+		}
+
+		if e > end {
+			e = end
+		}
+
+		ds = append(ds, DataExtent{s, d.Data[s - d.Offset:e - d.Offset], d.Type, d.Stats})
+	}
+
+	return
+}
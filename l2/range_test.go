@@ -0,0 +1,100 @@
+package l2
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"adscodex/oligo"
+)
+
+func TestRangeAddr(t *testing.T) {
+	c := newTestCodec(t)
+	egsz := uint64(ecGroupDataSize(c.c1.BlockSize(), c.c1.BlockNum(), c.dseqnum))
+	gaddrs := uint64(c.groupAddrs())
+	for _, tc := range []struct {
+		offset, length uint64
+	} {
+		{ 0, 1 },
+		{ 100, 50 },
+		{ superChunkSize - 10, 20 },
+		{ superChunkSize, 1 },
+	} {
+		saddr, eaddr := c.RangeAddr(1000, tc.offset, tc.length)
+
+		// the bytes of the second chunk are after two superblocks
+		spos, epos := superSize + tc.offset, superSize + tc.offset + tc.length - 1
+		if tc.offset >= superChunkSize {
+			spos += superSize
+		}
+
+		if tc.offset + tc.length > superChunkSize {
+			epos += superSize
+		}
+
+		if s := 1000 + spos / egsz * gaddrs; saddr != s {
+			t.Errorf("%d:%d: start address %d expected %d", tc.offset, tc.length, saddr, s)
+		}
+
+		if e := 1000 + (epos / egsz + 1) * gaddrs - 1; eaddr != e {
+			t.Errorf("%d:%d: end address %d expected %d", tc.offset, tc.length, eaddr, e)
+		}
+	}
+}
+
+func TestDecodeRange(t *testing.T) {
+	c := newTestCodec(t)
+	ss := DefaultSeedSearch
+	ss.Seeds = 4
+	c.SetRandomize(true)
+	c.SetSeedSearch(&ss)
+
+	// testData is limited by the addresses of the table
+	data := testData(c, 600)
+	if len(data) < 100 {
+		t.Skipf("data too small for a range: %d", len(data))
+	}
+
+	_, ols, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, st := decodeStatus(c, ols)
+	seed := st.Chunks[0].Seed
+
+	// only keep the reads for the range, the file superblock, and the
+	// superblock after the data, the rest are not needed
+	offset, length := uint64(len(data) / 2), uint64(len(data) / 10)
+	saddr, eaddr := c.RangeAddr(0, offset, length)
+	_, haddr := c.RangeAddr(0, 0, 1)
+	ssaddr, seaddr := c.RangeAddr(0, uint64(len(data)), superSize)
+	var reads []oligo.Oligo
+	for i, ol := range ols {
+		addr, _ := c.OligoAddress(0, i)
+		if addr <= haddr || (addr >= saddr && addr <= eaddr) || (addr >= ssaddr && addr <= seaddr) {
+			reads = append(reads, ol)
+		}
+	}
+
+	for _, rs := range [][]oligo.Oligo{ols, reads} {
+		ds, st, _ := c.DecodeRangeContext(context.Background(), 0, offset, length, rs)
+		if len(ds) != 1 || ds[0].Offset != offset || !bytes.Equal(ds[0].Data, data[offset:offset+length]) {
+			t.Fatalf("range doesn't match: %d extents", len(ds))
+		}
+
+		// the chunk is not complete, so the SHA1 can't be checked
+		if ds[0].Type != FileVerified || st.Size != uint64(len(data)) || st.Verdict != VerdictIncomplete {
+			t.Fatalf("type %d status %+v", ds[0].Type, st)
+		}
+
+		if cs := st.Chunks[0]; cs.SHA1 == nil || cs.Seed != seed {
+			t.Fatalf("chunk superblock not recovered: %+v expected seed %d", cs, seed)
+		}
+	}
+
+	// the whole file is verified by the chunk SHA1
+	ds, st, _ := c.DecodeRangeContext(context.Background(), 0, 0, uint64(len(data)), ols)
+	if len(ds) != 1 || !bytes.Equal(ds[0].Data, data) || st.Verdict != VerdictVerified {
+		t.Fatalf("whole file range doesn't match: %d extents", len(ds))
+	}
+}
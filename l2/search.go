@@ -0,0 +1,192 @@
+package l2
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+// Best-first search over the combinations of alternatives for a sequence
+// of segments. Each alternative has a cost (negative log-likelihood), the
+// combinations are visited in the order of their total cost (sum of the
+// costs of the chosen alternatives), i.e. the most probable first.
+//
+// The segments with more than one alternative are ordered by the cost
+// difference between their best and second best alternative. Each
+// combination is represented as a state with the position p of the last
+// segment that doesn't use its best alternative. The successors of a
+// state are:
+//	1. the next alternative at p
+//	2. the second alternative at p+1
+//	3. if p uses its second alternative, the best alternative at p and
+//	   the second at p+1
+// Each combination has exactly one predecessor, and the successors
+// don't cost less than the state, so the combinations are visited
+// exactly once, in order.
+
+// Result of the search
+type searchResult struct {
+	found	bool		// the visit function accepted a combination
+	tried	int		// number of combinations visited
+	total	float64		// total number of combinations
+	mass	float64		// probability mass of the visited combinations (0 to 1)
+}
+
+// Alternative chosen for a segment, the list is shared between the states
+type searchAlt struct {
+	seg	int
+	alt	int
+	next	*searchAlt
+}
+
+type searchState struct {
+	cost	float64
+	seg	int		// index in segs
+	alt	int		// alternative used by seg
+	prev	*searchAlt	// alternatives chosen for the segments before seg
+}
+
+type searchHeap []*searchState
+
+func (h searchHeap) Len() int { return len(h) }
+func (h searchHeap) Less(i, j int) bool { return h[i].cost < h[j].cost }
+func (h searchHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *searchHeap) Push(x interface{}) { *h = append(*h, x.(*searchState)) }
+func (h *searchHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	s := old[n - 1]
+	*h = old[0:n - 1]
+	return s
+}
+
+// Converts the weights of the alternatives of a segment to costs
+// (negative log of the normalized weight)
+func weightCosts(weights []float64) []float64 {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+
+	costs := make([]float64, len(weights))
+	for i, w := range weights {
+		if w <= 0 || total <= 0 {
+			costs[i] = -math.Log(1/float64(len(weights)))
+		} else {
+			costs[i] = -math.Log(w / total)
+		}
+	}
+
+	return costs
+}
+
+// Visits the combinations of alternatives in the order of increasing cost,
+// until visit returns true, maxiter combinations are visited, the time
+// runs out (maxtime 0 - no limit), or the context is canceled. The idx
+// array passed to visit contains the chosen alternative for each segment
+// (it is reused between the calls). The costs for each segment don't
+// need to be sorted.
+func bestFirstSearch(ctx context.Context, costs [][]float64, maxiter int, maxtime time.Duration, visit func(idx []int) bool) (res searchResult) {
+	// sort the alternatives of each segment by cost
+	order := make([][]int, len(costs))
+	var segs []int		// segments with more than one alternative
+	res.total = 1
+	for i, c := range costs {
+		o := make([]int, len(c))
+		for j := range o {
+			o[j] = j
+		}
+
+		sort.SliceStable(o, func(a, b int) bool {
+			return c[o[a]] < c[o[b]]
+		})
+
+		order[i] = o
+		res.total *= float64(len(c))
+		if len(c) > 1 {
+			segs = append(segs, i)
+		}
+	}
+
+	cost := func(seg, alt int) float64 {
+		s := segs[seg]
+		return costs[s][order[s][alt]]
+	}
+
+	sort.SliceStable(segs, func(a, b int) bool {
+		ca, cb := costs[segs[a]], costs[segs[b]]
+		oa, ob := order[segs[a]], order[segs[b]]
+		return ca[oa[1]] - ca[oa[0]] < cb[ob[1]] - cb[ob[0]]
+	})
+
+	idx := make([]int, len(costs))
+	base := 0.0
+	for i, c := range costs {
+		if len(c) > 0 {
+			base += c[order[i][0]]
+		}
+	}
+
+	var start time.Time
+	if maxtime > 0 {
+		start = time.Now()
+	}
+
+	try := func(st *searchState) bool {
+		for i := range idx {
+			idx[i] = order[i][0]
+		}
+
+		if st != nil {
+			s := segs[st.seg]
+			idx[s] = order[s][st.alt]
+			for a := st.prev; a != nil; a = a.next {
+				s = segs[a.seg]
+				idx[s] = order[s][a.alt]
+			}
+		}
+
+		res.tried++
+		if st != nil {
+			res.mass += math.Exp(-st.cost)
+		} else {
+			res.mass += math.Exp(-base)
+		}
+
+		return visit(idx)
+	}
+
+	if res.found = try(nil); res.found || len(segs) == 0 {
+		return
+	}
+
+	h := &searchHeap{}
+	heap.Push(h, &searchState{base + cost(0, 1) - cost(0, 0), 0, 1, nil})
+	for h.Len() > 0 && res.tried < maxiter {
+		if res.tried % 256 == 0 && ((maxtime > 0 && time.Since(start) > maxtime) || ctx.Err() != nil) {
+			break
+		}
+
+		st := heap.Pop(h).(*searchState)
+		if res.found = try(st); res.found {
+			return
+		}
+
+		p := st.seg
+		if st.alt + 1 < len(costs[segs[p]]) {
+			heap.Push(h, &searchState{st.cost + cost(p, st.alt + 1) - cost(p, st.alt), p, st.alt + 1, st.prev})
+		}
+
+		if p + 1 < len(segs) {
+			d := cost(p + 1, 1) - cost(p + 1, 0)
+			heap.Push(h, &searchState{st.cost + d, p + 1, 1, &searchAlt{p, st.alt, st.prev}})
+			if st.alt == 1 {
+				heap.Push(h, &searchState{st.cost + d - (cost(p, 1) - cost(p, 0)), p + 1, 1, st.prev})
+			}
+		}
+	}
+
+	return
+}
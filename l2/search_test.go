@@ -0,0 +1,65 @@
+package l2
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestBestFirstSearch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n < 100; n++ {
+		costs := make([][]float64, 1 + rnd.Intn(8))
+		total := 1
+		for i := range costs {
+			costs[i] = make([]float64, 1 + rnd.Intn(4))
+			for j := range costs[i] {
+				costs[i][j] = rnd.Float64() * 5
+			}
+
+			total *= len(costs[i])
+		}
+
+		seen := make(map[string]bool)
+		prev := math.Inf(-1)
+		res := bestFirstSearch(context.Background(), costs, total + 1, 0, func(idx []int) bool {
+			s := fmt.Sprintf("%v", idx)
+			if seen[s] {
+				t.Fatalf("combination %s visited twice", s)
+			}
+			seen[s] = true
+
+			c := 0.0
+			for i, j := range idx {
+				c += costs[i][j]
+			}
+
+			if c < prev - 1e-9 {
+				t.Fatalf("combination %s cost %v less than the previous %v", s, c, prev)
+			}
+			prev = c
+			return false
+		})
+
+		if res.found || res.tried != total || len(seen) != total || int(res.total) != total {
+			t.Fatalf("tried %d (%d unique) of %d (%v) combinations", res.tried, len(seen), total, res.total)
+		}
+	}
+
+	// the search stops when the combination is found, or the budget runs out
+	costs := [][]float64{ weightCosts([]float64{1, 3}), {0}, weightCosts([]float64{2, 1, 1}) }
+	res := bestFirstSearch(context.Background(), costs, 100, 0, func(idx []int) bool {
+		return idx[0] == 1 && idx[2] == 0
+	})
+
+	if !res.found || res.tried != 1 || math.Abs(res.mass - 0.375) > 1e-9 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	res = bestFirstSearch(context.Background(), costs, 3, 0, func(idx []int) bool { return false })
+	if res.found || res.tried != 3 {
+		t.Fatalf("budget not respected: %+v", res)
+	}
+}
@@ -0,0 +1,231 @@
+package l2
+
+import (
+	"context"
+	"sync"
+	"adscodex/oligo"
+)
+
+// Whitening seed search
+//
+// When the data is randomized, each super chunk can be whitened with a
+// different seed. The encoder tries several seeds for each chunk and
+// keeps the one that produces the fewest bad data oligos. An oligo is
+// bad if its GC content is outside the allowed range, if it is a near
+// duplicate of another oligo from the chunk, or if it contains a
+// subsequence of the primers (or their reverse complements). Only the
+// part of the oligos between the primers is checked.
+//
+// The seed is stored in the upper bits of the size field of the chunk's
+// superblock (the one after the chunk, that contains its SHA1). Seed 0
+// is the default whitening (the keystream seeded with the file size),
+// so the files encoded without the search are unchanged. If any of the
+// chunks uses a different seed, the same bits of the file superblock
+// have the superSeeded flag set, so the decoder knows that it can't
+// assume seed 0 for a chunk whose superblock is lost.
+const (
+	superSeedShift = 48
+	superSizeMask = (1 << superSeedShift) - 1
+	superSeeded = 0x1
+	MaxSeeds = 1 << (64 - superSeedShift)
+)
+
+// Parameters of the seed search
+type SeedSearch struct {
+	Seeds	int		// number of seeds to try for each chunk (0 or 1 - no search)
+	MinGC	float64		// minimum GC content of an oligo
+	MaxGC	float64		// maximum GC content of an oligo
+	DupDist	int		// oligos within this distance are near duplicates
+	PrimerK	int		// length of the primer subsequences that are not allowed (0 - not checked)
+}
+
+var DefaultSeedSearch = SeedSearch{Seeds: 16, MinGC: 0.4, MaxGC: 0.6, DupDist: 1, PrimerK: 8}
+
+// Returns the keystream seed for a chunk whitened with the specified seed
+func chunkKey(size uint64, seed uint16) uint64 {
+	return size ^ (uint64(seed) << superSeedShift)
+}
+
+// Returns the number of bad oligos for the chunk data at offset off (in
+// the file) whitened with the specified seed. The first byte of the
+// chunk is encoded in the oligo at position pos in the encoded data.
+func (c *Codec) seedScore(ss *SeedSearch, addr uint64, pos int, data []byte, size, off uint64, seed uint16, kmers map[string]bool) (bad int, err error) {
+	egsz := ecGroupDataSize(c.c1.BlockSize(), c.c1.BlockNum(), c.dseqnum)
+	ecgrpaddr := c.dseqnum
+	if ecgrpaddr < c.rseqnum {
+		ecgrpaddr = c.rseqnum
+	}
+
+	d := append([]byte(nil), data...)
+	newKeystreamAt(chunkKey(size, seed), off).xor(d)
+
+	ols := make([]string, len(d))
+	isbad := make([]bool, len(d))
+	for i := range d {
+		// FIXME: we know that the block size and number are 1
+		p := pos + i
+		a := addr + uint64((p / egsz) * ecgrpaddr + p % egsz)
+		ol, e := c.c1.EncodePayload(a, false, d[i:i+1])
+		if e != nil {
+			return 0, e
+		}
+
+		s := ol.String()
+		ols[i] = s
+		if gc := oligo.GCcontent(ol); gc < ss.MinGC || gc > ss.MaxGC {
+			isbad[i] = true
+		}
+
+		for k := 0; !isbad[i] && ss.PrimerK > 0 && k + ss.PrimerK <= len(s); k++ {
+			isbad[i] = kmers[s[k:k + ss.PrimerK]]
+		}
+	}
+
+	// any two oligos within DupDist share at least one of DupDist+1
+	// parts, only the oligos with a common part are compared
+	if ss.DupDist > 0 && len(ols) > 0 {
+		parts := ss.DupDist + 1
+		olen := len(ols[0])
+		for p := 0; p < parts; p++ {
+			start, end := p * olen / parts, (p + 1) * olen / parts
+			buckets := make(map[string][]int)
+			for i, s := range ols {
+				buckets[s[start:end]] = append(buckets[s[start:end]], i)
+			}
+
+			for _, b := range buckets {
+				for i := 0; i < len(b); i++ {
+					for j := i + 1; j < len(b); j++ {
+						if isbad[b[i]] && isbad[b[j]] {
+							continue
+						}
+
+						if stringDistance(ols[b[i]], ols[b[j]]) <= ss.DupDist {
+							isbad[b[i]] = true
+							isbad[b[j]] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for _, b := range isbad {
+		if b {
+			bad++
+		}
+	}
+
+	return
+}
+
+// Edit distance between two strings
+func stringDistance(a, b string) int {
+	prev := make([]int, len(b) + 1)
+	cur := make([]int, len(b) + 1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			d := prev[j - 1]
+			if a[i - 1] != b[j - 1] {
+				d++
+			}
+
+			if prev[j] + 1 < d {
+				d = prev[j] + 1
+			}
+
+			if cur[j - 1] + 1 < d {
+				d = cur[j - 1] + 1
+			}
+
+			cur[j] = d
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+// Returns the subsequences of length k of the primers and their reverse
+// complements
+func (c *Codec) primerKmers(k int) map[string]bool {
+	kmers := make(map[string]bool)
+	if k <= 0 {
+		return kmers
+	}
+
+	for _, p := range []oligo.Oligo{c.p5, c.p3} {
+		if p == nil {
+			continue
+		}
+
+		rc := p.Clone()
+		oligo.Reverse(rc)
+		oligo.Invert(rc)
+		for _, s := range []string{p.String(), rc.String()} {
+			for i := 0; i + k <= len(s); i++ {
+				kmers[s[i:i + k]] = true
+			}
+		}
+	}
+
+	return kmers
+}
+
+// Finds the seed with the fewest bad oligos for the chunk. The seeds are
+// tried in parallel, on ties the smaller seed wins. Stops with the
+// context's error if it is canceled.
+func (c *Codec) findSeed(ctx context.Context, ss *SeedSearch, addr uint64, pos int, data []byte, size, off uint64, kmers map[string]bool) (seed uint16, err error) {
+	nseeds := ss.Seeds
+	if nseeds > MaxSeeds {
+		nseeds = MaxSeeds
+	}
+
+	scores := make([]int, nseeds)
+	errs := make([]error, nseeds)
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < c.procs(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range ch {
+				scores[s], errs[s] = c.seedScore(ss, addr, pos, data, size, off, uint16(s), kmers)
+			}
+		}()
+	}
+
+feed:
+	for s := 0; s < nseeds; s++ {
+		select {
+		case ch <- s:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	for s := range scores {
+		if errs[s] != nil {
+			return 0, errs[s]
+		}
+
+		if scores[s] < scores[seed] {
+			seed = uint16(s)
+		}
+	}
+
+	c.logf("chunk at %d: seed %d, %d bad oligos (%d with the default seed)", off, seed, scores[seed], scores[0])
+	return
+}
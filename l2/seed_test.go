@@ -0,0 +1,102 @@
+package l2
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"adscodex/oligo"
+)
+
+func TestStringDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b	string
+		d	int
+	} {
+		{ "ACGT", "ACGT", 0 },
+		{ "ACGT", "AGGT", 1 },
+		{ "ACGT", "CGT", 1 },
+		{ "ACGT", "TGCA", 4 },
+		{ "", "ACG", 3 },
+	} {
+		if d := stringDistance(tc.a, tc.b); d != tc.d {
+			t.Errorf("%s %s: expected %d got %d", tc.a, tc.b, tc.d, d)
+		}
+	}
+}
+
+func TestSeedSearch(t *testing.T) {
+	c := newTestCodec(t)
+	ss := DefaultSeedSearch
+	ss.Seeds = 4
+	c.SetRandomize(true)
+	c.SetSeedSearch(&ss)
+
+	data := testData(c, 600)
+	_, ols, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rand.Shuffle(len(ols), func(i, j int) { ols[i], ols[j] = ols[j], ols[i] })
+	ds := c.Decode(0, c.MaxAddr(), ols)
+	if len(ds) != 1 || !bytes.Equal(ds[0].Data, data) {
+		t.Fatalf("data doesn't match: %d extents", len(ds))
+	}
+}
+
+// Decodes the data without the reads for the superblock after it,
+// the superblock with the chunk's whitening seed
+func decodeWithoutSuper(c *Codec, data []byte, ols []oligo.Oligo) (ds []DataExtent, st FileStatus) {
+	saddr, eaddr := c.RangeAddr(0, uint64(len(data)), superSize)
+	var reads []oligo.Oligo
+	for i, ol := range ols {
+		if addr, _ := c.OligoAddress(0, i); addr < saddr || addr > eaddr {
+			reads = append(reads, ol)
+		}
+	}
+
+	return decodeStatus(c, reads)
+}
+
+func TestSeedLost(t *testing.T) {
+	c := newTestCodec(t)
+	ss := DefaultSeedSearch
+	ss.Seeds = 4
+	c.SetRandomize(true)
+	c.SetSeedSearch(&ss)
+
+	// find data that is whitened with a seed other than 0
+	var data []byte
+	var ols []oligo.Oligo
+	for i := 0; i < 20 && ols == nil; i++ {
+		data = testData(c, 256)
+		_, ols, _ = c.Encode(0, data)
+		if _, st := decodeStatus(c, ols); st.Chunks[0].Seed == 0 {
+			ols = nil
+		}
+	}
+
+	if ols == nil {
+		t.Skipf("no data with a non-zero seed")
+	}
+
+	// the seed is lost with the superblock, the chunk is a hole
+	ds, st := decodeWithoutSuper(c, data, ols)
+	if len(ds) != 0 || st.Verdict != VerdictIncomplete || st.Chunks[0].SeedKnown {
+		t.Fatalf("%d extents, status %+v", len(ds), st)
+	}
+
+	// without the seed search, the file superblock says the seed is 0
+	c.SetSeedSearch(nil)
+	_, ols, _ = c.Encode(0, data)
+	ds, st = decodeWithoutSuper(c, data, ols)
+	if len(ds) == 0 || !st.Chunks[0].SeedKnown {
+		t.Fatalf("%d extents, status %+v", len(ds), st)
+	}
+
+	for _, d := range ds {
+		if !bytes.Equal(d.Data, data[d.Offset:d.Offset + uint64(len(d.Data))]) {
+			t.Fatalf("extent %d:%d doesn't match", d.Offset, len(d.Data))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package l2
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+)
+
+// Returns the oligo with the payload changed to s
+func testPayload(c *Codec, s string) oligo.Oligo {
+	ol := c.p5.Clone()
+	ol.Append(long.FromString1(s))
+	ol.Append(c.p3)
+	return ol
+}
+
+// Returns an oligo that is at distance 1 from the codeword of ol and
+// from another one, or nil if there is no codeword close enough
+func ambiguousOligo(c *Codec, ol oligo.Oligo) oligo.Oligo {
+	p := []byte(ol.Slice(c.p5.Len(), ol.Len() - c.p3.Len()).String())
+	for i := 0; i < len(p); i++ {
+		for j := i + 1; j < len(p); j++ {
+			for _, a := range "ACGT" {
+				for _, b := range "ACGT" {
+					if byte(a) == p[i] || byte(b) == p[j] {
+						continue
+					}
+
+					q := append([]byte(nil), p...)
+					q[i], q[j] = byte(a), byte(b)
+					if _, _, _, dist, err := c.c1.Decode(testPayload(c, string(q))); err != nil || dist != 0 {
+						continue
+					}
+
+					// half way between the two codewords
+					q[j] = p[j]
+					return testPayload(c, string(q))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestDecodeStats(t *testing.T) {
+	c := newTestCodec(t)
+	data := testData(c, 300)
+	_, ols, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var amb oligo.Oligo
+	for _, ol := range ols {
+		if amb = ambiguousOligo(c, ol); amb != nil {
+			break
+		}
+	}
+
+	if amb == nil {
+		t.Skipf("no codewords at distance 2 in the table")
+	}
+
+	reads := append([]oligo.Oligo{amb}, ols...)
+	for _, ambig := range []bool{false, true} {
+		c.SetAmbiguousErasures(ambig)
+		ds := c.Decode(0, c.MaxAddr(), reads)
+		if len(ds) != 1 || !bytes.Equal(ds[0].Data, data) {
+			t.Fatalf("ambiguous %v: data doesn't match", ambig)
+		}
+
+		st := ds[0].Stats
+		if st.Oligos != uint64(len(reads)) || st.Failed != 0 || (st.Ambiguous == 1) != ambig {
+			t.Fatalf("ambiguous %v: stats %+v", ambig, st)
+		}
+	}
+}
+
+func TestDecodeStatsConcurrent(t *testing.T) {
+	c := newTestCodec(t)
+	data := testData(c, 300)
+	_, ols, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the decodes on the same codec count their own oligos
+	var wg sync.WaitGroup
+	stats := make([]DecodeStats, 4)
+	for i := range stats {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ds := c.Decode(0, c.MaxAddr(), ols[0:len(ols) - i])
+			if len(ds) > 0 {
+				stats[i] = ds[0].Stats
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, st := range stats {
+		if st.Oligos != uint64(len(ols) - i) {
+			t.Errorf("decode %d: %d oligos expected %d", i, st.Oligos, len(ols) - i)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package l2
+
+import (
+	"bytes"
+	"crypto/sha1"
+)
+
+const (
+	// results of checking the data against its SHA1 hash
+	HashUnknown = iota	// the hash wasn't recovered, the data can't be checked
+	HashMatch		// the data matches the hash
+	HashMismatch		// the data doesn't match the hash
+	HashIncomplete		// the hash was recovered, but not all data was
+)
+
+const (
+	// verdicts about the integrity of the whole file
+	VerdictIncomplete = iota	// not all data was recovered
+	VerdictUnverified		// all data was recovered, but not all of it is verified
+	VerdictVerified			// all data was recovered and verified by the SHA1 hashes
+)
+
+// Integrity of the file recovered by a decode (see DecodeContext)
+type FileStatus struct {
+	Verdict	int		// VerdictIncomplete, VerdictUnverified, or VerdictVerified
+	Size	uint64		// file size (0 - not recovered)
+	SHA1	[]byte		// SHA1 hash of the whole file from the superblocks (nil - not recovered)
+	Hash	int		// result of the check of the whole file (HashUnknown, etc.)
+	Chunks	[]ChunkStatus
+}
+
+// Integrity of a chunk of the file (see superChunkSize)
+type ChunkStatus struct {
+	Offset	uint64		// offset of the chunk in the file
+	Size	uint64		// size of the chunk
+	SHA1	[]byte		// SHA1 hash of the chunk from its superblock (nil - not recovered)
+	Seed	uint16		// whitening seed of the chunk (see SeedSearch)
+	SeedKnown bool		// the seed was recovered, if not, the chunk is a hole or a best guess
+	Hash	int		// result of the check of the chunk (HashUnknown, etc.)
+}
+
+// Returns the number of chunks in the file, 0 if the size is not known
+func (f *File) chunkNum() int {
+	return int((f.size + superChunkSize - 1) / superChunkSize)
+}
+
+// Returns the size of the chunk n
+func (f *File) chunkSize(n int) uint64 {
+	off := uint64(n) * superChunkSize
+	if f.size != 0 && off + superChunkSize > f.size {
+		return f.size - off
+	}
+
+	return superChunkSize
+}
+
+// Returns true if the extents of the chunk cover all its data
+func (f *File) chunkComplete(n int) bool {
+	if n >= len(f.chunks) {
+		return false
+	}
+
+	start := uint64(n) * superChunkSize
+	off := start
+	for _, d := range f.chunks[n].dss {
+		if d.Offset != off {
+			return false
+		}
+
+		off += uint64(len(d.Data))
+	}
+
+	return off == start + f.chunkSize(n)
+}
+
+// Checks the SHA1 hash of the whole file. The hash is of the whitened
+// data, so it has to be called before the data is unwhitened.
+func (f *File) checkHash() int {
+	if f.compat || f.sha1 == nil || f.size == 0 {
+		return HashUnknown
+	}
+
+	h := sha1.New()
+	for i := 0; i < f.chunkNum(); i++ {
+		if !f.chunkComplete(i) {
+			return HashIncomplete
+		}
+
+		for _, d := range f.chunks[i].dss {
+			h.Write(d.Data)
+		}
+	}
+
+	if bytes.Equal(h.Sum(nil), f.sha1) {
+		return HashMatch
+	}
+
+	return HashMismatch
+}
+
+// Returns the integrity of the file, the hash is checked by close
+func (f *File) status() (st FileStatus) {
+	st.Size = f.size
+	st.SHA1 = f.sha1
+	st.Hash = f.hash
+	st.Verdict = f.verdict()
+
+	n := f.chunkNum()
+	if n == 0 {
+		// the size is not known, report the chunks we tried
+		n = len(f.chunks)
+	}
+
+	for i := 0; i < n; i++ {
+		cs := ChunkStatus{Offset: uint64(i) * superChunkSize, Size: f.chunkSize(i), Hash: HashUnknown}
+		if i < len(f.chunks) {
+			c := f.chunks[i]
+			cs.SHA1 = c.sha1
+			cs.Seed = c.seed
+			cs.SeedKnown = f.seedKnown(c)
+			switch {
+			case c.sha1 == nil || f.compat:
+				cs.Hash = HashUnknown
+
+			case c.verified:
+				cs.Hash = HashMatch
+
+			case f.chunkComplete(i):
+				cs.Hash = HashMismatch
+
+			default:
+				cs.Hash = HashIncomplete
+			}
+		}
+
+		st.Chunks = append(st.Chunks, cs)
+	}
+
+	return
+}
+
+// The file is verified if it matches its hash, or if the hash wasn't
+// recovered, but all chunks match theirs. The hashes are of the whitened
+// data, the chunks with unknown whitening seeds can't be verified (see
+// close).
+func (f *File) verdict() int {
+	n := f.chunkNum()
+	if n == 0 {
+		return VerdictIncomplete
+	}
+
+	verified, seeds := true, true
+	for i := 0; i < n; i++ {
+		if !f.chunkComplete(i) {
+			return VerdictIncomplete
+		}
+
+		if !f.seedKnown(f.chunks[i]) {
+			if f.seeded {
+				// the chunk is a hole
+				return VerdictIncomplete
+			}
+
+			seeds = false
+		}
+
+		verified = verified && f.chunks[i].verified
+	}
+
+	if !seeds {
+		return VerdictUnverified
+	}
+
+	if f.hash == HashMatch || (f.hash == HashUnknown && verified) {
+		return VerdictVerified
+	}
+
+	return VerdictUnverified
+}
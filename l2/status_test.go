@@ -0,0 +1,147 @@
+package l2
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"adscodex/oligo"
+)
+
+// Decodes the oligos and returns the extents with the file status
+func decodeStatus(c *Codec, ols []oligo.Oligo) ([]DataExtent, FileStatus) {
+	ds, st, _ := c.DecodeContext(context.Background(), 0, c.MaxAddr(), ols)
+	return ds, st
+}
+
+func TestFileStatus(t *testing.T) {
+	c := newTestCodec(t)
+	data := testData(c, 1000)
+	_, ols, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, st := decodeStatus(c, ols)
+	if st.Size != uint64(len(data)) || st.Hash != HashMatch || st.Verdict != VerdictVerified || len(st.Chunks) != 1 {
+		t.Fatalf("status %+v", st)
+	}
+
+	if cs := st.Chunks[0]; cs.Offset != 0 || cs.Size != uint64(len(data)) || cs.Hash != HashMatch {
+		t.Fatalf("chunk %+v", cs)
+	}
+
+	// drop a whole erasure group in the middle
+	rows := c.dseqnum + c.rseqnum
+	g := len(ols) / rows / 2
+	reads := append(append([]oligo.Oligo{}, ols[0:g*rows]...), ols[(g+1)*rows:]...)
+	_, st = decodeStatus(c, reads)
+	if st.Hash != HashIncomplete || st.Verdict != VerdictIncomplete || st.Chunks[0].Hash != HashIncomplete {
+		t.Fatalf("status without group %d: %+v", g, st)
+	}
+}
+
+// decodes running at the same time get the status of their own file
+func TestFileStatusConcurrent(t *testing.T) {
+	c := newTestCodec(t)
+	data := testData(c, 1000)
+	_, ols, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := c.dseqnum + c.rseqnum
+	g := len(ols) / rows / 2
+	reads := append(append([]oligo.Oligo{}, ols[0:g*rows]...), ols[(g+1)*rows:]...)
+
+	var wg sync.WaitGroup
+	verdicts := make([]int, 8)
+	for i := range verdicts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rs := ols
+			if i % 2 == 1 {
+				rs = reads
+			}
+
+			_, st := decodeStatus(c, rs)
+			verdicts[i] = st.Verdict
+		}(i)
+	}
+
+	wg.Wait()
+	for i, v := range verdicts {
+		if (i % 2 == 0 && v != VerdictVerified) || (i % 2 == 1 && v != VerdictIncomplete) {
+			t.Fatalf("decode %d: verdict %d", i, v)
+		}
+	}
+}
+
+func TestRecoverFile(t *testing.T) {
+	c := newTestCodec(t)
+	data := testData(c, 500)
+	_, ols, err := c.Encode(0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the data is after the file superblock, and the chunk superblock
+	// after the data, each oligo has one byte
+	rows := c.dseqnum + c.rseqnum
+	dstart := superSize
+	send := superSize + len(data) + superSize
+
+	// drop the groups with the chunk superblock, it can't be recovered
+	// from the rest of it
+	var reads []oligo.Oligo
+	for i, ol := range ols {
+		if g := i / rows; g * c.dseqnum <= dstart + len(data) || g * c.dseqnum >= send {
+			reads = append(reads, ol)
+		}
+	}
+
+	// drop the erasure oligos of a group in the data, and read a wrong
+	// value for one of its data oligos twice, before the right one, so
+	// it is the most probable
+	g := (dstart + len(data) / 2) / c.dseqnum
+	var nreads []oligo.Oligo
+	for _, ol := range reads {
+		addr, ef, _, _, err := c.c1.Decode(ol)
+		if err == nil && ef && int(addr) / c.dseqnum == g {
+			continue
+		}
+
+		nreads = append(nreads, ol)
+	}
+
+	addr, _, d, _, err := c.c1.Decode(ols[g * rows])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong, err := c.c1.Encode(addr, false, []byte{d[0] ^ 0xff})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nreads = append([]oligo.Oligo{wrong, wrong}, nreads...)
+
+	var log testLogger
+	c.SetLogger(&log)
+	c.SetParallel(1)
+	de, st := decodeStatus(c, nreads)
+	if len(de) != 1 || !bytes.Equal(de[0].Data, data) {
+		t.Fatalf("data doesn't match, %d extents, status %+v", len(de), st)
+	}
+
+	if st.Hash != HashMatch || st.Verdict != VerdictVerified || st.Chunks[0].Hash != HashUnknown {
+		t.Fatalf("status %+v", st)
+	}
+
+	// the most probable data doesn't match, the alternatives were tried
+	if !strings.Contains(log.String(), "recover file") {
+		t.Fatalf("file not recovered from the alternatives:\n%s", log.String())
+	}
+}
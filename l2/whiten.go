@@ -0,0 +1,83 @@
+package l2
+
+import (
+	"encoding/binary"
+)
+
+// Keystream used to whiten (randomize) the data and to generate the
+// padding. It doesn't depend on the math/rand implementation, so the
+// encoding of the same data with the same parameters is always the same.
+//
+// The generator is SplitMix64 (Steele, Lea, Flood, "Fast Splittable
+// Pseudorandom Number Generators", 2014). The state starts with the
+// seed, before each output it is incremented by 0x9e3779b97f4a7c15, and
+// the output is
+//	z = (state ^ (state >> 30)) * 0xbf58476d1ce4e5b9
+//	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+//	z ^ (z >> 31)
+// Each output provides 8 bytes of the stream, least significant first.
+// The byte at offset n is from the output n/8, so the stream can be
+// started at any offset without generating the bytes before it.
+//
+// The stream for a file is seeded with the size of the file, which is
+// stored in the superblocks. The padding of the last erasure group is
+// the continuation of the stream after the end of the file.
+type keystream struct {
+	state	uint64
+	buf	[8]byte
+	pos	int		// position in buf, 8 if empty
+}
+
+const splitmixGamma = 0x9e3779b97f4a7c15
+
+func newKeystream(seed uint64) *keystream {
+	return &keystream{state: seed, pos: 8}
+}
+
+// Creates a keystream positioned at the specified offset
+func newKeystreamAt(seed, offset uint64) *keystream {
+	ks := newKeystream(seed + (offset / 8) * splitmixGamma)
+	if offset % 8 != 0 {
+		ks.fill()
+		ks.pos = int(offset % 8)
+	}
+
+	return ks
+}
+
+func (ks *keystream) next64() uint64 {
+	ks.state += splitmixGamma
+	z := ks.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (ks *keystream) fill() {
+	binary.LittleEndian.PutUint64(ks.buf[:], ks.next64())
+	ks.pos = 0
+}
+
+// Returns the next byte of the stream
+func (ks *keystream) next() byte {
+	if ks.pos == len(ks.buf) {
+		ks.fill()
+	}
+
+	ks.pos++
+	return ks.buf[ks.pos - 1]
+}
+
+// XORs the data with the stream
+func (ks *keystream) xor(data []byte) {
+	for i := range data {
+		data[i] ^= ks.next()
+	}
+}
+
+// Fills the data with the stream
+func (ks *keystream) read(data []byte) {
+	for i := range data {
+		data[i] = ks.next()
+	}
+}
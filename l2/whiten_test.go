@@ -0,0 +1,91 @@
+package l2
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"testing"
+	"github.com/klauspost/reedsolomon"
+)
+
+func TestKeystream(t *testing.T) {
+	// reference values for SplitMix64 with seed 0
+	ks := newKeystream(0)
+	for _, v := range []uint64{0xe220a8397b1dcdaf, 0x6e789e6aa1b965f4, 0x06c45d188009454f} {
+		if n := ks.next64(); n != v {
+			t.Fatalf("expected %016x got %016x", v, n)
+		}
+	}
+
+	stream := make([]byte, 100)
+	newKeystream(42).read(stream)
+	for off := 0; off < len(stream); off++ {
+		b := make([]byte, len(stream) - off)
+		newKeystreamAt(42, uint64(off)).read(b)
+		if string(b) != string(stream[off:]) {
+			t.Fatalf("offset %d: stream differs", off)
+		}
+	}
+}
+
+// Pins the data stored in the erasure groups for a given input, any
+// change in the whitening, the padding, or the superblocks changes the
+// encoded oligos
+func TestLayoutGolden(t *testing.T) {
+	golden := []struct {
+		rndmz	bool
+		size	int
+		sha1	string
+	} {
+		{ false, 1000, "b0774f9373fa17f554fd06e8cead7eadbe61e153" },
+		{ true, 1000, "8ac52bf03ac500179c2d040c9ffc15f4e855fa96" },
+		{ true, 600000, "f4205f6af35a28906d296c96df67856fa9d810c1" },
+	}
+
+	for _, g := range golden {
+		ec, err := reedsolomon.New(3, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := &Codec{dseqnum: 3, rseqnum: 2, rndmz: g.rndmz, ec: ec}
+		data := make([]byte, g.size)
+		for i := range data {
+			data[i] = byte(i * 7)
+		}
+
+		egsz := ecGroupDataSize(1, 1, c.dseqnum)
+		nd, err := c.layout(context.Background(), 0, data, egsz)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(nd) % egsz != 0 {
+			t.Fatalf("size %d not aligned to %d", len(nd), egsz)
+		}
+
+		for i := range data {
+			if data[i] != byte(i * 7) {
+				t.Fatalf("the input data was modified")
+			}
+		}
+
+		h := sha1.New()
+		for o := 0; o < len(nd); o += egsz {
+			dblks, err := ecGroupEncode(1, 1, c.dseqnum, c.rseqnum, ec, nd[o:o+egsz])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, r := range dblks {
+				for _, b := range r {
+					h.Write(b)
+				}
+			}
+		}
+
+		if s := fmt.Sprintf("%x", h.Sum(nil)); s != g.sha1 {
+			t.Errorf("rndmz %v size %d: expected %s got %s", g.rndmz, g.size, g.sha1, s)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package oligo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Type of an alignment operation
+type OpType int
+
+const (
+	OpMatch OpType = iota	// the nts in both oligos are the same
+	OpSub			// substitution of an nt
+	OpIns			// nt inserted in the second oligo
+	OpDel			// nt deleted from the first oligo
+)
+
+// Alignment modes
+type AlignMode int
+
+const (
+	Global AlignMode = iota	// both oligos are aligned end-to-end
+	SemiGlobal		// gaps at the ends of the oligos are not penalized
+	Local			// best matching parts of the oligos (Smith-Waterman)
+)
+
+// Single alignment operation, i.e. a single nt (or nt pair) in the
+// alignment.
+type Op struct {
+	Type	OpType
+	APos	int	// position in the first oligo (for OpIns, the position before which the nt is inserted)
+	BPos	int	// position in the second oligo (for OpDel, the position before which the nt is deleted)
+	ANt	int	// nt in the first oligo, -1 for OpIns or if not known
+	BNt	int	// nt in the second oligo, -1 for OpDel or if not known
+}
+
+// Alignment of two oligos. The first oligo is considered to be the
+// reference (original), the second one the query (read). The alignment
+// covers the parts [AStart, AEnd) and [BStart, BEnd) of the oligos.
+type Alignment struct {
+	Ops	[]Op
+	Score	int	// score of the alignment
+	Dist	int	// number of operations that are not matches
+	AStart	int
+	AEnd	int
+	BStart	int
+	BEnd	int
+	ALen	int	// length of the first oligo
+	BLen	int	// length of the second oligo
+}
+
+// Scoring for the alignment. The score of a gap with length n is
+// GapOpen + n*GapExtend. The penalties are expected to be
+// non-positive.
+type AlignParams struct {
+	Mode		AlignMode
+	Match		int
+	Mismatch	int
+	GapOpen		int
+	GapExtend	int
+}
+
+// Parameters that make the alignment score equal to minus the Levenshtein
+// distance
+var LevenshteinParams = AlignParams{Global, 0, -1, 0, -1}
+
+const negInf = -(1<<30)
+
+// Aligns oligo b to oligo a, returning the operations with the
+// smallest (Levenshtein) number of errors.
+func Align(a, b Oligo) *Alignment {
+	return AlignWith(a, b, &LevenshteinParams)
+}
+
+// Aligns oligo b to oligo a using the specified scoring and mode.
+// Uses Gotoh's algorithm, so the memory use is proportional to a.Len()*b.Len().
+func AlignWith(a, b Oligo, p *AlignParams) *Alignment {
+	n := a.Len()
+	m := b.Len()
+	w := m + 1
+
+	// best score of an alignment of a[0:i] and b[0:j] that ends with
+	// match/substitution (mm), deletion (dm), or insertion (im)
+	mm := make([]int32, (n+1)*w)
+	dm := make([]int32, (n+1)*w)
+	im := make([]int32, (n+1)*w)
+	an := make([]int, n)
+	bn := make([]int, m)
+	for i := range an {
+		an[i] = a.At(i)
+	}
+	for j := range bn {
+		bn[j] = b.At(j)
+	}
+
+	score := func(i, j int) int32 {
+		if an[i] == bn[j] {
+			return int32(p.Match)
+		}
+
+		return int32(p.Mismatch)
+	}
+
+	gopen := int32(p.GapOpen + p.GapExtend)
+	gext := int32(p.GapExtend)
+	free := p.Mode != Global
+	for i := 0; i <= n; i++ {
+		for j := 0; j <= m; j++ {
+			k := i*w + j
+			if i == 0 || j == 0 {
+				// the starting points of the alignment
+				mm[k] = negInf
+				if (i == 0 && j == 0) || free {
+					mm[k] = 0
+				}
+			} else {
+				v := max32(mm[k - w - 1], max32(dm[k - w - 1], im[k - w - 1]))
+				if p.Mode == Local && v < 0 {
+					v = 0
+				}
+
+				mm[k] = v + score(i - 1, j - 1)
+			}
+
+			dm[k] = negInf
+			if i > 0 && !(free && j == 0) {
+				dm[k] = max32(max32(mm[k - w], im[k - w]) + gopen, dm[k - w] + gext)
+			}
+
+			im[k] = negInf
+			if j > 0 && !(free && i == 0) {
+				im[k] = max32(max32(mm[k - 1], dm[k - 1]) + gopen, im[k - 1] + gext)
+			}
+		}
+	}
+
+	// find where the alignment ends
+	ei, ej, st := n, m, OpMatch
+	best := int32(negInf)
+	cell := func(i, j int, local bool) {
+		k := i*w + j
+		if mm[k] > best {
+			best, ei, ej, st = mm[k], i, j, OpMatch
+		}
+
+		if local {
+			return
+		}
+
+		if dm[k] > best {
+			best, ei, ej, st = dm[k], i, j, OpDel
+		}
+
+		if im[k] > best {
+			best, ei, ej, st = im[k], i, j, OpIns
+		}
+	}
+
+	switch p.Mode {
+	case Global:
+		cell(n, m, false)
+
+	case SemiGlobal:
+		cell(n, m, false)
+		for i := n - 1; i >= 0; i-- {
+			cell(i, m, false)
+		}
+		for j := m - 1; j >= 0; j-- {
+			cell(n, j, false)
+		}
+
+	case Local:
+		best = 0
+		ei, ej = 0, 0
+		for i := 1; i <= n; i++ {
+			for j := 1; j <= m; j++ {
+				cell(i, j, true)
+			}
+		}
+	}
+
+	al := &Alignment{Score: int(best), AEnd: ei, BEnd: ej, ALen: n, BLen: m}
+
+	// trace back
+	i, j := ei, ej
+	for i > 0 || j > 0 {
+		if (free && (i == 0 || j == 0)) || (p.Mode == Local && best <= 0) {
+			break
+		}
+
+		k := i*w + j
+		var op Op
+		switch st {
+		case OpMatch:
+			op = Op{OpMatch, i - 1, j - 1, an[i - 1], bn[j - 1]}
+			if op.ANt != op.BNt {
+				op.Type = OpSub
+			}
+
+			v := mm[k] - score(i - 1, j - 1)
+			pk := k - w - 1
+			i--
+			j--
+			if p.Mode == Local && v == 0 {
+				// start of the local alignment
+				al.Ops = append(al.Ops, op)
+				goto done
+			}
+
+			if mm[pk] == v {
+				st = OpMatch
+			} else if dm[pk] == v {
+				st = OpDel
+			} else {
+				st = OpIns
+			}
+
+		case OpDel:
+			op = Op{OpDel, i - 1, j, an[i - 1], -1}
+			pk := k - w
+			i--
+			if mm[pk] + gopen == dm[k] {
+				st = OpMatch
+			} else if dm[pk] + gext == dm[k] {
+				st = OpDel
+			} else {
+				st = OpIns
+			}
+
+		case OpIns:
+			op = Op{OpIns, i, j - 1, -1, bn[j - 1]}
+			pk := k - 1
+			j--
+			if mm[pk] + gopen == im[k] {
+				st = OpMatch
+			} else if im[pk] + gext == im[k] {
+				st = OpIns
+			} else {
+				st = OpDel
+			}
+		}
+
+		al.Ops = append(al.Ops, op)
+	}
+
+done:
+	al.AStart, al.BStart = i, j
+	if len(al.Ops) == 0 {
+		al.AStart, al.BStart = al.AEnd, al.BEnd
+	}
+
+	// the operations were added in reverse order
+	for s, e := 0, len(al.Ops) - 1; s < e; s, e = s + 1, e - 1 {
+		al.Ops[s], al.Ops[e] = al.Ops[e], al.Ops[s]
+	}
+
+	for _, op := range al.Ops {
+		if op.Type != OpMatch {
+			al.Dist++
+		}
+	}
+
+	return al
+}
+
+// Returns the number of operations of the specified type
+func (al *Alignment) Count(t OpType) (n int) {
+	for _, op := range al.Ops {
+		if op.Type == t {
+			n++
+		}
+	}
+
+	return
+}
+
+func (al *Alignment) cigar(extended bool) string {
+	var sb strings.Builder
+
+	if len(al.Ops) == 0 {
+		return "*"
+	}
+
+	if al.BStart > 0 {
+		fmt.Fprintf(&sb, "%dS", al.BStart)
+	}
+
+	var c byte
+	n := 0
+	for _, op := range al.Ops {
+		var oc byte
+
+		switch op.Type {
+		case OpMatch:
+			oc = '='
+			if !extended {
+				oc = 'M'
+			}
+
+		case OpSub:
+			oc = 'X'
+			if !extended {
+				oc = 'M'
+			}
+
+		case OpIns:
+			oc = 'I'
+
+		case OpDel:
+			oc = 'D'
+		}
+
+		if oc != c && n != 0 {
+			fmt.Fprintf(&sb, "%d%c", n, c)
+			n = 0
+		}
+
+		c = oc
+		n++
+	}
+
+	fmt.Fprintf(&sb, "%d%c", n, c)
+	if al.BEnd < al.BLen {
+		fmt.Fprintf(&sb, "%dS", al.BLen - al.BEnd)
+	}
+
+	return sb.String()
+}
+
+// Returns the SAM CIGAR string of the alignment (using M for both
+// matches and substitutions). The unaligned parts of the second oligo
+// are soft-clipped.
+func (al *Alignment) Cigar() string {
+	return al.cigar(false)
+}
+
+// Returns the CIGAR string of the alignment using = for matches and X for
+// substitutions.
+func (al *Alignment) ExtendedCigar() string {
+	return al.cigar(true)
+}
+
+// Returns the value of the SAM MD tag for the alignment. Requires the nts
+// from the first oligo to be known.
+func (al *Alignment) MD() string {
+	var sb strings.Builder
+
+	n := 0
+	indel := false
+	for _, op := range al.Ops {
+		switch op.Type {
+		case OpMatch:
+			n++
+			indel = false
+
+		case OpSub:
+			fmt.Fprintf(&sb, "%d%s", n, Nt2String(op.ANt))
+			n = 0
+			indel = false
+
+		case OpDel:
+			if !indel {
+				fmt.Fprintf(&sb, "%d^", n)
+				n = 0
+				indel = true
+			}
+
+			sb.WriteString(Nt2String(op.ANt))
+		}
+	}
+
+	fmt.Fprintf(&sb, "%d", n)
+	return sb.String()
+}
+
+// Creates an alignment from a CIGAR string (either SAM or extended). The
+// alignment starts at position apos of the first oligo. Any of the oligos
+// can be nil, in which case the nts from it are set to -1 and
+// the M operations are assumed to be matches.
+func ParseCigar(cigar string, a, b Oligo, apos int) (al *Alignment, err error) {
+	al = &Alignment{AStart: apos}
+	if cigar == "*" || cigar == "" {
+		al.AEnd = apos
+		return
+	}
+
+	i, j := apos, 0
+	nt := func(o Oligo, idx int) int {
+		if o == nil {
+			return -1
+		}
+
+		if idx >= o.Len() {
+			err = fmt.Errorf("CIGAR %s longer than the oligo %v", cigar, o)
+			return -1
+		}
+
+		return o.At(idx)
+	}
+
+	start := true
+	for s := cigar; s != ""; {
+		k := strings.IndexAny(s, "MIDNSHP=X")
+		if k <= 0 {
+			return nil, fmt.Errorf("invalid CIGAR: %s", cigar)
+		}
+
+		cnt, e := strconv.Atoi(s[0:k])
+		if e != nil {
+			return nil, fmt.Errorf("invalid CIGAR: %s: %v", cigar, e)
+		}
+
+		c := s[k]
+		s = s[k+1:]
+		switch c {
+		case 'S':
+			j += cnt
+			if start {
+				al.BStart = j
+			}
+			continue
+
+		case 'H', 'P':
+			continue
+		}
+
+		start = false
+		for ; cnt > 0; cnt-- {
+			var op Op
+
+			switch c {
+			case 'M', '=', 'X':
+				op = Op{OpMatch, i, j, nt(a, i), nt(b, j)}
+				if c == 'X' || (c == 'M' && a != nil && b != nil && op.ANt != op.BNt) {
+					op.Type = OpSub
+				}
+				i++
+				j++
+
+			case 'I':
+				op = Op{OpIns, i, j, -1, nt(b, j)}
+				j++
+
+			case 'D', 'N':
+				op = Op{OpDel, i, j, nt(a, i), -1}
+				i++
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			if op.Type != OpMatch {
+				al.Dist++
+			}
+
+			al.Ops = append(al.Ops, op)
+		}
+
+		al.AEnd, al.BEnd = i, j
+	}
+
+	al.ALen, al.BLen = al.AEnd, j
+	if a != nil {
+		al.ALen = a.Len()
+	}
+
+	if b != nil {
+		al.BLen = b.Len()
+	}
+
+	return
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,140 @@
+package oligo
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func testFromString(s string) *testOligo {
+	o := &testOligo{}
+	for i := 0; i < len(s); i++ {
+		o.seq = append(o.seq, byte(Char2Nt(s[i])))
+	}
+
+	return o
+}
+
+// applies the operations to the first oligo and checks that the result
+// is the second one
+func checkOps(t *testing.T, al *Alignment, a, b *testOligo) {
+	var r []byte
+
+	i, j := al.AStart, al.BStart
+	for _, op := range al.Ops {
+		if op.APos != i || op.BPos != j {
+			t.Fatalf("%v %v: invalid op position %v, expected %d:%d", a, b, op, i, j)
+		}
+
+		switch op.Type {
+		case OpMatch, OpSub:
+			if (op.ANt == op.BNt) != (op.Type == OpMatch) || int(a.seq[i]) != op.ANt {
+				t.Fatalf("%v %v: invalid op %v", a, b, op)
+			}
+			r = append(r, byte(op.BNt))
+			i++
+			j++
+
+		case OpIns:
+			r = append(r, byte(op.BNt))
+			j++
+
+		case OpDel:
+			if int(a.seq[i]) != op.ANt {
+				t.Fatalf("%v %v: invalid op %v", a, b, op)
+			}
+			i++
+		}
+	}
+
+	if i != al.AEnd || j != al.BEnd {
+		t.Fatalf("%v %v: alignment ends at %d:%d, expected %d:%d", a, b, i, j, al.AEnd, al.BEnd)
+	}
+
+	if string(r) != string(b.seq[al.BStart:al.BEnd]) {
+		t.Fatalf("%v %v: operations produce %v", a, b, r)
+	}
+}
+
+func TestAlign(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		s1 := randomSeq(rand.Intn(100))
+		s2 := mutateSeq(s1, rand.Intn(10))
+		a, b := &testOligo{s1}, &testOligo{s2}
+
+		al := Align(a, b)
+		if d := Distance(a, b); al.Dist != d || al.Score != -d {
+			t.Fatalf("%v %v: alignment distance %d score %d, expected %d", s1, s2, al.Dist, al.Score, d)
+		}
+
+		if al.AStart != 0 || al.BStart != 0 || al.AEnd != len(s1) || al.BEnd != len(s2) {
+			t.Fatalf("%v %v: global alignment doesn't cover the oligos: %v", s1, s2, al)
+		}
+
+		checkOps(t, al, a, b)
+
+		al2, err := ParseCigar(al.ExtendedCigar(), a, b, 0)
+		if err != nil {
+			t.Fatalf("%v %v: %v", s1, s2, err)
+		}
+
+		if len(al.Ops) != len(al2.Ops) || al.Dist != al2.Dist {
+			t.Fatalf("%v %v: parsed CIGAR %s differs", s1, s2, al.ExtendedCigar())
+		}
+
+		for n := range al.Ops {
+			if al.Ops[n] != al2.Ops[n] {
+				t.Fatalf("%v %v: parsed CIGAR %s differs at %d: %v %v", s1, s2, al.ExtendedCigar(), n, al.Ops[n], al2.Ops[n])
+			}
+		}
+	}
+}
+
+func TestCigar(t *testing.T) {
+	a := testFromString("ACGTACGTACGT")
+	b := testFromString("ACTTACGACGT")
+	al := Align(a, b)
+	if c, md := al.ExtendedCigar(), al.MD(); c != "2=1X4=1D4=" || md != "2G4^T4" {
+		t.Fatalf("unexpected CIGAR/MD: %s %s", c, md)
+	}
+
+	// the unaligned parts of the read are soft-clipped
+	a = testFromString("ACGTACGT")
+	b = testFromString("TTTACGTACGTGG")
+	al = AlignWith(a, b, &AlignParams{Local, 1, -1, -1, -1})
+	if c := al.Cigar(); c != "3S8M2S" {
+		t.Fatalf("unexpected CIGAR: %s", c)
+	}
+}
+
+func TestAlignLocal(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		sub := randomSeq(20 + rand.Intn(40))
+		s := append(randomSeq(rand.Intn(20)), sub...)
+		s = append(s, randomSeq(rand.Intn(20))...)
+		a, b := &testOligo{s}, &testOligo{sub}
+
+		al := AlignWith(a, b, &AlignParams{Local, 2, -3, -5, -2})
+		checkOps(t, al, a, b)
+		if al.Score < 2*len(sub) {
+			t.Fatalf("%v %v: local alignment score %d", s, sub, al.Score)
+		}
+
+		al = AlignWith(a, b, &AlignParams{SemiGlobal, 0, -1, 0, -1})
+		checkOps(t, al, a, b)
+		if al.Dist != 0 || al.BStart != 0 || al.BEnd != len(sub) {
+			t.Fatalf("%v %v: semi-global alignment %v", s, sub, al)
+		}
+	}
+}
+
+func TestAlignAffine(t *testing.T) {
+	a := testFromString("ACGTTGCAAGCTTACG")
+	b := testFromString("ACGTTGCTTACG")
+
+	// with the gap opening penalty the four deleted nts should be in a single gap
+	al := AlignWith(a, b, &AlignParams{Global, 1, -2, -4, -1})
+	checkOps(t, al, a, b)
+	if al.Count(OpDel) != 4 || len(al.Cigar()) != len("7M4D5M") {
+		t.Fatalf("unexpected affine alignment: %s", al.Cigar())
+	}
+}
@@ -0,0 +1,254 @@
+package oligo
+
+// Bit-parallel implementations of the Levenshtein distance and the
+// approximate substring search (Myers' algorithm, in the block-based
+// form described by Hyyrö). They process 64 rows of the dynamic
+// programming table at once and are used automatically by Distance,
+// Find, and Match when the oligos implement the Packed interface.
+
+// Oligo that stores its nts packed in 64-bit words, 2 bits per nt.
+// The nt at position i is stored in word i/32, with the first nt in
+// the most significant bits of the word.
+type Packed interface {
+	Oligo
+
+	// Returns the words that store the nts. The bits after the last nt
+	// are ignored.
+	Words() []uint64
+}
+
+// Pattern (the vertical sequence) prepared for the bit-parallel algorithm
+type bitPattern struct {
+	m	int		// pattern length
+	peq	[5][]uint64	// for each nt, bits set where the pattern matches it (none for N)
+	high	uint64		// the bit for the last row in the last block
+}
+
+// State of the columns while scanning the text
+type bitState struct {
+	pv, mv	[]uint64	// positive and negative vertical deltas
+	score	int		// the value in the last row
+}
+
+// Returns the nt at position idx from packed words
+func ntAt(w []uint64, idx int) int {
+	return int((w[idx>>5] >> (62 - 2*uint(idx&31))) & 3)
+}
+
+// Creates a pattern of length m. The mask function returns the mask of
+// the nts matched at each position (see IUPAC2Mask).
+func newBitPattern(m int, mask func(i int) int) (bp *bitPattern) {
+	bp = new(bitPattern)
+	bp.m = m
+	nb := (m + 63) / 64
+	for i := 0; i < len(bp.peq); i++ {
+		bp.peq[i] = make([]uint64, nb)
+	}
+
+	for i := 0; i < m; i++ {
+		b := uint64(1) << uint(i%64)
+		mask := mask(i)
+		for j := 0; j < N; j++ {
+			if mask & (1<<uint(j)) != 0 {
+				bp.peq[j][i/64] |= b
+			}
+		}
+	}
+
+	bp.high = uint64(1) << uint((m - 1)%64)
+	return
+}
+
+func packedPattern(p Packed) *bitPattern {
+	w := p.Words()
+	return newBitPattern(p.Len(), func(i int) int { return 1 << uint(ntAt(w, i)) })
+}
+
+func (bp *bitPattern) newState() (st *bitState) {
+	nb := len(bp.peq[0])
+	st = new(bitState)
+	st.pv = make([]uint64, nb)
+	st.mv = make([]uint64, nb)
+	for i := 0; i < nb; i++ {
+		st.pv[i] = ^uint64(0)
+	}
+
+	st.score = bp.m
+	return
+}
+
+// Advances the state by one column for the text nt.
+// The hin parameter is the difference between the values in the first row
+// of the new and the previous column: 1 if the pattern has to match from the
+// start of the text, 0 if it can start anywhere.
+// Returns the value in the last row of the new column.
+func (bp *bitPattern) step(st *bitState, nt int, hin int) int {
+	last := len(st.pv) - 1
+	for b := 0; b <= last; b++ {
+		pv, mv := st.pv[b], st.mv[b]
+		eq := bp.peq[nt][b]
+		xv := eq | mv
+		if hin < 0 {
+			eq |= 1
+		}
+
+		xh := (((eq & pv) + pv) ^ pv) | eq
+		ph := mv | ^(xh | pv)
+		mh := pv & xh
+
+		high := uint64(1) << 63
+		if b == last {
+			high = bp.high
+		}
+
+		hout := 0
+		if ph & high != 0 {
+			hout = 1
+		} else if mh & high != 0 {
+			hout = -1
+		}
+
+		ph <<= 1
+		mh <<= 1
+		if hin < 0 {
+			mh |= 1
+		} else if hin > 0 {
+			ph |= 1
+		}
+
+		st.pv[b] = mh | ^(xv | ph)
+		st.mv[b] = ph & xv
+		hin = hout
+	}
+
+	st.score += hin
+	return st.score
+}
+
+// Levenshtein distance between two packed oligos
+func distanceBits(a, b Packed) int {
+	// use the shorter oligo as a pattern, fewer blocks to update
+	if a.Len() < b.Len() {
+		a, b = b, a
+	}
+
+	n := a.Len()
+	if b.Len() == 0 {
+		return n
+	}
+
+	bp := packedPattern(b)
+	st := bp.newState()
+	w := a.Words()
+	d := bp.m
+	for j := 0; j < n; j++ {
+		d = bp.step(st, ntAt(w, j), 1)
+	}
+
+	return d
+}
+
+// Same as Find, for packed oligos
+func findBits(s, subseq Packed, maxdist int) (pos int, length int) {
+	sw := s.Words()
+	w := subseq.Words()
+	return findText(s.Len(), func(j int) int { return ntAt(sw, j) },
+		subseq.Len(), func(i int) int { return 1 << uint(ntAt(w, i)) }, maxdist)
+}
+
+// Same as Find, for patterns with IUPAC codes
+func findPattern(s Oligo, p *Pattern, maxdist int) (pos int, length int) {
+	if ps, ok := s.(Packed); ok {
+		sw := ps.Words()
+		return findText(s.Len(), func(j int) int { return ntAt(sw, j) }, p.Len(), p.MaskAt, maxdist)
+	}
+
+	return findText(s.Len(), s.At, p.Len(), p.MaskAt, maxdist)
+}
+
+// Finds the subsequence with length m in the text with length slen.
+// The text function returns the nt at each position of the text, the mask function
+// the mask of the matching nts at each position of the subsequence.
+func findText(slen int, text func(j int) int, m int, mask func(i int) int, maxdist int) (pos int, length int) {
+	if m == 0 {
+		return slen, 0
+	}
+
+	// find where the best match ends (the last one if there are multiple)
+	bp := newBitPattern(m, mask)
+	st := bp.newState()
+	end, mdist := 0, m
+	for j := 0; j < slen; j++ {
+		if d := bp.step(st, text(j), 0); d <= mdist {
+			mdist = d
+			end = j + 1
+		}
+	}
+
+	if mdist > maxdist {
+		return -1, 0
+	}
+
+	// the length of the match is found the same way as in Find, so the
+	// ties are broken the same way. A match with up to mdist errors
+	// can't start before w, no need to go over the whole text.
+	w := end - m - mdist
+	if w < 0 {
+		w = 0
+	}
+
+	n := end - w
+	f := make([]int, n + 1)
+	l := make([]int, n + 1)
+	for i := 0; i < m; i++ {
+		mi := mask(i)
+		fj1, lj1 := f[0], l[0]
+		f[0]++
+		if w == 0 {
+			l[0]++
+		} else {
+			// the match starts at w, the nts of the
+			// subsequence up to i are deleted
+			l[0]--
+		}
+
+		for j := 0; j < n; j++ {
+			mn, ln := min2(f[j+1]+1, f[j]+1, l[j+1]-1, l[j]+1) // delete & insert
+			if mi & (1 << uint(text(w + j))) == 0 {
+				mn, ln = min2(mn, fj1+1, ln, lj1) // change
+			} else {
+				mn, ln = min2(mn, fj1, ln, lj1) // matched
+			}
+
+			fj1, f[j+1] = f[j+1], mn
+			lj1, l[j+1] = l[j+1], ln
+		}
+	}
+
+	length = m + l[n]
+	pos = end - length
+	if pos < 0 {
+		length += pos
+		pos = 0
+	}
+
+	return
+}
+
+// Same as Match, for packed oligos. The pattern is already converted
+// to masks of the matching nts.
+func matchBits(a Packed, p []int, maxdist int) bool {
+	if len(p) == 0 {
+		return a.Len() <= maxdist
+	}
+
+	bp := newBitPattern(len(p), func(i int) int { return p[i] })
+	st := bp.newState()
+	w := a.Words()
+	d := bp.m
+	for j := 0; j < a.Len(); j++ {
+		d = bp.step(st, ntAt(w, j), 1)
+	}
+
+	return d <= maxdist
+}
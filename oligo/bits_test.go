@@ -0,0 +1,206 @@
+package oligo
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Minimal oligo implementation for the tests, one nt per byte.
+// The oligo/long and oligo/short packages can't be used here, they
+// import this package.
+type testOligo struct {
+	seq	[]byte
+}
+
+// Same as testOligo, but also implements the Packed interface
+type testPacked struct {
+	testOligo
+}
+
+func (o *testOligo) Len() int { return len(o.seq) }
+func (o *testOligo) Next() bool { return false }
+func (o *testOligo) At(idx int) int { return int(o.seq[idx]) }
+func (o *testOligo) Set(idx int, nt int) { o.seq[idx] = byte(nt) }
+func (o *testOligo) Clone() Oligo { return &testOligo{append([]byte(nil), o.seq...)} }
+func (o *testOligo) Slice(start, end int) Oligo { return &testOligo{o.seq[start:end]} }
+
+func (o *testOligo) String() (s string) {
+	for _, nt := range o.seq {
+		s += Nt2String(int(nt))
+	}
+
+	return
+}
+
+func (o *testOligo) Cmp(other Oligo) int {
+	return 0
+}
+
+func (o *testOligo) Append(other Oligo) bool {
+	for i := 0; i < other.Len(); i++ {
+		o.seq = append(o.seq, byte(other.At(i)))
+	}
+
+	return true
+}
+
+func (o *testPacked) Words() []uint64 {
+	w := make([]uint64, (len(o.seq) + 31) / 32 + 1)
+	for i, nt := range o.seq {
+		w[i/32] |= uint64(nt) << uint(62 - 2*(i%32))
+	}
+
+	return w
+}
+
+func randomSeq(l int) []byte {
+	s := make([]byte, l)
+	for i := range s {
+		s[i] = byte(rand.Intn(4))
+	}
+
+	return s
+}
+
+// introduces up to n random errors in the sequence
+func mutateSeq(s []byte, n int) []byte {
+	s = append([]byte(nil), s...)
+	for i := 0; i < n && len(s) > 1; i++ {
+		p := rand.Intn(len(s))
+		switch rand.Intn(3) {
+		case 0:
+			s[p] = byte(rand.Intn(4))
+		case 1:
+			s = append(s[0:p], s[p+1:]...)
+		case 2:
+			s = append(s[0:p], append([]byte{ byte(rand.Intn(4)) }, s[p:]...)...)
+		}
+	}
+
+	return s
+}
+
+func TestDistanceBits(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		s1 := randomSeq(rand.Intn(200))
+		s2 := mutateSeq(s1, rand.Intn(20))
+		if rand.Intn(10) == 0 {
+			s2 = randomSeq(rand.Intn(200))
+		}
+
+		d1 := Distance(&testOligo{s1}, &testOligo{s2})
+		d2 := Distance(&testPacked{testOligo{s1}}, &testPacked{testOligo{s2}})
+		if d1 != d2 {
+			t.Fatalf("%v %v: generic distance %d bit-parallel %d", s1, s2, d1, d2)
+		}
+	}
+}
+
+func TestFindBits(t *testing.T) {
+	for i := 0; i < 5000; i++ {
+		sub := randomSeq(10 + rand.Intn(60))
+		s := append(randomSeq(rand.Intn(20)), mutateSeq(sub, rand.Intn(8))...)
+		s = append(s, randomSeq(rand.Intn(20))...)
+		maxdist := rand.Intn(10)
+
+		// the same match as the generic implementation, the cut
+		// points can't depend on the type of the oligo
+		o, osub := &testOligo{s}, &testOligo{sub}
+		p1, l1 := Find(o, osub, maxdist)
+		p2, l2 := Find(&testPacked{testOligo{s}}, &testPacked{testOligo{sub}}, maxdist)
+		if p1 != p2 || l1 != l2 {
+			t.Fatalf("%v %v: generic find %d:%d bit-parallel %d:%d", s, sub, p1, l1, p2, l2)
+		}
+
+		if p2 < 0 || i >= 200 {
+			continue
+		}
+
+		// the match has to be the closest substring
+		mdist := len(sub)
+		for j := 0; j <= len(s); j++ {
+			for k := j; k <= len(s); k++ {
+				if d := Distance(o.Slice(j, k), osub); d < mdist {
+					mdist = d
+				}
+			}
+		}
+
+		if d := Distance(o.Slice(p2, p2 + l2), osub); d != mdist {
+			t.Fatalf("%v %v: match %d:%d distance %d, expected %d", s, sub, p2, l2, d, mdist)
+		}
+	}
+}
+
+func TestMatchBits(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		s := randomSeq(rand.Intn(100))
+		p := ""
+		for _, nt := range mutateSeq(s, rand.Intn(10)) {
+			if rand.Intn(10) == 0 {
+				p += "?"
+			} else {
+				p += Nt2String(int(nt))
+			}
+		}
+
+		maxdist := rand.Intn(10)
+		m1 := Match(&testOligo{s}, p, maxdist)
+		m2 := Match(&testPacked{testOligo{s}}, p, maxdist)
+		if m1 != m2 {
+			t.Fatalf("%v %v: generic match %v bit-parallel %v", s, p, m1, m2)
+		}
+	}
+}
+
+// 150-nt reads with a few errors
+func benchReads(n int, packed bool) (ols []Oligo) {
+	s := randomSeq(150)
+	for i := 0; i < n; i++ {
+		o := &testOligo{mutateSeq(s, 10)}
+		if packed {
+			ols = append(ols, &testPacked{*o})
+		} else {
+			ols = append(ols, o)
+		}
+	}
+
+	return
+}
+
+func benchmarkDistance(b *testing.B, packed bool) {
+	ols := benchReads(64, packed)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Distance(ols[i%len(ols)], ols[(i+1)%len(ols)])
+	}
+}
+
+func benchmarkFind(b *testing.B, packed bool) {
+	ols := benchReads(64, packed)
+	primer := Oligo(&testOligo{randomSeq(20)})
+	if packed {
+		primer = &testPacked{*primer.(*testOligo)}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Find(ols[i%len(ols)], primer, 8)
+	}
+}
+
+func BenchmarkDistance150(b *testing.B) {
+	benchmarkDistance(b, false)
+}
+
+func BenchmarkDistanceBits150(b *testing.B) {
+	benchmarkDistance(b, true)
+}
+
+func BenchmarkFind150(b *testing.B) {
+	benchmarkFind(b, false)
+}
+
+func BenchmarkFindBits150(b *testing.B) {
+	benchmarkFind(b, true)
+}
@@ -0,0 +1,197 @@
+package oligo
+
+// Unknown nt (N in the sequencing data). The oligo/long implementation
+// can store it when the reads are parsed with the NKeep policy. It doesn't
+// match any nt in Find, Match, and Distance.
+const N = 4
+
+// What to do with sequences that contain unknown (N) nts
+type NPolicy int
+
+const (
+	NDrop NPolicy = iota	// drop the sequences that have Ns
+	NKeep			// keep the Ns as unknown nts
+)
+
+// Masks of the nts matched by the IUPAC codes, bit (1<<nt) is set for
+// each nt the code matches
+var iupacMasks = map[byte]int {
+	'A': 1<<A,
+	'T': 1<<T,
+	'C': 1<<C,
+	'G': 1<<G,
+	'R': 1<<A | 1<<G,
+	'Y': 1<<C | 1<<T,
+	'S': 1<<G | 1<<C,
+	'W': 1<<A | 1<<T,
+	'K': 1<<G | 1<<T,
+	'M': 1<<A | 1<<C,
+	'B': 1<<C | 1<<G | 1<<T,
+	'D': 1<<A | 1<<G | 1<<T,
+	'H': 1<<A | 1<<C | 1<<T,
+	'V': 1<<A | 1<<C | 1<<G,
+	'N': 1<<A | 1<<T | 1<<C | 1<<G,
+	'?': 1<<A | 1<<T | 1<<C | 1<<G,
+}
+
+var iupacCodes = "?ATWCMYHGRKDSVBN"
+
+// Converts an IUPAC nt code to a mask of the nts it matches.
+// Also accepts ? as an alias for N.
+// Returns 0 if the code is invalid.
+func IUPAC2Mask(c byte) int {
+	return iupacMasks[c]
+}
+
+// Converts a mask of nts to its IUPAC code
+func Mask2IUPAC(mask int) byte {
+	return iupacCodes[mask & 0xF]
+}
+
+// Oligo pattern that can contain IUPAC degenerate nts, used as a
+// primer or subsequence in Find. It implements the Oligo interface, At
+// returns N for the degenerate positions.
+type Pattern struct {
+	mask	[]byte
+}
+
+// Converts a string with IUPAC codes to a pattern
+// Returns the pattern and true if the conversion was successful
+func NewPattern(s string) (*Pattern, bool) {
+	p := &Pattern{make([]byte, len(s))}
+	for i := 0; i < len(s); i++ {
+		m := IUPAC2Mask(s[i])
+		if m == 0 {
+			return nil, false
+		}
+
+		p.mask[i] = byte(m)
+	}
+
+	return p, true
+}
+
+// for when we know that there can't be error
+func NewPattern1(s string) *Pattern {
+	p, _ := NewPattern(s)
+	return p
+}
+
+// Returns the mask of the nts matched at position idx
+func (p *Pattern) MaskAt(idx int) int {
+	return int(p.mask[idx])
+}
+
+// Returns the reverse complement of the pattern
+func (p *Pattern) ReverseComplement() *Pattern {
+	r := &Pattern{make([]byte, len(p.mask))}
+	for i, m := range p.mask {
+		var c byte
+
+		// A<->T, C<->G
+		c |= (m & (1<<A)) << (T - A)
+		c |= (m & (1<<T)) >> (T - A)
+		c |= (m & (1<<C)) << (G - C)
+		c |= (m & (1<<G)) >> (G - C)
+		r.mask[len(p.mask) - i - 1] = c
+	}
+
+	return r
+}
+
+// Implementation of the Oligo interface...
+func (p *Pattern) Len() int {
+	return len(p.mask)
+}
+
+func (p *Pattern) String() string {
+	s := make([]byte, len(p.mask))
+	for i, m := range p.mask {
+		s[i] = Mask2IUPAC(int(m))
+	}
+
+	return string(s)
+}
+
+func (p *Pattern) Cmp(other Oligo) int {
+	olen := other.Len()
+	if p.Len() < olen {
+		return -1
+	} else if p.Len() > olen {
+		return 1
+	}
+
+	for i := 0; i < olen; i++ {
+		n := p.At(i) - other.At(i)
+		if n < 0 {
+			return -1
+		} else if n > 0 {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (p *Pattern) Next() bool {
+	return false
+}
+
+func (p *Pattern) At(idx int) int {
+	switch p.mask[idx] {
+	case 1<<A:
+		return A
+	case 1<<T:
+		return T
+	case 1<<C:
+		return C
+	case 1<<G:
+		return G
+	}
+
+	return N
+}
+
+func (p *Pattern) Set(idx int, nt int) {
+	if nt < 0 || nt >= N {
+		p.mask[idx] = 0xF
+	} else {
+		p.mask[idx] = 1<<uint(nt)
+	}
+}
+
+func (p *Pattern) Slice(start, end int) Oligo {
+	if end <= 0 {
+		end = len(p.mask) - end
+	}
+
+	if end > len(p.mask) {
+		end = len(p.mask)
+	} else if end < 0 {
+		end = 0
+	}
+
+	if start < 0 || start > len(p.mask) || start > end {
+		return &Pattern{}
+	}
+
+	return &Pattern{append([]byte(nil), p.mask[start:end]...)}
+}
+
+func (p *Pattern) Clone() Oligo {
+	return &Pattern{append([]byte(nil), p.mask...)}
+}
+
+func (p *Pattern) Append(other Oligo) bool {
+	if op, ok := other.(*Pattern); ok {
+		p.mask = append(p.mask, op.mask...)
+		return true
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		p.mask = append(p.mask, 0)
+		p.Set(len(p.mask) - 1, other.At(i))
+	}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package oligo
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPattern(t *testing.T) {
+	s := "ACGTRYSWKMBDHVN"
+	p, ok := NewPattern(s)
+	if !ok || p.String() != s {
+		t.Fatalf("pattern conversion fails: %v: %v", s, p)
+	}
+
+	if _, ok := NewPattern("ACGX"); ok {
+		t.Fatalf("invalid pattern accepted")
+	}
+
+	if p.At(0) != A || p.At(4) != N {
+		t.Fatalf("At() fails: %d %d", p.At(0), p.At(4))
+	}
+}
+
+func TestMatchIUPAC(t *testing.T) {
+	a := testFromString("ACGTACGT")
+	for _, p := range []string{ "ACGTACGT", "RCGTACGT", "MSKWMSKW", "NNNNNNNN", "ACGTACG?" } {
+		if !Match(a, p, 0) {
+			t.Fatalf("%v doesn't match %s", a, p)
+		}
+	}
+
+	for _, p := range []string{ "YCGTACGT", "ACGTACGV" } {
+		if Match(a, p, 0) || !Match(a, p, 1) {
+			t.Fatalf("%v should match %s with one error", a, p)
+		}
+	}
+
+	// the unknown nts don't match anything
+	a.seq[2] = N
+	if Match(a, "ACNTACGT", 0) || !Match(a, "ACNTACGT", 1) {
+		t.Fatalf("unknown nt matched")
+	}
+}
+
+func TestDistanceN(t *testing.T) {
+	// the unknown nts don't match each other either
+	a := testFromString("ACGTACGT")
+	b := testFromString("ACGTACGT")
+	a.seq[2], b.seq[2] = N, N
+	if d := Distance(a, b); d != 1 {
+		t.Fatalf("distance with Ns on both sides %d expected 1", d)
+	}
+
+	s := testFromString("TTTTACGTACGTTTTT")
+	s.seq[6] = N
+	if pos, _ := Find(s, a, 0); pos >= 0 {
+		t.Fatalf("found at %d with Ns on both sides", pos)
+	}
+
+	if pos, l := Find(s, a, 1); pos != 4 || l != 8 {
+		t.Fatalf("found at %d:%d expected 4:8", pos, l)
+	}
+}
+
+func TestFindPattern(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		sub := randomSeq(10 + rand.Intn(30))
+		s := append(randomSeq(rand.Intn(20)), mutateSeq(sub, rand.Intn(4))...)
+		s = append(s, randomSeq(rand.Intn(20))...)
+		maxdist := rand.Intn(6)
+
+		// without degenerate nts the pattern should behave the same as an oligo
+		p := &Pattern{}
+		p.Append(&testOligo{sub})
+		p1, l1 := Find(&testOligo{s}, &testOligo{sub}, maxdist)
+		p2, l2 := Find(&testOligo{s}, p, maxdist)
+		if p1 < 0 != (p2 < 0) || (p1 >= 0 && Distance((&testOligo{s}).Slice(p1, p1 + l1), &testOligo{sub}) != Distance((&testOligo{s}).Slice(p2, p2 + l2), &testOligo{sub})) {
+			t.Fatalf("%v %v: find %d:%d pattern find %d:%d", s, sub, p1, l1, p2, l2)
+		}
+
+		// degenerate positions match whatever is there
+		p.mask[0] = 0xF
+		p.mask[len(sub) - 1] = 0xF
+		s = append(append(randomSeq(5), sub...), randomSeq(5)...)
+		s[5] = byte((int(s[5]) + 1) % 4)
+		s[5 + len(sub) - 1] = byte((int(s[5 + len(sub) - 1]) + 1) % 4)
+		if pos, l := Find(&testOligo{s}, p, 0); pos != 5 || l != len(sub) {
+			t.Fatalf("%v %v: degenerate find %d:%d", s, p, pos, l)
+		}
+	}
+}
+
+func TestPatternReverseComplement(t *testing.T) {
+	for _, s := range [][2]string{ {"ACGT", "ACGT"}, {"AACG", "CGTT"}, {"RYSWKMBDHVN", "NBDHVKMWSRY"} } {
+		if r := NewPattern1(s[0]).ReverseComplement().String(); r != s[1] {
+			t.Fatalf("reverse complement of %s: %s expected %s", s[0], r, s[1])
+		}
+	}
+}
@@ -40,6 +40,23 @@ func FromString(s string) (*Oligo, bool) {
 	return &Oligo{seq, false}, true
 }
 
+// Same as FromString, but also accepts N, stored as an unknown nt (oligo.N)
+func FromStringN(s string) (*Oligo, bool) {
+	seq := make([]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		nt := oligo.Char2Nt(s[i])
+		if s[i] == 'N' {
+			nt = oligo.N
+		} else if nt < 0 {
+			return nil, false
+		}
+
+		seq[i] = byte(nt)
+	}
+
+	return &Oligo{seq, false}, true
+}
+
 // for when we know that there can't be error
 func FromString1(s string) *Oligo {
 	o, _ := FromString(s)
@@ -171,12 +188,12 @@ func (o *Oligo) Clone() oligo.Oligo {
 		return nil
 	}
 
+	// the clone gets its own copy, marking o as sharing the seq
+	// would be a write to an oligo that may be used concurrently
+	// (e.g. the primers in l1.Codec.Encode)
 	no := new(Oligo)
-//	no.seq = make([]byte, no.len)
-//	copy(no.seq, o.seq)
-	no.seq = o.seq
-	no.sharing = true
-	o.sharing = true
+	no.seq = make([]byte, len(o.seq))
+	copy(no.seq, o.seq)
 
 	return no
 }
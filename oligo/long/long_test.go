@@ -4,6 +4,7 @@ import (
 	"flag"
 	"math/rand"
 	"os"
+	"sync"
 	"testing"
 	"adscodex/oligo"
 	"adscodex/oligo/short"
@@ -118,6 +119,29 @@ func TestNext(t *testing.T) {
 	}
 }
 
+func TestClone(t *testing.T) {
+	o1, _ := FromString(randomString(40))
+	so1 := o1.String()
+
+	// the original and the clones can change independently
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o2 := o1.Clone()
+			o2.Append(o1)
+			o2.Set(0, (o2.At(0) + 1) % 4)
+		}()
+	}
+	wg.Wait()
+
+	o3 := o1.Clone()
+	o1.Next()
+	if o1.String() == so1 || o3.String() != so1 {
+		t.Fatalf("Clone() fails: %v: %v: %v", so1, o1, o3)
+	}
+}
 
 func TestSlice(t *testing.T) {
 	for i := 0; i < *iternum; {
@@ -224,3 +248,20 @@ func TestDist(t *testing.T) {
 		t.Fatalf("Diff %v:%v should be '-----------------------------------------------------------------------------------------------------------------------------R---------------------' instead of %v", o1, o2, diff)
 	}
 }
+
+func TestFromStringN(t *testing.T) {
+	so := "ACGNTTNA"
+	if _, ok := FromString(so); ok {
+		t.Fatalf("FromString() accepts unknown nts")
+	}
+
+	o, ok := FromStringN(so)
+	if !ok || o.String() != so || o.At(3) != oligo.N {
+		t.Fatalf("FromStringN() fails: %v: %v", so, o)
+	}
+
+	oligo.Invert(o)
+	if o.String() != "TGCNAANT" {
+		t.Fatalf("Invert() with unknown nts fails: %v", o)
+	}
+}
@@ -47,11 +47,11 @@ type Oligo interface {
 	Append(other Oligo) bool
 }
 
-var ntNames = "ATCG"
+var ntNames = "ATCGN"
 
 // Converts an numeric value of a nucleotide (nt) to its string value
 func Nt2String(nt int) string {
-	if nt<0 || nt > len(ntNames) {
+	if nt<0 || nt >= len(ntNames) {
 		return "?"
 	}
 
@@ -132,6 +132,12 @@ func MaxHomopolymer(o Oligo) (maxhp int) {
 
 // Implements Levenshtein distance
 func Distance(a, b Oligo) int {
+	if pa, ok := a.(Packed); ok {
+		if pb, ok := b.(Packed); ok {
+			return distanceBits(pa, pb)
+		}
+	}
+
 	f := make([]int, b.Len() + 1)
 
 	for j := range f {
@@ -146,7 +152,7 @@ func Distance(a, b Oligo) int {
 		for m := 0; m < b.Len(); m++ {
 			cb := b.At(m)
 			mn := min(f[j]+1, f[j-1]+1) // delete & insert
-			if cb != ca {
+			if cb != ca || ca == N {	// N doesn't match anything
 				mn = min(mn, fj1+1) // change
 			} else {
 				mn = min(mn, fj1) // matched
@@ -160,20 +166,21 @@ func Distance(a, b Oligo) int {
 	return f[len(f)-1]
 }
 
-// Match oligo to a pattern. Allows ? for matching any nt
+// Match oligo to a pattern. The pattern can contain IUPAC codes, as well
+// as ? for matching any nt
 func Match(a Oligo, p string, maxdist int) bool {
 	b := make([]int, len(p))
 	for i := 0; i < len(p); i++ {
-		if p[i] == '?' {
-			b[i] = -1
-		} else {
-			b[i] = Char2Nt(p[i])
-			if b[i] == -1 {
-				return false
-			}
+		b[i] = IUPAC2Mask(p[i])
+		if b[i] == 0 {
+			return false
 		}
 	}
 
+	if pa, ok := a.(Packed); ok {
+		return matchBits(pa, b, maxdist)
+	}
+
 	f := make([]int, len(b) + 1)
 	for j := range f {
 		f[j] = j
@@ -187,7 +194,7 @@ func Match(a Oligo, p string, maxdist int) bool {
 		for m := 0; m < len(b); m++ {
 			cb := b[m]
 			mn := min(f[j]+1, f[j-1]+1) // delete & insert
-			if cb != -1 && cb != ca {
+			if cb & (1<<uint(ca)) == 0 {
 				mn = min(mn, fj1+1) // change
 			} else {
 				mn = min(mn, fj1) // matched
@@ -203,9 +210,20 @@ func Match(a Oligo, p string, maxdist int) bool {
 
 // Finds subsequence in a sequence, with up to maxdist errors allowed.
 // Similar to Levenshtein distance.
+// If the subsequence is a Pattern, its IUPAC codes are matched too.
 // Returns the position and the length in the original sequence, -1 for position
 // if not found.
 func Find(s, subseq Oligo, maxdist int) (pos int, length int) {
+	if p, ok := subseq.(*Pattern); ok {
+		return findPattern(s, p, maxdist)
+	}
+
+	if ps, ok := s.(Packed); ok {
+		if pss, ok := subseq.(Packed); ok {
+			return findBits(ps, pss, maxdist)
+		}
+	}
+
 	slen := s.Len()
 	sslen := subseq.Len()
 	f := make([]int, slen + 1)
@@ -226,7 +244,7 @@ func Find(s, subseq Oligo, maxdist int) (pos int, length int) {
 			cb := s.At(j)
 
 			mn, ln := min2(f[j+1]+1, f[j]+1, l[j+1]-1, l[j]+1) // delete & insert
-			if cb != ca {
+			if cb != ca || ca == N {	// N doesn't match anything
 				mn, ln = min2(mn, fj1+1, ln, lj1) // change
 			} else {
 				mn, ln = min2(mn, fj1, ln, lj1) // matched
@@ -293,6 +311,10 @@ func HasSuffix(seq, suffix Oligo, maxdist int) bool {
 	return false
 }
 
+// Returns the Levenshtein distance and the changes needed to convert one
+// oligo to the other as a string ('-' for match, 'R' for substitution,
+// 'I' for insertion, 'D' for deletion).
+// Deprecated: use Align instead.
 func Diff(from, to Oligo) (int, string) {
 	m := from.Len()
 	n := to.Len()
@@ -403,6 +425,8 @@ func Invert(o Oligo)  {
 			nt = C
 		case C:
 			nt = G
+		case N:
+			// unknown stays unknown
 		default:
 			panic("unknown nucleotide")
 		}
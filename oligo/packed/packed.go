@@ -0,0 +1,334 @@
+// Package oligo/packed implements the Oligo interface for sequences of
+// any length, storing each nt in 2 bits. It uses 4 times less memory
+// than oligo/long and implements the oligo.Packed interface, so the
+// distance and search functions from the oligo package use their
+// bit-parallel versions on it.
+package packed
+
+import (
+	"math/bits"
+	"adscodex/oligo"
+)
+
+type Oligo struct {
+	// Oligo length
+	len	int
+
+	// Sequence of nts
+	// Each nt uses 2 bits, 32 nts per word, with nt at position 0
+	// stored in the most significant bits of seq[0], etc.
+	// The bits after the last nt are always zero.
+	seq	[]uint64
+}
+
+const (
+	complMask = 0x5555555555555555	// A<->T and C<->G flip the lowest bit of the nt
+)
+
+// number of words needed for olen nts
+func wordNum(olen int) int {
+	return (olen + 31) / 32
+}
+
+// bit position of the nt at idx in its word
+func shift(idx int) uint {
+	return uint(62 - 2*(idx%32))
+}
+
+// mask for the used bits in the last word of an oligo with length olen
+func tailMask(olen int) uint64 {
+	if olen%32 == 0 {
+		return ^uint64(0)
+	}
+
+	return ^uint64(0) << uint(64 - 2*(olen%32))
+}
+
+// Creates a new packed oligo object with the specified length and
+// value of "AAA...AA"
+func New(olen int) *Oligo {
+	return &Oligo{olen, make([]uint64, wordNum(olen))}
+}
+
+// Creates a new packed oligo object from its string representation
+// Returns an Object and true if the conversion was successful
+func FromString(s string) (*Oligo, bool) {
+	o := New(len(s))
+	for i := 0; i < len(s); i++ {
+		nt := oligo.Char2Nt(s[i])
+		if nt < 0 {
+			return nil, false
+		}
+
+		o.seq[i/32] |= uint64(nt) << shift(i)
+	}
+
+	return o, true
+}
+
+// for when we know that there can't be error
+func FromString1(s string) *Oligo {
+	o, _ := FromString(s)
+	return o
+}
+
+// Copies oligo of any type (implementing the Oligo interface) to a packed
+// Oligo.
+// Returns a new Oligo object and true (because the conversion is always possible)
+func Copy(o oligo.Oligo) (*Oligo, bool) {
+	if o1, ok := o.(*Oligo); ok {
+		return o1.copy(), true
+	}
+
+	ol := New(o.Len())
+	if p, ok := o.(oligo.Packed); ok {
+		copy(ol.seq, p.Words())
+		if len(ol.seq) > 0 {
+			ol.seq[len(ol.seq) - 1] &= tailMask(ol.len)
+		}
+	} else {
+		for i := 0; i < ol.len; i++ {
+			ol.seq[i/32] |= uint64(o.At(i)) << shift(i)
+		}
+	}
+
+	return ol, true
+}
+
+// Implementation of the Oligo interface...
+func (o *Oligo) Len() int {
+	return o.len
+}
+
+func (o *Oligo) String() string {
+	s := make([]byte, o.len)
+	for i := 0; i < o.len; i++ {
+		s[i] = "ATCG"[o.At(i)]
+	}
+
+	return string(s)
+}
+
+// Returns a new oligo with its own copy of the nts. The original is
+// not modified, so it can be copied concurrently (e.g. the primers).
+func (o *Oligo) copy() *Oligo {
+	seq := make([]uint64, len(o.seq))
+	copy(seq, o.seq)
+	return &Oligo{o.len, seq}
+}
+
+func (o *Oligo) Cmp(other oligo.Oligo) int {
+	olen := other.Len()
+	if o.len < olen {
+		return -1
+	} else if o.len > olen {
+		return 1
+	}
+
+	if o1, ok := other.(*Oligo); ok {
+		// the unused bits are zero, so we can compare whole words
+		for i := 0; i < wordNum(o.len); i++ {
+			if o.seq[i] < o1.seq[i] {
+				return -1
+			} else if o.seq[i] > o1.seq[i] {
+				return 1
+			}
+		}
+
+		return 0
+	}
+
+	for i := 0; i < olen; i++ {
+		n := o.At(i) - other.At(i)
+		if n < 0 {
+			return -1
+		} else if n > 0 {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (o *Oligo) Next() bool {
+	var i int
+
+	for i = o.len - 1; i >= 0; i-- {
+		nt := o.At(i)
+		if nt < 3 {
+			o.Set(i, nt + 1)
+			break
+		}
+
+		o.Set(i, 0)
+	}
+
+	return i >= 0
+}
+
+func (o *Oligo) At(idx int) int {
+	return int((o.seq[idx/32] >> shift(idx)) & 3)
+}
+
+func (o *Oligo) Set(idx int, nt int) {
+	s := shift(idx)
+	o.seq[idx/32] &= ^(uint64(3) << s)
+	o.seq[idx/32] |= uint64(nt & 3) << s
+}
+
+// Returns olen nts from the words, starting from nt at position start
+func extract(seq []uint64, start, olen int) (ret []uint64) {
+	ret = make([]uint64, wordNum(olen))
+	sw := start / 32
+	sb := uint(2*(start%32))
+	for i := range ret {
+		w := seq[sw + i] << sb
+		if sb != 0 && sw + i + 1 < len(seq) {
+			w |= seq[sw + i + 1] >> (64 - sb)
+		}
+
+		ret[i] = w
+	}
+
+	if len(ret) > 0 {
+		ret[len(ret) - 1] &= tailMask(olen)
+	}
+
+	return
+}
+
+func (o *Oligo) Slice(start, end int) oligo.Oligo {
+	if end <= 0 {
+		end = o.len - end
+	}
+
+	if end > o.len {
+		end = o.len
+	} else if end < 0 {
+		end = 0
+	}
+
+	if start < 0 || start > o.len || start > end {
+		return New(0)
+	}
+
+	return &Oligo{end - start, extract(o.seq, start, end - start)}
+}
+
+func (o *Oligo) Clone() oligo.Oligo {
+	if o == nil {
+		return nil
+	}
+
+	return o.copy()
+}
+
+func (o *Oligo) Append(other oligo.Oligo) bool {
+	if o1, ok := other.(*Oligo); ok && o1 == o {
+		other = o.Slice(0, o.len)
+	}
+
+	olen := other.Len()
+	nlen := o.len + olen
+
+	if n := wordNum(nlen); n > len(o.seq) {
+		seq := make([]uint64, n, n + n/2)
+		copy(seq, o.seq)
+		o.seq = seq
+	}
+
+	p, ok := other.(oligo.Packed)
+	if !ok {
+		for i := 0; i < olen; i++ {
+			o.seq[(o.len + i)/32] |= uint64(other.At(i)) << shift(o.len + i)
+		}
+
+		o.len = nlen
+		return true
+	}
+
+	ow := p.Words()
+	sw := o.len / 32
+	sb := uint(2*(o.len%32))
+	for i := 0; i < wordNum(olen); i++ {
+		w := ow[i]
+		if i == wordNum(olen) - 1 {
+			w &= tailMask(olen)
+		}
+
+		o.seq[sw + i] |= w >> sb
+		if sb != 0 && sw + i + 1 < len(o.seq) {
+			o.seq[sw + i + 1] |= w << (64 - sb)
+		}
+	}
+
+	o.len = nlen
+	return true
+}
+
+// Implementation of the oligo.Packed interface
+func (o *Oligo) Words() []uint64 {
+	return o.seq
+}
+
+// reverses the order of the nts in a word
+func reverseWord(w uint64) uint64 {
+	w = bits.ReverseBytes64(w)
+	w = ((w >> 4) & 0x0F0F0F0F0F0F0F0F) | ((w & 0x0F0F0F0F0F0F0F0F) << 4)
+	w = ((w >> 2) & 0x3333333333333333) | ((w & 0x3333333333333333) << 2)
+	return w
+}
+
+// Reverses the oligo in place, i.e. nucleotide at position 0 becomes the one at position o.Len() - 1, etc.
+func (o *Oligo) Reverse() {
+	n := len(o.seq)
+	if n == 0 {
+		return
+	}
+
+	seq := make([]uint64, n)
+	for i, w := range o.seq {
+		seq[n - i - 1] = reverseWord(w)
+	}
+
+	// the nts are now at the end of the last word, shift them to the start
+	o.seq = extract(seq, 32*n - o.len, o.len)
+}
+
+// Inverts the oligo in place, i.e. A->T, T->A, C->G, G->C
+func (o *Oligo) Invert() {
+	for i := range o.seq {
+		o.seq[i] ^= complMask
+	}
+
+	if len(o.seq) > 0 {
+		o.seq[len(o.seq) - 1] &= tailMask(o.len)
+	}
+}
+
+// Returns the reverse complement of the oligo as a new oligo
+func (o *Oligo) ReverseComplement() *Oligo {
+	ret, _ := Copy(o)
+	ret.Reverse()
+	ret.Invert()
+	return ret
+}
+
+// Returns a 64-bit hash of the oligo. Oligos that are equal (i.e. Cmp
+// returns 0) have the same hash.
+func (o *Oligo) Hash() uint64 {
+	h := uint64(o.len)
+	for i := 0; i < wordNum(o.len); i++ {
+		h ^= o.seq[i]
+		h *= 0x9e3779b97f4a7c15
+		h ^= h >> 29
+	}
+
+	// final mixing (splitmix64)
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,271 @@
+package packed
+
+import (
+	"flag"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+	"adscodex/oligo/short"
+)
+
+var iternum = flag.Int("n", 100, "number of iterations")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+func randomString(l int) string {
+	so := ""
+	for i := 0; i < l; i++ {
+		so += oligo.Nt2String(rand.Intn(4))
+	}
+
+	return so
+}
+
+func randomOligo(l int) (o oligo.Oligo) {
+	so := randomString(l)
+
+	// randomly return some of the oligos as short or long, so we can test
+	// the interoperability
+	switch rand.Intn(3) {
+	case 0:
+		if l <= 32 {
+			o, _ = short.FromString(so)
+			break
+		}
+		fallthrough
+
+	case 1:
+		o, _ = long.FromString(so)
+
+	default:
+		o, _ = FromString(so)
+	}
+
+	return
+}
+
+func TestAt(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		so1 := randomString(rand.Intn(200))
+		o1, _ := FromString(so1)
+		so2 := ""
+		for i := 0; i < o1.Len(); i++ {
+			so2 += oligo.Nt2String(o1.At(i))
+		}
+
+		if so1 != so2 {
+			t.Fatalf("At() fails: %v: %v", so1, so2)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		so1 := randomString(rand.Intn(200))
+		o1, _ := FromString(so1)
+		so2 := o1.String()
+
+		if so1 != so2 {
+			t.Fatalf("String() fails: %v: %v", so1, so2)
+		}
+	}
+}
+
+func TestCmp(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		l := rand.Intn(100)
+		o1 := FromString1(randomString(l))
+		o2 := randomOligo(l)
+		if rand.Intn(4) == 0 {
+			o2 = randomOligo(rand.Intn(100))
+		}
+
+		lo1, _ := long.FromString(o1.String())
+		if c1, c2 := o1.Cmp(o2), lo1.Cmp(o2); c1 != c2 {
+			t.Fatalf("Cmp() fails: %v:%v %d:%d", o1, o2, c1, c2)
+		}
+
+		o3, _ := Copy(o2)
+		if o1.Cmp(o3) != lo1.Cmp(o2) {
+			t.Fatalf("Cmp() packed fails: %v:%v", o1, o3)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		o1 := FromString1(randomString(1 + rand.Intn(100)))
+		o2 := o1.Clone()
+		if !o2.Next() {
+			// all Gs, wraps around to all As
+			if o2.Cmp(New(o1.Len())) != 0 {
+				t.Fatalf("Next() fails: %v: %v", o1, o2)
+			}
+
+			continue
+		}
+
+		if o1.Cmp(o2) != -1 {
+			t.Fatalf("Next() fails: %v: %v", o1, o2)
+		}
+	}
+}
+
+func TestSlice(t *testing.T) {
+	for i := 0; i < *iternum; {
+		o1 := FromString1(randomString(rand.Intn(200)))
+		if o1.Len() < 4 {
+			continue
+		}
+
+		s := rand.Intn(o1.Len())
+		e := rand.Intn(o1.Len())
+		if e <= s {
+			continue
+		}
+
+		so1 := o1.String()[s:e]
+		o2 := o1.Slice(s, e)
+		if so2 := o2.String(); so1 != so2 {
+			t.Fatalf("Slice() fails: %v: %v", so1, so2)
+		}
+
+		// the slice should compare the same as a fresh oligo
+		if o3 := FromString1(so1); o3.Cmp(o2) != 0 || o3.Hash() != o2.(*Oligo).Hash() {
+			t.Fatalf("Slice() doesn't clear the unused bits: %v", o2)
+		}
+
+		i++
+	}
+}
+
+func TestAppend(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		o1 := FromString1(randomString(rand.Intn(100)))
+		o2 := randomOligo(rand.Intn(100))
+
+		so1 := o1.String() + o2.String()
+		ok := o1.Append(o2)
+		so2 := o1.String()
+
+		if !ok || so1 != so2 {
+			t.Fatalf("Append() fails: %v: %v", so1, so2)
+		}
+	}
+}
+
+func TestAppendSelf(t *testing.T) {
+	o1 := FromString1(randomString(45))
+	so := o1.String() + o1.String()
+	o1.Append(o1)
+	if o1.String() != so {
+		t.Fatalf("Append() to itself fails: %v: %v", so, o1)
+	}
+}
+
+func TestZeroAppend(t *testing.T) {
+	o1 := New(0)
+	o2 := FromString1(randomString(rand.Intn(47)))
+
+	o1.Append(o2)
+	if o1.Cmp(o2) != 0 {
+		t.Fatalf("append to empty oligo")
+	}
+}
+
+func TestCopy(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		o1 := randomOligo(rand.Intn(100))
+		o2, ok := Copy(o1)
+		if !ok || o2.String() != o1.String() {
+			t.Fatalf("Copy() fails: %v: %v", o1, o2)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	o1 := FromString1(randomString(40))
+	so := o1.String()
+	o2 := o1.Clone()
+	o2.Set(0, (o1.At(0) + 1) % 4)
+	if o1.String() != so {
+		t.Fatalf("changing a clone changes the original: %v: %v", so, o1)
+	}
+}
+
+func TestCloneConcurrent(t *testing.T) {
+	o1 := FromString1(randomString(40))
+	so1 := o1.String()
+
+	// the clones and the copies don't change the original
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o2 := o1.Clone()
+			o2.Append(o1)
+			o2.Set(0, (o2.At(0) + 1) % 4)
+			o3, _ := Copy(o1)
+			o3.Next()
+		}()
+	}
+	wg.Wait()
+
+	o2 := o1.Clone()
+	o3, _ := Copy(o1)
+	o1.Next()
+	if o1.String() == so1 || o2.String() != so1 || o3.String() != so1 {
+		t.Fatalf("Clone() fails: %v: %v: %v: %v", so1, o1, o2, o3)
+	}
+}
+
+func TestReverseComplement(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		so := randomString(rand.Intn(200))
+		o1 := FromString1(so)
+		o2 := long.FromString1(so)
+		oligo.Reverse(o2)
+		oligo.Invert(o2)
+
+		if o3 := o1.ReverseComplement(); o3.String() != o2.String() {
+			t.Fatalf("ReverseComplement() fails: %v: %v %v", so, o3, o2)
+		}
+
+		if o1.String() != so {
+			t.Fatalf("ReverseComplement() changes the oligo: %v: %v", so, o1)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	hs := make(map[uint64]string)
+	for i := 0; i < *iternum; i++ {
+		so := randomString(rand.Intn(100))
+		h := FromString1(so).Hash()
+		if s, ok := hs[h]; ok && s != so {
+			t.Fatalf("Hash() collision: %v: %v", s, so)
+		}
+
+		hs[h] = so
+	}
+}
+
+func TestDistance(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		s1 := randomString(rand.Intn(200))
+		s2 := randomString(rand.Intn(200))
+
+		d1 := oligo.Distance(long.FromString1(s1), long.FromString1(s2))
+		d2 := oligo.Distance(FromString1(s1), FromString1(s2))
+		if d1 != d2 {
+			t.Fatalf("Distance() fails: %v %v: %d %d", s1, s2, d1, d2)
+		}
+	}
+}
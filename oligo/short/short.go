@@ -197,3 +197,12 @@ func (o *Oligo) Append(other oligo.Oligo) bool {
 func (o *Oligo) Uint64() uint64 {
 	return o.seq
 }
+
+// Implementation of the oligo.Packed interface
+func (o *Oligo) Words() []uint64 {
+	if o.len == 0 {
+		return []uint64{ 0 }
+	}
+
+	return []uint64{ o.seq << uint(64 - 2*o.len) }
+}
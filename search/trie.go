@@ -0,0 +1,562 @@
+// Package search implements approximate (Levenshtein distance) search of
+// an oligo in a set of oligos. The set is stored in a trie and the search
+// walks it while updating one row of the distance matrix per level,
+// skipping the branches that can't get below the current distance limit.
+package search
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+)
+
+// how many steps the search does before checking if it should stop (the
+// time or the context)
+const CheckTimeCount = 50000
+
+type Trie struct {
+	bp		byte			// current base pair
+	depth		int			// how many levels under it
+	strand		oligo.Oligo
+	chld		[4]*Trie		// children
+}
+
+// Result of a search, the oligo and its distance to the searched one
+type DistSeq struct {
+	Seq	oligo.Oligo
+	Dist	int
+}
+
+// State of a single search
+type searcher struct {
+	strand		[]byte		// the oligo we are looking for
+	distances	[][]int		// one row of the distance matrix per trie level
+	matchseq	[]int		// nts on the path from the root to the current node
+	bporder		[]int		// order in which the nts are tried for each level (optional)
+	all		bool		// collect all matches within maxdist, not just the closest one
+	mindist		int		// stop as soon as a match closer than mindist is found
+	stoptime	int64		// time (in ms) when to stop the search, no limit if <= 0
+	ctx		context.Context	// stop the search when it is done (nil - never)
+	steps		int64		// maximum number of visited nodes, no limit if <= 0
+	count		int		// steps until the time is checked again
+	stopped		bool		// true if the search ran out of time or steps, or was canceled
+	matches		[]DistSeq	// collected matches if all is true
+}
+
+// Creates a new trie with the specified oligos
+func NewTrie(seqs []oligo.Oligo) (trie *Trie, err error) {
+	trie = new(Trie)
+	trie.bp = math.MaxUint8
+	for _, seq := range seqs {
+		err = trie.Add(seq, 0)
+		if err != nil {
+			trie = nil
+			return
+		}
+	}
+
+	return
+}
+
+func (t *Trie) add(strand oligo.Oligo, idx int) (depth int, err error) {
+	if idx == strand.Len() {
+		t.strand = strand
+		return t.depth, nil
+	}
+
+	bp := strand.At(idx)
+	if bp < 0 || bp >= len(t.chld) {
+		// no N in the trie, the searched oligos can have them
+		return 0, fmt.Errorf("invalid nt at %d: %v", idx, strand)
+	}
+
+	if t.chld[bp] == nil {
+		c := new(Trie)
+		c.bp = byte(bp)
+		t.chld[bp] = c
+	}
+
+	if d, e := t.chld[bp].add(strand, idx+1); e != nil {
+		return 0, e
+	} else {
+		if d+1 > t.depth {
+			t.depth = d + 1
+		}
+	}
+
+	return t.depth, nil
+}
+
+// Adds the part of the strand starting from idx to the trie
+func (t *Trie) Add(strand oligo.Oligo, idx int) (err error) {
+	_, err = t.add(strand, idx)
+	return
+}
+
+func (t *Trie) addClone(strand oligo.Oligo, idx int) (nt *Trie) {
+	nt = new(Trie)
+	if t != nil {
+		*nt = *t
+	}
+
+	if idx == strand.Len() {
+		nt.strand = strand
+		return nt
+	}
+
+	bp := strand.At(idx)
+	nc := nt.chld[bp].addClone(strand, idx+1)
+	nc.bp = byte(bp)
+	if nc.depth+1 > nt.depth {
+		nt.depth = nc.depth + 1
+	}
+	nt.chld[bp] = nc
+
+	return nt
+}
+
+// Returns a new trie that has the strand added. Only the nodes on the
+// path of the strand are copied, the rest are shared with the original
+// trie, so both tries can still be used after the call.
+func (t *Trie) AddClone(strand oligo.Oligo) (nt *Trie) {
+	return t.addClone(strand, 0)
+}
+
+// Use this method with care. After it is called, the trie is no longer a tree,
+// but a DAG. The trie t needs be a tree, not DAG. The method doesn't check for
+// loops and will exhaust the stack.
+// The method keeps track of up to pfxlen predecessors and appends the appropriate
+// trie from the tries array to each of the strands in t. If a strand is also a
+// prefix of other strands, the children of the appended trie are merged with
+// the existing ones.
+func (t *Trie) Concat(pfxlen int, prefix uint64, tries []*Trie) int {
+	if t == nil {
+		return 0
+	}
+
+	// first concatenate the strands below
+	t.depth = 0
+	for i := 0; i < len(t.chld); i++ {
+		if t.chld[i] != nil {
+			pfx := (prefix<<2) | uint64(i)
+			pfx &= (1<<(2*pfxlen) - 1)
+
+			d := t.chld[i].Concat(pfxlen, pfx, tries) + 1
+			if t.depth < d {
+				t.depth = d
+			}
+		}
+	}
+
+	ot := tries[prefix]
+	if t.strand != nil && ot != nil {
+		for i := 0; i < len(t.chld); i++ {
+			t.chld[i] = merge(t.chld[i], ot.chld[i])
+			if t.chld[i] != nil && t.depth < t.chld[i].depth + 1 {
+				t.depth = t.chld[i].depth + 1
+			}
+		}
+		t.strand = nil
+	}
+
+	return t.depth
+}
+
+// Appends the ot trie to all strands in the trie.
+// The same restrictions as for Concat apply.
+func (t *Trie) Append(ot *Trie) int {
+	return t.Concat(0, 0, []*Trie{ot})
+}
+
+// Returns a trie that contains the strands of both tries.
+// The tries are not modified, the nodes that are not on common paths
+// are shared with the result.
+func merge(a, b *Trie) (ret *Trie) {
+	if a == nil {
+		return b
+	} else if b == nil {
+		return a
+	}
+
+	ret = new(Trie)
+	ret.bp = a.bp
+	ret.strand = a.strand
+	if ret.strand == nil {
+		ret.strand = b.strand
+	}
+
+	for i := 0; i < len(ret.chld); i++ {
+		ret.chld[i] = merge(a.chld[i], b.chld[i])
+		if ret.chld[i] != nil && ret.depth < ret.chld[i].depth + 1 {
+			ret.depth = ret.chld[i].depth + 1
+		}
+	}
+
+	return
+}
+
+// Returns all strands in the trie with distance up to maxdist from seq
+func (t *Trie) Search(seq oligo.Oligo, maxdist int) (match []DistSeq) {
+	s := t.newSearcher(seq, false)
+	s.all = true
+	t.search(s, maxdist)
+
+	return s.matches
+}
+
+// Returns the strand with the minimum distance from seq
+func (t *Trie) SearchMin(seq oligo.Oligo) (match *DistSeq) {
+	return t.search(t.newSearcher(seq, false), -1)
+}
+
+// Returns the strand with the minimum distance from seq, giving up as soon
+// as a strand closer than mindist is found. It is useful for checking
+// whether there is a strand closer than mindist. If all strands are at least
+// mindist away, the closest one is returned.
+func (t *Trie) SearchAtLeast(seq oligo.Oligo, mindist int) (match *DistSeq) {
+	s := t.newSearcher(seq, false)
+	s.mindist = mindist
+
+	return t.search(s, -1)
+}
+
+// Returns the strand with the minimum distance to any suffix of seq
+func (t *Trie) SearchSuffix(seq oligo.Oligo) (match *DistSeq) {
+	return t.search(t.newSearcher(seq, true), -1)
+}
+
+// Returns the strand with the minimum distance from seq, trying the nts at
+// each level in the order specified by bporder (4 entries per level,
+// nil for A, T, C, G). The search gives up and returns the best match so
+// far when the time in ms reaches stoptime, or if it visits more than
+// maxsteps nodes. No limits are applied if the values are zero or negative.
+// The second return value is false if the search didn't finish.
+func (t *Trie) SearchMinLimit(seq oligo.Oligo, bporder []int, stoptime int64, maxsteps int64) (match *DistSeq, complete bool) {
+	return t.SearchMinContext(context.Background(), seq, bporder, stoptime, maxsteps)
+}
+
+// Same as SearchMinLimit, but also gives up when the context is canceled
+// or its deadline passes
+func (t *Trie) SearchMinContext(ctx context.Context, seq oligo.Oligo, bporder []int, stoptime int64, maxsteps int64) (match *DistSeq, complete bool) {
+	s := t.newSearcher(seq, false)
+	s.bporder = bporder
+	s.stoptime = stoptime
+	s.steps = maxsteps
+	if ctx.Err() != nil {
+		return nil, false
+	}
+
+	if ctx.Done() != nil {
+		// the background context can't be canceled, don't check it
+		s.ctx = ctx
+	}
+
+	match = t.search(s, -1)
+	complete = !s.stopped
+	return
+}
+
+func (t *Trie) newSearcher(seq oligo.Oligo, suffix bool) (s *searcher) {
+	var strand []byte
+
+	// utils.Oligo and the like wrap the actual oligo
+	if s, ok := seq.(interface{ Oligo() oligo.Oligo }); ok {
+		seq = s.Oligo()
+	}
+
+	if s, ok := seq.(*long.Oligo); ok {
+		strand = s.Bytes()
+	} else {
+		strand = make([]byte, seq.Len())
+		for i := 0; i < len(strand); i++ {
+			strand[i] = byte(seq.At(i))
+		}
+	}
+
+	s = new(searcher)
+	s.strand = strand
+	s.distances = make([][]int, t.depth + 1)
+	s.distances[0] = make([]int, len(strand) + 1)
+	if !suffix {
+		for i := 0; i < len(s.distances[0]); i++ {
+			s.distances[0][i] = i
+		}
+	}
+
+	s.matchseq = make([]int, t.depth + 1)
+	s.count = CheckTimeCount
+	return
+}
+
+// If maxdist is negative, looks for the minimum distance
+func (t *Trie) search(s *searcher, maxdist int) (match *DistSeq) {
+	if maxdist < 0 {
+		maxdist = len(s.strand)
+	} else {
+		// searchRecursive looks for distances strictly less than maxdist
+		maxdist++
+	}
+
+	for n := 0; n < len(t.chld); n++ {
+		i := n
+		if s.bporder != nil {
+			i = s.bporder[n]
+		}
+
+		c := t.chld[i]
+		if c == nil {
+			continue
+		}
+
+		m := c.searchRecursive(s, i, 0, maxdist)
+		if m != nil {
+			match = m
+			if !s.all {
+				maxdist = m.Dist
+			}
+		}
+
+		if s.stopped {
+			break
+		}
+	}
+
+	return
+}
+
+// Checks if the search should stop because it ran out of steps or time
+func (s *searcher) stop() bool {
+	if s.stopped {
+		return true
+	}
+
+	if s.steps > 0 {
+		s.steps--
+		if s.steps == 0 {
+			s.stopped = true
+			return true
+		}
+	}
+
+	if s.stoptime > 0 || s.ctx != nil {
+		if s.count <= 0 {
+			// we might be over the limit, check
+			if s.stoptime > 0 && time.Now().UnixMilli() >= s.stoptime {
+				s.stopped = true
+				return true
+			}
+
+			if s.ctx != nil && s.ctx.Err() != nil {
+				s.stopped = true
+				return true
+			}
+
+			// not over the limit, reset the count and keep going
+			s.count = CheckTimeCount
+		}
+		s.count--
+	}
+
+	return false
+}
+
+func newSeq(mseq []int) (ol oligo.Oligo) {
+	ol = long.New(len(mseq))
+	for i, nt := range mseq {
+		ol.Set(i, nt)
+	}
+
+	return
+}
+
+// Looks for strands with distance less than maxdist
+func (t *Trie) searchRecursive(s *searcher, idx int, didx int, maxdist int) (match *DistSeq) {
+	if s.stop() {
+		return
+	}
+
+	strand := s.strand
+	colnum := len(strand) + 1
+
+	// Build one row for the letter, with a column for each letter in the target
+	// word, plus one for the empty string at column 0
+	previousRow := s.distances[didx]
+	currentRow := s.distances[didx + 1]
+	if currentRow == nil {
+		currentRow = make([]int, colnum)
+		s.distances[didx + 1] = currentRow
+	}
+
+	s.matchseq[didx] = idx
+	currentRow[0] = previousRow[0] + 1
+	rowMin := currentRow[0]
+	for col := 1; col < colnum; col++ {
+		insertCost := currentRow[col - 1] + 1
+		deleteCost := previousRow[col] + 1
+		replaceCost := previousRow[col - 1]
+		if strand[col - 1] != byte(idx) {
+			replaceCost++
+		}
+
+		minCost := insertCost
+		if minCost > deleteCost {
+			minCost = deleteCost
+		}
+		if minCost > replaceCost {
+			minCost = replaceCost
+		}
+
+		currentRow[col] = minCost
+		if rowMin > minCost {
+			rowMin = minCost
+		}
+	}
+
+	// if the last entry in the row indicates the optimal cost is less than the
+	// maximum cost, and there is a word in this trie node, then add it.
+	if d := currentRow[colnum - 1]; d < maxdist && t.strand != nil {
+		match = &DistSeq{ newSeq(s.matchseq[0:didx + 1]), d }
+		if s.all {
+			s.matches = append(s.matches, *match)
+		} else {
+			maxdist = d
+			if d < s.mindist {
+				s.stopped = true
+				return
+			}
+		}
+	}
+
+	// if any entries in the row are less than the maximum cost, then
+	// recursively search each branch of the trie
+	if rowMin < maxdist {
+		var bpo [4]int
+
+		didx++
+		bpord := []int{ 0, 1, 2, 3 }
+		n := didx*len(t.chld)
+		if n < len(s.bporder) {
+			bpord = s.bporder[n:n+4]
+		}
+
+		// try the nt that matches the strand first, it is most likely
+		// to lower maxdist and prune the rest of the branches. N
+		// doesn't match any of them.
+		if didx < len(strand) && int(strand[didx]) < len(t.chld) {
+			cidx := int(strand[didx])
+			bpo[0] = cidx
+			n := 1
+			for _, i := range bpord {
+				if i != cidx {
+					bpo[n] = i
+					n++
+				}
+			}
+		} else {
+			copy(bpo[:], bpord)
+		}
+
+		for n := 0; n < len(t.chld); n++ {
+			i := bpo[n]
+			c := t.chld[i]
+			if c == nil {
+				continue
+			}
+
+			if m := c.searchRecursive(s, i, didx, maxdist); m != nil {
+				match = m
+				if !s.all {
+					maxdist = m.Dist
+				}
+			}
+
+			if s.stopped {
+				break
+			}
+		}
+	}
+
+	return match
+}
+
+// Returns the number of nodes in the trie
+func (t *Trie) Size() (sz int) {
+	t.visit(make(map[*Trie]bool), true, 0, func(tt *Trie, n float64) float64 {
+		sz++
+		return 0
+	})
+
+	return
+}
+
+// Returns the number of nodes for each depth, counting the shared
+// nodes once for each path they are on
+func (root *Trie) SizeByDepth() (ret map[int]float64) {
+	ret = make(map[int]float64)
+
+	// first count how many times a node is referenced by other nodes
+	tcnt := make(map[*Trie] uint64)
+	root.visit(make(map[*Trie]bool), false, 0, func(t *Trie, n float64) float64 {
+		tcnt[t]++
+		return 0
+	})
+
+	// then visit each node again, but this time use the counts from before
+	// to calculate the real number of nodes for each depth
+	root.visit(make(map[*Trie]bool), true, 1, func(t *Trie, n float64) float64 {
+		n *= float64(tcnt[t])
+		ret[t.depth] += float64(n)
+		return n
+	})
+
+
+	return
+}
+
+// calls the specified function once for each node in the trie
+// uses the tmap parameter to keep track of the visited nodes
+func (t *Trie) visit(tmap map[*Trie]bool, once bool, n float64, v func(t *Trie, n float64) float64) {
+	if t == nil {
+		return
+	}
+
+	if tmap[t] {
+		// if the once flag is true, visit each reference of the node
+		// but not its children
+		if !once {
+			v(t, n)
+		}
+		return
+	}
+
+	tmap[t] = true
+	m := v(t, n)
+	for _, c := range t.chld {
+		c.visit(tmap, once, m, v)
+	}
+
+	return
+}
+
+func (t *Trie) Depth() int {
+	return t.depth
+}
+
+func (t *Trie) Clone() (ret *Trie) {
+	if t == nil {
+		return nil
+	}
+
+	ret = new(Trie)
+	ret.bp = t.bp
+	ret.depth = t.depth
+	ret.strand = t.strand
+	for i := 0; i < len(t.chld); i++ {
+		ret.chld[i] = t.chld[i].Clone()
+	}
+
+	return
+}
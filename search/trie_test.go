@@ -0,0 +1,231 @@
+package search
+
+import (
+	"context"
+	"flag"
+	"math/rand"
+	"os"
+	"testing"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+	"adscodex/oligo/short"
+)
+
+var iternum = flag.Int("n", 20, "number of iterations")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+func randomOligo(l int) oligo.Oligo {
+	o := long.New(l)
+	for i := 0; i < l; i++ {
+		o.Set(i, rand.Intn(4))
+	}
+
+	return o
+}
+
+// introduces up to n random errors in the oligo
+func mutate(o oligo.Oligo, n int) oligo.Oligo {
+	s := []byte(o.String())
+	for i := 0; i < n && len(s) > 1; i++ {
+		p := rand.Intn(len(s))
+		nt := oligo.Nt2String(rand.Intn(4))[0]
+		switch rand.Intn(3) {
+		case 0:
+			s[p] = nt
+		case 1:
+			s = append(s[0:p], s[p+1:]...)
+		case 2:
+			s = append(s[0:p], append([]byte{nt}, s[p:]...)...)
+		}
+	}
+
+	return long.FromString1(string(s))
+}
+
+func randomSet(n, l int) (ols []oligo.Oligo) {
+	for i := 0; i < n; i++ {
+		ols = append(ols, randomOligo(l))
+	}
+
+	return
+}
+
+func minDist(ols []oligo.Oligo, o oligo.Oligo) (min int) {
+	min = -1
+	for _, ol := range ols {
+		if d := oligo.Distance(ol, o); min < 0 || d < min {
+			min = d
+		}
+	}
+
+	return
+}
+
+func TestSearch(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		ols := randomSet(200, 16)
+		trie, _ := NewTrie(ols)
+
+		o := mutate(ols[rand.Intn(len(ols))], 3)
+		ms := trie.Search(o, 4)
+
+		n := 0
+		for _, ol := range ols {
+			if oligo.Distance(ol, o) <= 4 {
+				n++
+			}
+		}
+
+		if n != len(ms) {
+			t.Fatalf("%v: expected %d matches got %d", o, n, len(ms))
+		}
+
+		for _, m := range ms {
+			if d := oligo.Distance(m.Seq, o); d != m.Dist || d > 4 {
+				t.Fatalf("%v: invalid match %v: %d %d", o, m.Seq, m.Dist, d)
+			}
+		}
+	}
+}
+
+func TestSearchMin(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		ols := randomSet(200, 16)
+		trie, _ := NewTrie(ols)
+
+		o := mutate(ols[rand.Intn(len(ols))], 5)
+		m := trie.SearchMin(o)
+		if m == nil {
+			t.Fatalf("%v: no match", o)
+		}
+
+		if d := minDist(ols, o); d != m.Dist || oligo.Distance(m.Seq, o) != d {
+			t.Fatalf("%v: expected distance %d got %v %d", o, d, m.Seq, m.Dist)
+		}
+	}
+}
+
+func TestSearchN(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		ols := randomSet(200, 16)
+		trie, _ := NewTrie(ols)
+
+		// N doesn't match any nt in the trie
+		s := []byte(ols[rand.Intn(len(ols))].String())
+		for j := 0; j < 3; j++ {
+			s[rand.Intn(len(s))] = 'N'
+		}
+
+		o, _ := long.FromStringN(string(s))
+		m := trie.SearchMin(o)
+		if m == nil {
+			t.Fatalf("%v: no match", o)
+		}
+
+		if d := minDist(ols, o); d != m.Dist || oligo.Distance(m.Seq, o) != d {
+			t.Fatalf("%v: expected distance %d got %v %d", o, d, m.Seq, m.Dist)
+		}
+	}
+
+	on, _ := long.FromStringN("ACNT")
+	if _, err := NewTrie([]oligo.Oligo{long.FromString1("ACGT"), on}); err == nil {
+		t.Fatalf("oligo with N added to the trie")
+	}
+}
+
+func TestSearchAtLeast(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		ols := randomSet(100, 16)
+		trie, _ := NewTrie(ols)
+
+		o := randomOligo(16)
+		d := minDist(ols, o)
+		m := trie.SearchAtLeast(o, d)
+		if m == nil || m.Dist != d {
+			t.Fatalf("%v: expected distance %d got %v", o, d, m)
+		}
+
+		m = trie.SearchAtLeast(o, d + 1)
+		if m == nil || m.Dist > d {
+			t.Fatalf("%v: expected distance up to %d got %v", o, d, m)
+		}
+	}
+}
+
+func TestSearchSuffix(t *testing.T) {
+	for i := 0; i < *iternum; i++ {
+		ols := randomSet(100, 12)
+		trie, _ := NewTrie(ols)
+
+		sfx := ols[rand.Intn(len(ols))]
+		o := randomOligo(20)
+		o.Append(sfx)
+
+		m := trie.SearchSuffix(o)
+		if m == nil || m.Dist != 0 {
+			t.Fatalf("%v: suffix %v not found: %v", o, sfx, m)
+		}
+	}
+}
+
+func TestSearchMinLimit(t *testing.T) {
+	ols := randomSet(1000, 20)
+	trie, _ := NewTrie(ols)
+
+	o := randomOligo(20)
+	m, complete := trie.SearchMinLimit(o, nil, 0, 0)
+	if !complete || m == nil || m.Dist != minDist(ols, o) {
+		t.Fatalf("%v: unlimited search failed: %v %v", o, m, complete)
+	}
+
+	_, complete = trie.SearchMinLimit(o, nil, 0, 10)
+	if complete {
+		t.Fatalf("%v: search with 10 steps completed", o)
+	}
+}
+
+func TestSearchMinContext(t *testing.T) {
+	ols := randomSet(1000, 20)
+	trie, _ := NewTrie(ols)
+
+	o := randomOligo(20)
+	m, complete := trie.SearchMinContext(context.Background(), o, nil, 0, 0)
+	if !complete || m == nil || m.Dist != minDist(ols, o) {
+		t.Fatalf("%v: search failed: %v %v", o, m, complete)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, complete = trie.SearchMinContext(ctx, o, nil, 0, 0); complete {
+		t.Fatalf("%v: canceled search completed", o)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	// strands of different lengths, some of them prefixes of others
+	pfxs := []oligo.Oligo{ long.FromString1("AC"), long.FromString1("ACG"), long.FromString1("TT") }
+	sfxs := []oligo.Oligo{ long.FromString1("GA"), long.FromString1("CC") }
+
+	trie, _ := NewTrie(pfxs)
+	st, _ := NewTrie(sfxs)
+	trie.Append(st)
+
+	for _, p := range pfxs {
+		for _, s := range sfxs {
+			o, _ := short.Copy(p)
+			o.Append(s)
+			if m := trie.SearchMin(o); m == nil || m.Dist != 0 {
+				t.Fatalf("%v not found in concatenated trie: %v", o, m)
+			}
+		}
+	}
+
+	// the original prefixes are not in the trie anymore
+	if m := trie.SearchMin(long.FromString1("TT")); m != nil && m.Dist == 0 {
+		t.Fatalf("TT found after concatenation: %v", m)
+	}
+}
@@ -0,0 +1,598 @@
+// Package seqio reads sequencing data in the formats supported by
+// ADS Codex (CSV, FASTA, FASTQ, and SAM), optionally compressed with
+// gzip or bzip2. The format and the compression are detected
+// automatically, the data can be read from any io.Reader (including
+// stdin).
+package seqio
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+	"adscodex/oligo/packed"
+)
+
+// Format of the sequencing data
+type Format int
+
+const (
+	Auto Format = iota	// detect the format from the data
+	CSV			// sequence and id, separated by comma or space, one per line
+	FASTA
+	FASTQ
+	SAM
+)
+
+// Orientation of a read
+type Orientation int
+
+const (
+	Forward Orientation = iota
+	Reverse			// the read should be reverse-complemented
+	Unknown			// the read can be either forward or reverse
+)
+
+// Single read from the sequencing data
+type Record struct {
+	Id		string
+	Sequence	string
+	Quality		[]byte		// Phred quality scores, nil if not available
+	Orientation	Orientation
+	Line		int		// line where the record starts in the input
+}
+
+// Interface for reading sequencing data record by record
+type SequenceReader interface {
+	// Returns the next record, or io.EOF if there are no more records
+	Read() (*Record, error)
+
+	// Format of the data
+	Format() Format
+}
+
+// Reader that implements the SequenceReader interface for all
+// supported formats
+type Reader struct {
+	r	*bufio.Reader
+	format	Format
+	line	int		// number of lines read so far
+	next	string		// line read ahead (FASTA)
+	hasNext	bool
+	closer	io.Closer
+}
+
+// Number of bytes used for detecting the format
+const detectSize = 64*1024
+
+var formatNames = map[string]Format {
+	"auto": Auto,
+	"csv": CSV,
+	"fasta": FASTA,
+	"fastq": FASTQ,
+	"sam": SAM,
+}
+
+// Converts the name of a format (auto, csv, fasta, fastq, or sam) to Format
+func ParseFormatName(name string) (Format, error) {
+	if f, ok := formatNames[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+
+	return Auto, fmt.Errorf("unsupported file type: %s", name)
+}
+
+func (f Format) String() string {
+	for n, ff := range formatNames {
+		if ff == f {
+			return n
+		}
+	}
+
+	return "unknown"
+}
+
+// Creates a new reader. The compression and the format of the data are
+// detected automatically.
+func NewReader(r io.Reader) (*Reader, error) {
+	return NewFormatReader(r, Auto)
+}
+
+// Creates a new reader for data in the specified format. The compression
+// is detected automatically.
+func NewFormatReader(r io.Reader, f Format) (rd *Reader, err error) {
+	br := bufio.NewReaderSize(r, detectSize)
+	magic, _ := br.Peek(3)
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		var gr *gzip.Reader
+
+		gr, err = gzip.NewReader(br)
+		if err != nil {
+			return
+		}
+		br = bufio.NewReaderSize(gr, detectSize)
+
+	case len(magic) == 3 && string(magic) == "BZh":
+		br = bufio.NewReaderSize(bzip2.NewReader(br), detectSize)
+	}
+
+	if f == Auto {
+		buf, _ := br.Peek(detectSize)
+		f = detectFormat(buf)
+	}
+
+	rd = &Reader{r: br, format: f}
+	return
+}
+
+// Opens the file and creates a reader for it. If the file name is "-",
+// reads from stdin.
+func Open(fname string) (*Reader, error) {
+	return OpenFormat(fname, Auto)
+}
+
+// Same as Open, but for data in the specified format
+func OpenFormat(fname string, format Format) (rd *Reader, err error) {
+	var f *os.File
+
+	if fname == "-" {
+		f = os.Stdin
+	} else {
+		f, err = os.Open(fname)
+		if err != nil {
+			return
+		}
+	}
+
+	rd, err = NewFormatReader(f, format)
+	if err != nil {
+		f.Close()
+		return
+	}
+
+	if f != os.Stdin {
+		rd.closer = f
+	}
+
+	return
+}
+
+// Guesses the format of the data from the first few lines
+func detectFormat(buf []byte) Format {
+	buf = bytes.TrimLeft(buf, " \t\r\n")
+	if len(buf) == 0 {
+		return CSV
+	}
+
+	line := buf
+	if n := bytes.IndexByte(buf, '\n'); n >= 0 {
+		line = buf[0:n]
+	}
+
+	switch buf[0] {
+	case '>', ';':
+		return FASTA
+
+	case '@':
+		// SAM header lines start with @ too
+		if len(line) > 3 && line[3] == '\t' {
+			switch string(line[1:3]) {
+			case "HD", "SQ", "RG", "PG", "CO":
+				return SAM
+			}
+		}
+
+		return FASTQ
+	}
+
+	if len(bytes.Split(line, []byte{'\t'})) >= 11 {
+		return SAM
+	}
+
+	return CSV
+}
+
+func (r *Reader) Format() Format {
+	return r.format
+}
+
+// Closes the underlying file (if the reader was created by Open)
+func (r *Reader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+
+	return nil
+}
+
+// Returns the next line without the end of line characters
+func (r *Reader) readLine() (string, error) {
+	if r.hasNext {
+		r.hasNext = false
+		return r.next, nil
+	}
+
+	l, err := r.r.ReadString('\n')
+	if err == io.EOF && l != "" {
+		err = nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	r.line++
+	return strings.TrimRight(l, "\r\n"), nil
+}
+
+func (r *Reader) unreadLine(l string) {
+	r.next = l
+	r.hasNext = true
+}
+
+func (r *Reader) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("line %d: %s", r.line, fmt.Sprintf(format, a...))
+}
+
+func (r *Reader) Read() (rec *Record, err error) {
+	switch r.format {
+	default:
+		err = fmt.Errorf("unsupported format: %v", r.format)
+
+	case CSV:
+		rec, err = r.readCSV()
+
+	case FASTA:
+		rec, err = r.readFASTA()
+
+	case FASTQ:
+		rec, err = r.readFASTQ()
+
+	case SAM:
+		rec, err = r.readSAM()
+	}
+
+	return
+}
+
+func (r *Reader) readCSV() (*Record, error) {
+	for {
+		l, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if l == "" {
+			continue
+		}
+
+		ls := strings.Split(l, ",")
+		if len(ls) == 1 {
+			ls = strings.Split(l, " ")
+		}
+
+		rec := &Record{Sequence: ls[0], Line: r.line}
+		if len(ls) > 1 {
+			rec.Id = ls[1]
+		}
+
+		return rec, nil
+	}
+}
+
+func (r *Reader) readFASTA() (rec *Record, err error) {
+	var seq strings.Builder
+	var l string
+
+	for rec == nil {
+		l, err = r.readLine()
+		if err != nil {
+			return
+		}
+
+		l = strings.TrimSpace(l)
+		if l == "" || l[0] == ';' {
+			continue
+		}
+
+		if l[0] != '>' {
+			return nil, r.errorf("expecting description line: '%s'", l)
+		}
+
+		rec = &Record{Line: r.line}
+		if ls := strings.Fields(l[1:]); len(ls) > 0 {
+			rec.Id = ls[0]
+		}
+	}
+
+	// the sequence can span multiple lines
+	for {
+		l, err = r.readLine()
+		if err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		l = strings.TrimSpace(l)
+		if l != "" && l[0] == '>' {
+			r.unreadLine(l)
+			break
+		}
+
+		if l != "" && l[0] != ';' {
+			seq.WriteString(strings.ToUpper(l))
+		}
+	}
+
+	rec.Sequence = seq.String()
+	return
+}
+
+func (r *Reader) readFASTQ() (rec *Record, err error) {
+	var l string
+
+	// id line
+	for l == "" {
+		l, err = r.readLine()
+		if err != nil {
+			return
+		}
+	}
+
+	if l[0] != '@' {
+		return nil, r.errorf("invalid id line: '%s'", l)
+	}
+
+	rec = &Record{Line: r.line}
+	ls := strings.Fields(l[1:])
+	if len(ls) == 0 {
+		return nil, r.errorf("invalid id line: '%s'", l)
+	}
+
+	rec.Id = ls[0]
+
+	// This is sequencer specific. For Illumina it finds out the forward/reverse
+	// read based on the first character of the second field of the ID line
+	// (or the /1 or /2 suffix of the ID in the older formats). If it is '1'
+	// the read is forward, if it is '2', the read is reverse.
+	// If it is neither (hopefully all other sequencers), we treat the read as
+	// BOTH forward and reverse.
+	rec.Orientation = Unknown
+	flag := byte(0)
+	if len(ls) > 1 {
+		flag = ls[1][0]
+	} else if n := len(rec.Id); n > 2 && rec.Id[n-2] == '/' {
+		flag = rec.Id[n-1]
+	}
+
+	switch flag {
+	case '1':
+		rec.Orientation = Forward
+	case '2':
+		rec.Orientation = Reverse
+	}
+
+	// sequence
+	rec.Sequence, err = r.readLine()
+	if err == io.EOF {
+		return nil, r.errorf("expecting DNA sequence")
+	} else if err != nil {
+		return nil, err
+	}
+
+	// '+' line
+	l, err = r.readLine()
+	if err == io.EOF || (err == nil && (l == "" || l[0] != '+')) {
+		return nil, r.errorf("expecting '+' line")
+	} else if err != nil {
+		return nil, err
+	}
+
+	// quality
+	qual, err := r.readLine()
+	if err == io.EOF {
+		return nil, r.errorf("expecting quality line")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(qual) != len(rec.Sequence) {
+		return nil, r.errorf("lengths of sequence and quality lines differ: %d:%d", len(rec.Sequence), len(qual))
+	}
+
+	rec.Quality = make([]byte, len(qual))
+	for i := 0; i < len(qual); i++ {
+		rec.Quality[i] = qual[i] - 33 // '!'
+	}
+
+	return
+}
+
+// SAM flags
+const (
+	samUnmapped	= 0x4
+	samReverse	= 0x10
+	samSecondary	= 0x100
+	samSupplementary = 0x800
+)
+
+func (r *Reader) readSAM() (*Record, error) {
+	for {
+		l, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if l == "" || l[0] == '@' {
+			continue
+		}
+
+		ls := strings.Split(l, "\t")
+		if len(ls) < 11 {
+			return nil, r.errorf("expecting at least 11 fields, got %d", len(ls))
+		}
+
+		flag, err := strconv.ParseUint(ls[1], 10, 16)
+		if err != nil {
+			return nil, r.errorf("invalid flag: %v", ls[1])
+		}
+
+		// each read should be returned only once
+		if flag & (samSecondary | samSupplementary) != 0 || ls[9] == "*" {
+			continue
+		}
+
+		// the sequence of the mapped reads is in the orientation of the
+		// reference (already reverse-complemented if needed)
+		rec := &Record{Id: ls[0], Sequence: strings.ToUpper(ls[9]), Line: r.line}
+		if flag & samUnmapped != 0 {
+			rec.Orientation = Unknown
+		}
+
+		if ls[10] != "*" {
+			if len(ls[10]) != len(ls[9]) {
+				return nil, r.errorf("lengths of sequence and quality differ: %d:%d", len(ls[9]), len(ls[10]))
+			}
+
+			rec.Quality = make([]byte, len(ls[10]))
+			for i := 0; i < len(ls[10]); i++ {
+				rec.Quality[i] = ls[10][i] - 33
+			}
+		}
+
+		return rec, nil
+	}
+}
+
+// Parses the file (auto-detecting the format), calling the process
+// function for each read. The reads with unknown orientation are
+// processed twice, as forward and as reverse.
+func Parse(fname string, process func(id, sequence string, quality []byte, reverse bool) error) error {
+	return ParseFormat(fname, Auto, process)
+}
+
+// Same as Parse, for data in the specified format
+func ParseFormat(fname string, format Format, process func(id, sequence string, quality []byte, reverse bool) error) error {
+	return ParseRecords(fname, format, func(rec *Record) (err error) {
+		if rec.Orientation != Reverse {
+			if err = process(rec.Id, rec.Sequence, rec.Quality, false); err != nil {
+				return
+			}
+		}
+
+		if rec.Orientation != Forward {
+			err = process(rec.Id, rec.Sequence, rec.Quality, true)
+		}
+
+		return
+	})
+}
+
+// Parses the file, calling the process function once for each record
+// (regardless of its orientation)
+func ParseRecords(fname string, format Format, process func(rec *Record) error) (err error) {
+	r, err := OpenFormat(fname, format)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for {
+		var rec *Record
+
+		rec, err = r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", fname, err)
+		}
+
+		if err = process(rec); err != nil {
+			return
+		}
+	}
+}
+
+// Returns a parse function (same as Parse) for the specified format
+func Parser(format Format) func(string, func(id, sequence string, quality []byte, reverse bool) error) error {
+	return func(fname string, process func(id, sequence string, quality []byte, reverse bool) error) error {
+		return ParseFormat(fname, format, process)
+	}
+}
+
+// Reads the oligos from the file. The sequences with unknown nts (N)
+// are handled according to the policy, the invalid sequences are
+// skipped if ignoreBad is true, otherwise they cause an error.
+func Read(fname string, format Format, ignoreBad bool, np oligo.NPolicy) ([]oligo.Oligo, error) {
+	return ReadFunc(Parser(format), fname, ignoreBad, np)
+}
+
+// Same as Read, but returns packed oligos (2 bits per nt). The sequences
+// with unknown nts kept by the policy are returned as long oligos.
+func ReadPacked(fname string, format Format, ignoreBad bool, np oligo.NPolicy) ([]oligo.Oligo, error) {
+	return ReadFuncPacked(Parser(format), fname, ignoreBad, np)
+}
+
+// Same as Read, but uses the specified parse function (for example one
+// that merges paired-end reads)
+func ReadFunc(parse func(string, func(id, sequence string, quality []byte, reverse bool) error) error, fname string, ignoreBad bool, np oligo.NPolicy) ([]oligo.Oligo, error) {
+	return read(parse, fname, ignoreBad, np, func(s string) (oligo.Oligo, bool) {
+		return long.FromString(s)
+	})
+}
+
+// Same as ReadFunc, but returns packed oligos
+func ReadFuncPacked(parse func(string, func(id, sequence string, quality []byte, reverse bool) error) error, fname string, ignoreBad bool, np oligo.NPolicy) ([]oligo.Oligo, error) {
+	return read(parse, fname, ignoreBad, np, func(s string) (oligo.Oligo, bool) {
+		return packed.FromString(s)
+	})
+}
+
+func read(parse func(string, func(id, sequence string, quality []byte, reverse bool) error) error, fname string, ignoreBad bool, np oligo.NPolicy, fromString func(string) (oligo.Oligo, bool)) ([]oligo.Oligo, error) {
+	var oligos []oligo.Oligo
+
+	err := parse(fname, func(id, sequence string, quality []byte, reverse bool) error {
+		ol, ok := fromString(sequence)
+		if !ok && strings.IndexByte(sequence, 'N') >= 0 {
+			if np == oligo.NDrop {
+				return nil
+			}
+
+			ol, ok = long.FromStringN(sequence)
+		}
+
+		if !ok {
+			if ignoreBad {
+				// skip
+				return nil
+			} else {
+				return fmt.Errorf("invalid oligo: %s\n", sequence)
+			}
+		}
+
+		if reverse {
+			if p, ok := ol.(*packed.Oligo); ok {
+				p.Reverse()
+				p.Invert()
+			} else {
+				oligo.Reverse(ol)
+				oligo.Invert(ol)
+			}
+		}
+
+		oligos = append(oligos, ol)
+		return nil
+	})
+
+	return oligos, err
+}
@@ -0,0 +1,90 @@
+package seqio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, data []byte) (format Format, recs []*Record) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+
+		recs = append(recs, rec)
+	}
+
+	return r.Format(), recs
+}
+
+func TestDetect(t *testing.T) {
+	long := strings.Repeat("ACGT", 50000)
+	tests := []struct {
+		data	string
+		format	Format
+		seqs	[]string
+	} {
+		{ "ACGT,id1\nTTTT,id2\n", CSV, []string{ "ACGT", "TTTT" } },
+		{ ">id1 desc\nACGT\nacgt\n\n>id2\nTTTT", FASTA, []string{ "ACGTACGT", "TTTT" } },
+		{ "@id1 1:N:0\nACGT\n+\nIIII\n@id2\nTTTT\n+\nIIII\n", FASTQ, []string{ "ACGT", "TTTT" } },
+		{ "@HD\tVN:1.6\nr1\t0\tref\t1\t60\t4M\t*\t0\t0\tACGT\tIIII\nr2\t256\tref\t1\t60\t4M\t*\t0\t0\tACGT\tIIII\n", SAM, []string{ "ACGT" } },
+		{ "@id1\n" + long + "\n+\n" + strings.Repeat("I", len(long)) + "\n", FASTQ, []string{ long } },
+	}
+
+	for _, tst := range tests {
+		for _, compress := range []bool{ false, true } {
+			data := []byte(tst.data)
+			if compress {
+				var b bytes.Buffer
+				w := gzip.NewWriter(&b)
+				w.Write(data)
+				w.Close()
+				data = b.Bytes()
+			}
+
+			f, recs := readAll(t, data)
+			if f != tst.format {
+				t.Fatalf("format detected as %v, expected %v", f, tst.format)
+			}
+
+			if len(recs) != len(tst.seqs) {
+				t.Fatalf("%v: %d records, expected %d", f, len(recs), len(tst.seqs))
+			}
+
+			for i, rec := range recs {
+				if rec.Sequence != tst.seqs[i] {
+					t.Fatalf("%v: sequence %d differs", f, i)
+				}
+			}
+		}
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	_, recs := readAll(t, []byte("@a 1:N\nA\n+\nI\n@b 2:N\nA\n+\nI\n@c\nA\n+\nI\n@d/2\nA\n+\nI\n"))
+	exp := []Orientation{ Forward, Reverse, Unknown, Reverse }
+	for i, rec := range recs {
+		if rec.Orientation != exp[i] {
+			t.Fatalf("record %s orientation %v, expected %v", rec.Id, rec.Orientation, exp[i])
+		}
+	}
+}
+
+func TestErrorLine(t *testing.T) {
+	r, _ := NewReader(strings.NewReader("@a 1\nACGT\n+\nIIII\n@b 1\nACGT\n+\nIII\n"))
+	r.Read()
+	if _, err := r.Read(); err == nil || !strings.HasPrefix(err.Error(), "line 8:") {
+		t.Fatalf("expecting error at line 8, got %v", err)
+	}
+}
@@ -6,8 +6,7 @@ import (
 	"os"
 	"runtime"
 	"sort"
-	"adscodex/io/csv"
-	"adscodex/io/fastq"
+	"adscodex/seqio"
 	"adscodex/oligo"
 	"adscodex/oligo/long"
 	"adscodex/utils"
@@ -19,7 +18,7 @@ var datasetFile = flag.String("ds", "", "synthesis dataset file")
 var debug = flag.Bool("debug", false, "debug")
 var p5 = flag.String("p5", "", "5'-end primer")
 var p3 = flag.String("p3", "", "3'-end primer")
-var ftype = flag.String("ft", "fastq", "file type (csv or fastq)")
+var ftype = flag.String("ft", "auto", "file type (auto, csv, fasta, fastq, or sam)")
 
 type ClusterStat struct {
 	cid		int
@@ -37,7 +36,7 @@ func main() {
 	flag.Parse()
 
 	if *datasetFile != "" {
-		dspool, err = utils.ReadPool([]string { *datasetFile }, false, csv.Parse)
+		dspool, err = utils.ReadPool([]string { *datasetFile }, false, seqio.Parse)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			return
@@ -50,15 +49,9 @@ func main() {
 	}
 
 	fmt.Fprintf(os.Stderr, "Reading files %v...\n", fns)
-	switch *ftype {
-	default:
-		err = fmt.Errorf("invalid file type: %v\n", *ftype)
-
-	case "csv":
-		pool, err =  utils.ReadPool(fns, true, csv.Parse)
-
-	case "fastq":
-		pool, err =  utils.ReadPool(fns, true, fastq.Parse)
+	format, err := seqio.ParseFormatName(*ftype)
+	if err == nil {
+		pool, err = utils.ReadPool(fns, true, seqio.Parser(format))
 	}
 
 	if err != nil {
@@ -14,8 +14,7 @@ import (
 	"adscodex/oligo"
 	"adscodex/oligo/short"
 	"adscodex/oligo/long"
-	"adscodex/io/fastq"
-	"adscodex/io/csv"
+	"adscodex/seqio"
 	"adscodex/criteria"
 	"adscodex/utils"
 )
@@ -49,7 +48,7 @@ type Seq struct {
 	rev	bool
 }
 
-var ftype = flag.String("t", "fastq", "file type")
+var ftype = flag.String("t", "auto", "file type (auto, csv, fasta, fastq, or sam)")
 var klen = flag.Int("k", 31, "kmer length")
 var profname = flag.String("prof", "", "profile filename")
 var noligos = flag.Int("n", 0, "number of oligos")
@@ -352,19 +351,14 @@ func main() {
                 }
 */
 
-		var err error
-		switch (*ftype) {
-		default:
-			fmt.Fprintf(os.Stderr, "Error: invalid file type: %s\n", *ftype)
+		format, err := seqio.ParseFormatName(*ftype)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			return
-
-		case "csv":
-			err = csv.Parse(fname, fproc)
-
-		case "fastq":
-			err = fastq.Parse(fname, fproc)
 		}
 
+		err = seqio.ParseFormat(fname, format, fproc)
+
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"math"
+	"adscodex/oligo"
+)
+
+// Edit distance from an oligo to the rest of the pool
+type DistStat struct {
+	Oligo	*Oligo
+	MinDist	int		// distance to the closest oligos
+	MinOls	[]oligo.Oligo	// the closest oligos
+	AvgDist	float64		// average distance to the other oligos
+}
+
+// Calculates the distances from each of the specified oligos (all
+// oligos in the pool if ols is nil) to the rest of the pool. The progress
+// function (if not nil) is called after each oligo, from multiple
+// goroutines.
+func (p *Pool) Distances(ols []*Oligo, progress func()) (stats []DistStat) {
+	if ols == nil {
+		ols = p.oligos
+	}
+
+	src := &Pool{oligos: ols}
+	done := make(chan []DistStat)
+	nprocs := src.Parallel(256, func(seqs []*Oligo) {
+		var stats []DistStat
+
+		for _, s1 := range seqs {
+			var avgdist float64
+			var mdols []oligo.Oligo
+
+			mindist := int(math.MaxInt32)
+			for _, s2 := range p.oligos {
+				if s1 == s2 {
+					continue
+				}
+
+				d := oligo.Distance(s1, s2)
+				if d < mindist {
+					mindist = d
+					mdols = append([]oligo.Oligo(nil), s2)
+				} else if d == mindist {
+					mdols = append(mdols, s2)
+				}
+
+				avgdist += float64(d)
+			}
+
+			if progress != nil {
+				progress()
+			}
+
+			if len(p.oligos) > 1 {
+				avgdist /= float64(len(p.oligos) - 1)
+			}
+
+			stats = append(stats, DistStat{s1, mindist, mdols, avgdist})
+		}
+
+		done <- stats
+	})
+
+	for i := 0; i < nprocs; i++ {
+		stats = append(stats, <-done...)
+	}
+
+	return
+}
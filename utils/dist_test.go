@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"sort"
+	"testing"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+)
+
+func TestDistances(t *testing.T) {
+	var ols []oligo.Oligo
+	for _, s := range []string{"ACGTACGT", "ACGTACGA", "ACGTACCA", "TTTTTTTT"} {
+		ols = append(ols, long.FromString1(s))
+	}
+
+	p := NewPool(ols, false)
+	stats := p.Distances(nil, nil)
+	if len(stats) != len(ols) {
+		t.Fatalf("%d stats expected %d", len(stats), len(ols))
+	}
+
+	// the oligos are processed in parallel, the order isn't kept
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Oligo.String() < stats[j].Oligo.String() })
+	for _, st := range stats {
+		mindist, sum := -1, 0
+		var closest []string
+		for _, o := range ols {
+			if o.String() == st.Oligo.String() {
+				continue
+			}
+
+			d := oligo.Distance(st.Oligo, o)
+			sum += d
+			if mindist < 0 || d < mindist {
+				mindist, closest = d, nil
+			}
+
+			if d == mindist {
+				closest = append(closest, o.String())
+			}
+		}
+
+		if st.MinDist != mindist || st.AvgDist != float64(sum) / float64(len(ols) - 1) || len(st.MinOls) != len(closest) {
+			t.Fatalf("%v: %+v expected min %d %v", st.Oligo, st, mindist, closest)
+		}
+
+		for i, o := range st.MinOls {
+			if o.String() != closest[i] {
+				t.Fatalf("%v: closest %v expected %v", st.Oligo, st.MinOls, closest)
+			}
+		}
+	}
+
+	// only the specified oligos, to the whole pool
+	stats = p.Distances(p.Oligos()[0:1], nil)
+	if len(stats) != 1 || stats[0].Oligo != p.Oligos()[0] || stats[0].MinDist != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
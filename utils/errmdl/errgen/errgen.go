@@ -59,31 +59,34 @@ func (em  *ErrgenErrorModel) GenMany(numreads int, ols []oligo.Oligo) (rs []olig
 }
 
 func (em *ErrgenErrorModel) genOneLocked(ol oligo.Oligo) (r oligo.Oligo, errnum int) {
-	actions := em.seqs[em.rnd.Int31n(int32(len(em.seqs)))].Diff
+	al := em.seqs[em.rnd.Int31n(int32(len(em.seqs)))].Align
 	sol := ol.String()
 
 	var ret string
-	for ai, oi := 0, 0; oi < len(sol) && ai < len(actions); ai++ {
-		a := actions[ai]
+	oi := 0
+	for _, op := range al.Ops {
+		if oi >= len(sol) {
+			break
+		}
 
-		switch a {
-		case '-':
+		switch op.Type {
+		case oligo.OpMatch:
 			ret += string(sol[oi])
 			oi++
 
-		case 'D':
+		case oligo.OpDel:
 			oi++
 			errnum++
 
-		case 'I':
-			ret += oligo.Nt2String(int(rand.Int31n(4)))
-			errnum++
-
-		case 'A', 'T', 'C', 'G':
-			ret += string(a)
+		case oligo.OpIns:
+			nt := op.BNt
+			if nt < 0 {
+				nt = int(rand.Int31n(4))
+			}
+			ret += oligo.Nt2String(nt)
 			errnum++
 
-		case 'R':
+		case oligo.OpSub:
 			for {
 				nt := oligo.Nt2String(int(rand.Int31n(4)))
 				if sol[oi] != nt[0] {
@@ -68,7 +68,7 @@ func newDist(p float64, dist []float64, olen int) (ret *Dist) {
 		ret.pdist[i] = 2*dist[i] - ret.pdist[i+1]
 	}
 
-	fmt.Printf("newDist p %v e %v\n", p, e/float64(len(dist)))
+//	fmt.Printf("newDist p %v e %v\n", p, e/float64(len(dist)))
 	// FIXME: not sure this is right...
 	ret.p =  e/float64(len(dist))
 
@@ -1,7 +1,6 @@
 package simple
 
 import (
-	"fmt"
 	"math/rand"
 	"sort"
 	"sync"
@@ -153,7 +152,7 @@ func (em  *SimpleErrorModel) GenMany(numreads int, ols []oligo.Oligo) (rs []olig
 }
 
 func (em *SimpleErrorModel) SortedErrors(ol oligo.Oligo, minprob float64) (ret []errmdl.OligoProb) {
-	fmt.Printf("Sorted Errors: insertion %v deletion %v substitutions %v\n", em.erri, em.errdi - em.erri, em.err - em.errdi)
+//	fmt.Printf("Sorted Errors: insertion %v deletion %v substitutions %v\n", em.erri, em.errdi - em.erri, em.err - em.errdi)
 	m := make(map[string] float64)
 
 	seq := ol.String()
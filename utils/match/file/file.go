@@ -21,7 +21,7 @@ type Match struct {
 	Read	oligo.Oligo	// the read that is matched to it
 	Count	int		// number of reads
 	Cubu	float64		// read cubundance
-	Diff	string		// difference between original and the read
+	Align	*oligo.Alignment	// alignment of the read to the original
 }
 
 // Reads a match file.
@@ -31,12 +31,12 @@ type Match struct {
 func Read(fname string) (matches [][]*Match, err error) {
 	ms := make(map[int][]*Match)
 	maxid := 0
-	err = Parse(fname, func(id, count int, diff string, cubu float64, orig, read oligo.Oligo) {
+	err = Parse(fname, func(id, count int, al *oligo.Alignment, cubu float64, orig, read oligo.Oligo) {
 		m := new(Match)
 		m.Id = id
 		m.Count = count
 		m.Cubu = cubu
-		m.Diff = diff
+		m.Align = al
 		m.Orig = orig
 		m.Read = read
 
@@ -58,7 +58,44 @@ func Read(fname string) (matches [][]*Match, err error) {
 	return
 }
 
-func Parse(fname string, process func(id, count int, diff string, cubu float64, orig, read oligo.Oligo)) (err error) {
+// Converts the difference string produced by the old versions of the
+// match utility (see oligo.Diff) to an extended CIGAR string
+func diffCigar(diff string) (cigar string) {
+	var c byte
+
+	n := 0
+	for i := 0; i <= len(diff); i++ {
+		var dc byte
+
+		if i < len(diff) {
+			switch diff[i] {
+			case '-':
+				dc = '='
+			case 'R':
+				dc = 'X'
+			default:
+				dc = diff[i]
+			}
+		}
+
+		if dc != c && n != 0 {
+			cigar += fmt.Sprintf("%d%c", n, c)
+			n = 0
+		}
+
+		c = dc
+		n++
+	}
+
+	return
+}
+
+// Parses a match file. The difference between the original oligo and the
+// read is stored as an extended CIGAR string (see oligo.Alignment), the
+// files that use the older format are converted. SAM files (as written
+// by SAMWriter) are also accepted.
+// If the file doesn't contain the oligos, the nts in the alignment are -1.
+func Parse(fname string, process func(id, count int, al *oligo.Alignment, cubu float64, orig, read oligo.Oligo)) (err error) {
 	var r io.Reader
 
 	f, e := os.Open(fname)
@@ -75,7 +112,12 @@ func Parse(fname string, process func(id, count int, diff string, cubu float64,
 		f.Seek(0, 0)
 	}
 
-	sc := bufio.NewScanner(r)
+	br := bufio.NewReader(r)
+	if buf, _ := br.Peek(4096); isSAM(buf) {
+		return parseSAM(br, process)
+	}
+
+	sc := bufio.NewScanner(br)
 	n := 0
 	for sc.Scan() {
 		var id, count int
@@ -161,13 +203,32 @@ func Parse(fname string, process func(id, count int, diff string, cubu float64,
 			read = o
 		}
 
-		process(id, count, diff, cubu, orig, read)
+		if diff != "" && strings.IndexAny(diff, "0123456789") < 0 {
+			diff = diffCigar(diff)
+		}
+
+		var ao, ar oligo.Oligo
+		if sorig != "" {
+			ao = orig
+		}
+
+		if sread != "" {
+			ar = read
+		}
+
+		al, e := oligo.ParseCigar(diff, ao, ar, 0)
+		if e != nil {
+			err = fmt.Errorf("invalid line: %d '%s': %v", n, line, e)
+			return
+		}
+
+		process(id, count, al, cubu, orig, read)
 		n++
 	}
 	return
 }
 
-func ParseParallel(fname string, numprocs int, process func(id, count int, diff string, cubu float64, orig, read oligo.Oligo)) (err error) {
+func ParseParallel(fname string, numprocs int, process func(id, count int, al *oligo.Alignment, cubu float64, orig, read oligo.Oligo)) (err error) {
 	if numprocs == 0 {
 		numprocs = runtime.NumCPU()
 	}
@@ -182,13 +243,13 @@ func ParseParallel(fname string, numprocs int, process func(id, count int, diff
 					return
 				}
 
-				process(m.Id, m.Count, m.Diff, m.Cubu, m.Orig, m.Read)
+				process(m.Id, m.Count, m.Align, m.Cubu, m.Orig, m.Read)
 			}
 		}()
 	}
 
-	err = Parse(fname, func(id, count int, diff string, cubu float64, orig, read oligo.Oligo) {
-		ch <- &Match{id, orig, read, count, cubu, diff}
+	err = Parse(fname, func(id, count int, al *oligo.Alignment, cubu float64, orig, read oligo.Oligo) {
+		ch <- &Match{id, orig, read, count, cubu, al}
 	})
 
 	// wind down the goroutines
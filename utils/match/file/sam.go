@@ -0,0 +1,313 @@
+package file
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+)
+
+// SAM flags
+const (
+	samUnmapped	= 0x4
+	samSecondary	= 0x100
+	samSupplementary = 0x800
+)
+
+// Maximum Phred score written in the SAM files
+const maxPhred = 60
+
+// Writes the matches as a SAM file. The original oligos from the dataset
+// are the reference sequences, named by their index in the dataset. Besides
+// the standard NM and MD tags, the records contain the number of reads
+// (XC) and the read cubundance (XQ).
+type SAMWriter struct {
+	w	*bufio.Writer
+}
+
+// Creates a new SAM writer and writes the header with the reference
+// sequences
+func NewSAMWriter(w io.Writer, refs []oligo.Oligo, program string) (sw *SAMWriter, err error) {
+	sw = &SAMWriter{bufio.NewWriter(w)}
+	if _, err = fmt.Fprintf(sw.w, "@HD\tVN:1.6\tSO:unsorted\n"); err != nil {
+		return nil, err
+	}
+
+	for i, r := range refs {
+		if _, err = fmt.Fprintf(sw.w, "@SQ\tSN:%d\tLN:%d\n", i, r.Len()); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err = fmt.Fprintf(sw.w, "@PG\tID:%s\tPN:%s\n", program, program); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Writes a match. If the match doesn't have an alignment, the read is
+// written as unmapped. The quality contains the probabilities that the
+// nts of the read are correct, it can be nil.
+func (sw *SAMWriter) Write(name string, m *Match, quality []float64) (err error) {
+	qual := "*"
+	if quality != nil {
+		q := make([]byte, len(quality))
+		for i, p := range quality {
+			phred := maxPhred
+			if p < 1 {
+				phred = int(-10*math.Log10(1 - p))
+				if phred > maxPhred {
+					phred = maxPhred
+				}
+			}
+
+			q[i] = byte(phred + 33)
+		}
+
+		qual = string(q)
+	}
+
+	if m.Align == nil {
+		_, err = fmt.Fprintf(sw.w, "%s\t%d\t*\t0\t0\t*\t*\t0\t0\t%v\t%s\tXC:i:%d\tXQ:f:%v\n", name, samUnmapped,
+			m.Read, qual, m.Count, m.Cubu)
+		return
+	}
+
+	al := m.Align
+	_, err = fmt.Fprintf(sw.w, "%s\t0\t%d\t%d\t255\t%s\t*\t0\t0\t%v\t%s\tNM:i:%d\tMD:Z:%s\tXC:i:%d\tXQ:f:%v\n",
+		name, m.Id, al.AStart + 1, al.Cigar(), m.Read, qual, al.Dist, al.MD(), m.Count, m.Cubu)
+
+	return
+}
+
+// Flushes the buffered data to the underlying writer
+func (sw *SAMWriter) Flush() error {
+	return sw.w.Flush()
+}
+
+// Returns true if the data looks like a SAM file
+func isSAM(buf []byte) bool {
+	if len(buf) > 0 && buf[0] == '@' {
+		return true
+	}
+
+	n := strings.IndexByte(string(buf), '\n')
+	if n < 0 {
+		n = len(buf)
+	}
+
+	return len(strings.Split(string(buf[0:n]), "\t")) >= 11
+}
+
+// Parses a SAM file, calling process for each primary alignment. The id
+// is the index of the reference sequence in the header. The reference
+// nts in the alignment are recovered from the MD tag (if present), the
+// original oligo contains the aligned part of the reference. The count
+// and the cubundance are read from the XC and XQ tags, if they are
+// missing the count is 1 and the cubundance is calculated from the
+// read's quality scores.
+// As in the match files, process is called with count 0 for the
+// reference sequences that don't have any reads (after all records
+// are processed).
+func parseSAM(r *bufio.Reader, process func(id, count int, al *oligo.Alignment, cubu float64, orig, read oligo.Oligo)) (err error) {
+	refs := make(map[string]int)
+	seen := make(map[int]bool)
+
+	for n := 1; ; n++ {
+		line, e := r.ReadString('\n')
+		if e != nil && (e != io.EOF || line == "") {
+			if e != io.EOF {
+				err = e
+			}
+			break
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+
+		if line[0] == '@' {
+			if strings.HasPrefix(line, "@SQ\t") {
+				for _, f := range strings.Split(line, "\t")[1:] {
+					if strings.HasPrefix(f, "SN:") {
+						refs[f[3:]] = len(refs)
+					}
+				}
+			}
+			continue
+		}
+
+		ls := strings.Split(line, "\t")
+		if len(ls) < 11 {
+			return fmt.Errorf("line %d: invalid SAM record", n)
+		}
+
+		flag, e := strconv.ParseUint(ls[1], 10, 16)
+		if e != nil {
+			return fmt.Errorf("line %d: invalid flag: %v", n, e)
+		}
+
+		if flag & (samUnmapped | samSecondary | samSupplementary) != 0 || ls[2] == "*" {
+			continue
+		}
+
+		id, ok := refs[ls[2]]
+		if !ok {
+			return fmt.Errorf("line %d: unknown reference: %s", n, ls[2])
+		}
+
+		pos, e := strconv.Atoi(ls[3])
+		if e != nil || pos < 1 {
+			return fmt.Errorf("line %d: invalid position: %s", n, ls[3])
+		}
+
+		read, ok := long.FromString(strings.ToUpper(ls[9]))
+		if !ok {
+			return fmt.Errorf("line %d: invalid sequence: %s", n, ls[9])
+		}
+
+		count, cubu, md := 1, math.NaN(), ""
+		for _, t := range ls[11:] {
+			if len(t) < 5 {
+				continue
+			}
+
+			switch t[0:5] {
+			case "MD:Z:":
+				md = t[5:]
+
+			case "XC:i:":
+				if count, e = strconv.Atoi(t[5:]); e != nil {
+					return fmt.Errorf("line %d: invalid count: %v", n, e)
+				}
+
+			case "XQ:f:":
+				if cubu, e = strconv.ParseFloat(t[5:], 64); e != nil {
+					return fmt.Errorf("line %d: invalid cubundance: %v", n, e)
+				}
+			}
+		}
+
+		if math.IsNaN(cubu) {
+			cubu = float64(count)
+			if ls[10] != "*" {
+				for i := 0; i < len(ls[10]); i++ {
+					cubu *= 1 - math.Pow(10, -float64(ls[10][i] - 33)/10)
+				}
+			}
+		}
+
+		al, e := oligo.ParseCigar(ls[5], nil, read, pos - 1)
+		if e != nil {
+			return fmt.Errorf("line %d: %v", n, e)
+		}
+
+		orig := long.New(0)
+		if md != "" {
+			var sb strings.Builder
+
+			if e := applyMD(al, md); e != nil {
+				return fmt.Errorf("line %d: %v", n, e)
+			}
+
+			for _, op := range al.Ops {
+				if op.Type != oligo.OpIns {
+					sb.WriteString(oligo.Nt2String(op.ANt))
+				}
+			}
+
+			if o, ok := long.FromString(sb.String()); ok {
+				orig = o
+			}
+		}
+
+		seen[id] = true
+		process(id, count, al, cubu, orig, read)
+	}
+
+	if err == nil {
+		for id := 0; id < len(refs); id++ {
+			if !seen[id] {
+				process(id, 0, &oligo.Alignment{}, 0, long.New(0), long.New(0))
+			}
+		}
+	}
+
+	return
+}
+
+// Sets the nts of the first (reference) oligo in the alignment from
+// the value of the SAM MD tag, updating the substitutions
+func applyMD(al *oligo.Alignment, md string) error {
+	k := 0
+	next := func() (*oligo.Op, error) {
+		for ; k < len(al.Ops) && al.Ops[k].Type == oligo.OpIns; k++ {
+		}
+
+		if k >= len(al.Ops) {
+			return nil, fmt.Errorf("MD %s longer than the alignment", md)
+		}
+
+		k++
+		return &al.Ops[k-1], nil
+	}
+
+	for i := 0; i < len(md); {
+		switch c := md[i]; {
+		case c >= '0' && c <= '9':
+			j := i
+			for ; j < len(md) && md[j] >= '0' && md[j] <= '9'; j++ {
+			}
+
+			cnt, _ := strconv.Atoi(md[i:j])
+			for ; cnt > 0; cnt-- {
+				op, err := next()
+				if err != nil {
+					return err
+				}
+
+				if op.Type == oligo.OpSub {
+					al.Dist--
+				}
+
+				op.Type, op.ANt = oligo.OpMatch, op.BNt
+			}
+			i = j
+
+		case c == '^':
+			for i++; i < len(md) && (md[i] < '0' || md[i] > '9'); i++ {
+				op, err := next()
+				if err != nil {
+					return err
+				}
+
+				if op.Type != oligo.OpDel {
+					return fmt.Errorf("MD %s doesn't match the alignment", md)
+				}
+
+				op.ANt = oligo.String2Nt(string(md[i]))
+			}
+
+		default:
+			op, err := next()
+			if err != nil {
+				return err
+			}
+
+			if op.Type == oligo.OpMatch {
+				al.Dist++
+			}
+
+			op.Type, op.ANt = oligo.OpSub, oligo.String2Nt(string(c))
+			i++
+		}
+	}
+
+	return nil
+}
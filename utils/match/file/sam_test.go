@@ -0,0 +1,116 @@
+package file
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+)
+
+func TestSAM(t *testing.T) {
+	refs := []oligo.Oligo{
+		long.FromString1("ACGTACGTACGTAAAA"),
+		long.FromString1("TTTTGGGGCCCCAAAA"),
+		long.FromString1("CATCATCATCATCATC"),
+	}
+
+	reads := []oligo.Oligo{
+		long.FromString1("ACGTTCGTACGTAAA"),
+		long.FromString1("CATCATGCATCATCATC"),
+	}
+
+	var buf bytes.Buffer
+	w, err := NewSAMWriter(&buf, refs, "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	var als []*oligo.Alignment
+	for i, r := range reads {
+		id := i * 2
+		al := oligo.Align(refs[id], r)
+		als = append(als, al)
+		if err := w.Write("r", &Match{Id: id, Read: r, Count: 3, Cubu: 2.5, Align: al}, nil); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	w.Write("u", &Match{Read: long.FromString1("GGGGGGGG"), Count: 1, Cubu: 1}, nil)
+	w.Flush()
+
+	fname := filepath.Join(t.TempDir(), "m.sam")
+	if err := os.WriteFile(fname, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	ms, err := Read(fname)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(ms) != 3 || len(ms[0]) != 1 || len(ms[2]) != 1 || len(ms[1]) != 1 || ms[1][0].Count != 0 {
+		t.Fatalf("invalid matches: %v", ms)
+	}
+
+	for i, al := range als {
+		m := ms[i * 2][0]
+		if m.Count != 3 || m.Cubu != 2.5 || m.Read.Cmp(reads[i]) != 0 || m.Orig.Cmp(refs[i * 2]) != 0 {
+			t.Fatalf("invalid match %d: %v %v %v", i, m.Orig, m.Read, m.Count)
+		}
+
+		if m.Align.ExtendedCigar() != al.ExtendedCigar() || m.Align.Dist != al.Dist || m.Align.MD() != al.MD() {
+			t.Fatalf("invalid alignment %d: %s %s", i, m.Align.ExtendedCigar(), al.ExtendedCigar())
+		}
+	}
+}
+
+func TestSAMNoMD(t *testing.T) {
+	refs := []oligo.Oligo{
+		long.FromString1("ACGTACGTACGTAAAA"),
+	}
+
+	read := long.FromString1("ACGTTCGTACGTAAA")
+	al := oligo.Align(refs[0], read)
+
+	var buf bytes.Buffer
+	w, err := NewSAMWriter(&buf, refs, "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if err := w.Write("r", &Match{Id: 0, Read: read, Count: 1, Cubu: 1, Align: al}, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	w.Flush()
+
+	// without the MD tag the reference nts are not known, the aligned
+	// bases count as matches
+	sam := regexp.MustCompile(`\tMD:Z:[^\t]*`).ReplaceAllString(buf.String(), "")
+	fname := filepath.Join(t.TempDir(), "m.sam")
+	if err := os.WriteFile(fname, []byte(sam), 0644); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	ms, err := Read(fname)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	indels := 0
+	for _, op := range al.Ops {
+		if op.Type == oligo.OpIns || op.Type == oligo.OpDel {
+			indels++
+		}
+	}
+
+	if len(ms) != 1 || len(ms[0]) != 1 {
+		t.Fatalf("invalid matches: %v", ms)
+	}
+
+	if m := ms[0][0]; m.Align.Dist != indels || m.Align.Dist == al.Dist {
+		t.Fatalf("distance %d expected %d", m.Align.Dist, indels)
+	}
+}
@@ -6,10 +6,10 @@ import (
 	"math/rand"
 	"os"
 	"adscodex/oligo"
-	"adscodex/oligo/long"
-	"adscodex/io/csv"
-	"adscodex/io/fastq"
+	"adscodex/seqio"
 	"adscodex/utils"
+	"adscodex/search"
+	"adscodex/utils/match/file"
 )
 
 var printOligos = flag.Bool("p", true, "print oligo and reads for each match")
@@ -18,12 +18,13 @@ var pdist = flag.Int("pdist", 3, "primer match distance")
 var mdist = flag.Int("maxdist", 64, "maximum distance for matching")
 var p5 = flag.String("p5", "", "5'-end primer")
 var p3 = flag.String("p3", "", "3'-end primer")
-var ftype = flag.String("ft", "fastq", "input file type")
+var ftype = flag.String("ft", "auto", "input file type (auto, csv, fasta, fastq, or sam)")
+var samOut = flag.Bool("sam", false, "write the matches in SAM format, with the synthesis oligos as reference sequences")
 
 type Match struct {
 	oligo	oligo.Oligo		// the original oligo from the synthesis file
 	seq	*utils.Oligo		// the sequence from the results
-	diff	string			// difference from the oligo to seq
+	align	*oligo.Alignment	// alignment of seq to the oligo
 }
 
 func main() {
@@ -33,7 +34,7 @@ func main() {
 	if *p5 != "" {
 		var ok bool
 
-		pr5, ok = long.FromString(*p5)
+		pr5, ok = oligo.NewPattern(*p5)
 		if !ok {
 			fmt.Fprintf(os.Stderr, "invalid 5'-end primer: %s\n", *p5)
 			return
@@ -43,7 +44,7 @@ func main() {
 	if *p3 != "" {
 		var ok bool
 
-		pr3, ok = long.FromString(*p3)
+		pr3, ok = oligo.NewPattern(*p3)
 		if !ok {
 			fmt.Fprintf(os.Stderr, "invalid 3'-end primer: %s\n", *p3)
 			return
@@ -55,7 +56,7 @@ func main() {
 		return
 	}
 
-	dspool, err := utils.ReadPool([]string { *datasetFile }, false, csv.Parse)
+	dspool, err := utils.ReadPool([]string { *datasetFile }, false, seqio.Parse)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		return
@@ -67,17 +68,13 @@ func main() {
 	}
 
 	fmt.Fprintf(os.Stderr, "Reading data\n")
-	parse := fastq.Parse
-	switch (*ftype) {
-	default:
-		fmt.Fprintf(os.Stderr, "Error: invalid input file type: %s\n", *ftype)
-	case "csv":
-		parse = csv.Parse
-
-	case "fastq":
+	format, err := seqio.ParseFormatName(*ftype)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
 	}
 
-	pool, err := utils.ReadPool(fnames, true, parse)
+	pool, err := utils.ReadPool(fnames, true, seqio.Parser(format))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		return
@@ -96,7 +93,7 @@ func main() {
 	nprocs := pool.Parallel(1024, func (ols []*utils.Oligo) {
 		var ret []*Match
 		for _, ol := range ols {
-			var mss []utils.DistSeq
+			var mss []search.DistSeq
 			var d int
 
 			// find some matches
@@ -124,6 +121,10 @@ func main() {
 			switch len(matches) {
 			case 0:
 				fmt.Fprintf(os.Stderr, "no match for %v\n", ol)
+				if *samOut {
+					// unmapped reads are included in the SAM output
+					ret = append(ret, &Match{nil, ol, nil})
+				}
 				continue
 
 			case 1:
@@ -138,7 +139,7 @@ func main() {
 			m := new(Match)
 			m.oligo = match
 			m.seq = ol
-			_, m.diff = oligo.Diff(m.oligo, m.seq)
+			m.align = oligo.Align(m.oligo, m.seq)
 
 			ret = append(ret, m)
 		}
@@ -155,6 +156,14 @@ func main() {
 		}
 	}
 
+	if *samOut {
+		if err := writeSAM(dspool.Oligos(), omap); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+
+		return
+	}
+
 	// print oligos and the diffs
 	for i, s := range dspool.Oligos() {
 		ms := omap[s]
@@ -171,12 +180,51 @@ func main() {
 		for _, m := range ms {
 			if *printOligos {
 				// print everything, takes more storage
-				fmt.Printf("%d %d %v %v %v %v\n", i, m.seq.Count(), m.diff, m.seq.Qubundance(), m.oligo, m.seq)
+				fmt.Printf("%d %d %v %v %v %v\n", i, m.seq.Count(), m.align.ExtendedCigar(), m.seq.Qubundance(), m.oligo, m.seq)
 			} else {
 				// print only important stuff
-				fmt.Printf("%d %d %v %v\n", i, m.seq.Count(), m.diff, m.seq.Qubundance())
+				fmt.Printf("%d %d %v %v\n", i, m.seq.Count(), m.align.ExtendedCigar(), m.seq.Qubundance())
 			}
 
 		}
 	}
 }
+
+func writeSAM(oligos []*utils.Oligo, omap map[oligo.Oligo][]*Match) error {
+	w, err := file.NewSAMWriter(os.Stdout, utils.ToOligoArray(oligos), "match")
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	write := func(id int, m *Match) error {
+		fm := &file.Match{Id: id, Orig: m.oligo, Read: m.seq, Count: m.seq.Count(), Cubu: m.seq.Qubundance(), Align: m.align}
+
+		var qual []float64
+		if m.seq.Qubundances() != nil {
+			qual = make([]float64, m.seq.Len())
+			for i := range qual {
+				qual[i] = m.seq.QualityAt(i)
+			}
+		}
+
+		n++
+		return w.Write(fmt.Sprintf("r%d", n), fm, qual)
+	}
+
+	for i, s := range oligos {
+		for _, m := range omap[s] {
+			if err := write(i, m); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, m := range omap[nil] {
+		if err := write(-1, m); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
@@ -70,18 +70,9 @@ func main() {
 	var cnt uint64
 	var err error
 	for fn := 0; fn < flag.NArg(); fn++ {
-		err = file.ParseParallel(flag.Arg(fn), 0, func(id, count int, diff string, cubu float64, orig, read oligo.Oligo) {
-			if *maxerr != 0 {
-				nerr := 0
-				for i := 0; i < len(diff); i++ {
-					if diff[i] != '-' {
-						nerr++
-					}
-				}
-
-				if nerr > *maxerr {
-					return
-				}
+		err = file.ParseParallel(flag.Arg(fn), 0, func(id, count int, al *oligo.Alignment, cubu float64, orig, read oligo.Oligo) {
+			if *maxerr != 0 && al.Dist > *maxerr {
+				return
 			}
 
 			if p5 != nil && p3 != nil {
@@ -104,60 +95,45 @@ func main() {
 				}
 
 				read = read.Slice(ppos, spos)
-				_, diff = oligo.Diff(orig, read)
-//				fmt.Printf("%v\n%v\n%v\n", orig, read, diff)
+				al = oligo.Align(orig, read)
 			}
 
 			var nts, erri, errd, errs int
 			var imap, dmap, nmap [4]int
 			var smap [4][4] int
 
-			i, j := 0, 0
-			olen := orig.Len()
-			rlen := read.Len()
-			nts += count * olen
+			nts += count * orig.Len()
 			var icnt, dcnt, scnt int
-			for a := 0; a < len(diff); a++ {
+			for _, op := range al.Ops {
 				atomic.AddUint64(&total, 1)
-				if olen <= i || rlen <= j {
+				if (op.Type != oligo.OpIns && op.ANt < 0) || ((op.Type == oligo.OpIns || op.Type == oligo.OpSub) && op.BNt < 0) {
+					// the match file doesn't have the oligos
 					atomic.AddUint64(&bugged, 1)
-//					fmt.Printf("\nOrig: %v %d\n", orig, i)
-//					fmt.Printf("Read: %v %d\n", read, j)
-//					fmt.Printf("Diff: %v %d\n", diff, a)
 					continue
 				}
-				switch diff[a] {
-				case '-':
+
+				switch op.Type {
+				case oligo.OpMatch:
 					// put the accurate matches in the table with the substitutions X->X
-					c := orig.At(i)
+					c := op.ANt
 					nmap[c] += count
 					smap[c][c] += count
-					i++
-					j++
 
-				case 'I':
+				case oligo.OpIns:
 					erri += count
-					c := read.At(j)
-					imap[c] += count
+					imap[op.BNt] += count
 					icnt++
-					j++
 
-				case 'D':
+				case oligo.OpDel:
 					errd += count
-					oc := orig.At(i)
-					dmap[oc] += count
+					dmap[op.ANt] += count
 					dcnt++
-					i++
 
-				case 'R':
+				case oligo.OpSub:
 					errs += count
-					oc := orig.At(i)
-					c := read.At(j)
-					nmap[oc] += count
-					smap[oc][c] += count
+					nmap[op.ANt] += count
+					smap[op.ANt][op.BNt] += count
 					scnt++
-					i++
-					j++
 				}
 			}
 
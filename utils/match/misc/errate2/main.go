@@ -205,55 +205,31 @@ func parseRecords() (recs []*Rec, dictnum, dictmax int, err error) {
 
 	dmatch := make(map[int]int)
 	for fn := 0; fn < flag.NArg(); fn++ {
-		err = file.ParseParallel(flag.Arg(fn), 0, func(id, count int, diff string, cubu float64, orig, read oligo.Oligo) {
-			if *maxerr != 0 {
-				nerr := 0
-				for i := 0; i < len(diff); i++ {
-					if diff[i] != '-' {
-						nerr++
-					}
-				}
-
-				if nerr > *maxerr {
-					return
-				}
+		err = file.ParseParallel(flag.Arg(fn), 0, func(id, count int, al *oligo.Alignment, cubu float64, orig, read oligo.Oligo) {
+			if *maxerr != 0 && al.Dist > *maxerr {
+				return
 			}
 
-//			var nts, erri, errd, errs int
-//			var imap, dmap, nmap [4]int
-//			var smap [4][4] int
-
 			r := new(Rec)
 			r.olen = orig.Len()
 			r.count = count
-			i, j := 0, 0
-			for a := 0; a < len(diff); a++ {
-				switch diff[a] {
-				case '-':
+			for _, op := range al.Ops {
+				switch op.Type {
+				case oligo.OpMatch:
 					// put the accurate matches in the table with the substitutions X->X
-					c := orig.At(i)
+					c := op.ANt
 					r.nmap[c]++
 					r.smap[c][c]++
-					i++
-					j++
-
-				case 'I':
-					c := read.At(j)
-					r.imap[c]++
-					j++
-
-				case 'D':
-					oc := orig.At(i)
-					r.dmap[oc]++
-					i++
-
-				case 'R':
-					oc := orig.At(i)
-					c := read.At(j)
-					r.nmap[oc]++
-					r.smap[oc][c]++
-					i++
-					j++
+
+				case oligo.OpIns:
+					r.imap[op.BNt]++
+
+				case oligo.OpDel:
+					r.dmap[op.ANt]++
+
+				case oligo.OpSub:
+					r.nmap[op.ANt]++
+					r.smap[op.ANt][op.BNt]++
 				}
 			}
 
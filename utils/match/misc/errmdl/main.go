@@ -168,7 +168,7 @@ func main() {
 	uniqnum := 0
 	var err error
 	for fn := 0; fn < flag.NArg(); fn++ {
-		err = file.ParseParallel(flag.Arg(fn), 0, func(id, count int, diff string, cubu float64, orig, read oligo.Oligo) {
+		err = file.ParseParallel(flag.Arg(fn), 0, func(id, count int, al *oligo.Alignment, cubu float64, orig, read oligo.Oligo) {
 			if p5 != nil && p3 != nil {
 				// ignore reads that don't have the primers
 				ppos, plen := oligo.Find(read, p5, *dist)
@@ -189,59 +189,30 @@ func main() {
 				}
 
 				read = read.Slice(ppos, spos)
-				_, diff = oligo.Diff(orig, read)
-//				fmt.Printf("%v\n%v\n%v\n", orig, read, diff)
+				al = oligo.Align(orig, read)
 			}
 
-			if *errnum != 0 {
-				nerr := 0
-				for i := 0; i < len(diff); i++ {
-					if diff[i] != '-' {
-						nerr++
-					}
-				}
-
-				if nerr > *errnum {
-					return
-				}
+			if *errnum != 0 && al.Dist > *errnum {
+				return
 			}
 
 			node := root
 
-			i, j := 0, 0
 			olen := orig.Len()
-			rlen := read.Len()
 			depth := 0
-			for a := 0; a < len(diff); a++ {
-				if olen <= i || rlen <= j {
-//					fmt.Printf("\nOrig: %v %d\n", orig, i)
-//					fmt.Printf("Read: %v %d\n", read, j)
-//					fmt.Printf("Diff: %v %d\n", diff, a)
-				}
-				switch diff[a] {
-				case '-':
-					i++
-					j++
-
-				case 'I':
-					c := read.At(j)
-					node = node.Add(Ins, c, 0)
+			for _, op := range al.Ops {
+				switch op.Type {
+				case oligo.OpIns:
+					node = node.Add(Ins, op.BNt, 0)
 					depth++
-					j++
 
-				case 'D':
-					oc := orig.At(i)
-					node = node.Add(Del, oc, 0)
+				case oligo.OpDel:
+					node = node.Add(Del, op.ANt, 0)
 					depth++
-					i++
 
-				case 'R':
-					oc := orig.At(i)
-					c := read.At(j)
-					node = node.Add(Sub, c, oc)
+				case oligo.OpSub:
+					node = node.Add(Sub, op.BNt, op.ANt)
 					depth++
-					i++
-					j++
 				}
 			}
 
@@ -29,18 +29,9 @@ func main() {
 
 	ms := make(map[int]*entry)
 	n := 0
-	err := file.Parse(flag.Arg(0), func(id, count int, diff string, cubu float64, orig, read oligo.Oligo) {
-		if *err != 0 {
-			nerr := 0
-			for i := 0; i < len(diff); i++ {
-				if diff[i] != '-' {
-					nerr++
-				}
-			}
-
-			if *err < nerr {
-				return
-			}
+	err := file.Parse(flag.Arg(0), func(id, count int, al *oligo.Alignment, cubu float64, orig, read oligo.Oligo) {
+		if *err != 0 && *err < al.Dist {
+			return
 		}
 
 		if m, ok := ms[id]; ok {
@@ -4,6 +4,7 @@ import (
 _	"fmt"
 	"adscodex/oligo"
 	"adscodex/oligo/long"
+	"adscodex/oligo/packed"
 )
 
 type Oligo struct {
@@ -12,11 +13,63 @@ type Oligo struct {
 	qubund	[]float64	// qubundance (sum of the qualities at the position for all reads)
 }
 
+// If true, the new oligos are stored as packed oligos (2 bits per nt)
+// instead of long ones (1 byte per nt)
+var packedOligos bool
+
+// What FromString does with the sequences with unknown nts (N)
+var npolicy = oligo.NDrop
+
+// Sets whether FromString and Copy create packed oligos
+func SetPacked(p bool) {
+	packedOligos = p
+}
+
+// Sets the policy for the sequences with unknown nts. If the unknown
+// nts are kept, the oligo is always stored as a long oligo.
+func SetNPolicy(np oligo.NPolicy) {
+	npolicy = np
+}
+
+func newOligo(s string) (ol oligo.Oligo, ok bool) {
+	if packedOligos {
+		ol, ok = packed.FromString(s)
+	} else {
+		ol, ok = long.FromString(s)
+	}
+
+	if !ok && npolicy == oligo.NKeep {
+		ol, ok = long.FromStringN(s)
+	}
+
+	return
+}
+
+func copyOligo(o oligo.Oligo) (ret oligo.Oligo) {
+	if _, ok := o.(*packed.Oligo); ok || (packedOligos && !hasUnknown(o)) {
+		ret, _ = packed.Copy(o)
+	} else {
+		ret, _ = long.Copy(o)
+	}
+
+	return
+}
+
+func hasUnknown(o oligo.Oligo) bool {
+	for i := 0; i < o.Len(); i++ {
+		if o.At(i) == oligo.N {
+			return true
+		}
+	}
+
+	return false
+}
+
 func FromString(s string, qubu []float64) (o *Oligo, ok bool) {
 //	fmt.Printf("%v %v\n", s, qubu)
 	o = new(Oligo)
 	o.count = 1
-	o.ol, ok = long.FromString(s)
+	o.ol, ok = newOligo(s)
 	if !ok {
 		o = nil
 		return
@@ -39,14 +92,14 @@ func Copy(o oligo.Oligo) (ret *Oligo, ok bool) {
 	ret = new(Oligo)
 
 	if ol, ok := o.(*Oligo); ok {
-		ret.ol, _ = long.Copy(ol.ol)
+		ret.ol = copyOligo(ol.ol)
 		ret.count = ol.count
 		if ol.qubund != nil {
 			ret.qubund = make([]float64, len(ol.qubund))
 			copy(ret.qubund, ol.qubund)
 		}
 	} else {
-		ret.ol, _ = long.Copy(o)
+		ret.ol = copyOligo(o)
 		ret.count = 1
 	}
 
@@ -198,7 +251,11 @@ func (o *Oligo) Qubundances() []float64 {
 }
 
 func  (o *Oligo) Reverse() {
-	oligo.Reverse(o)
+	if p, ok := o.ol.(*packed.Oligo); ok {
+		p.Reverse()
+	} else {
+		oligo.Reverse(o)
+	}
 
 	// reverse the quality array
 	for n, i := len(o.qubund), 0; i < n/2; i++ {
@@ -207,7 +264,11 @@ func  (o *Oligo) Reverse() {
 }
 
 func  (o *Oligo) Invert() {
-	oligo.Invert(o)
+	if p, ok := o.ol.(*packed.Oligo); ok {
+		p.Invert()
+	} else {
+		oligo.Invert(o)
+	}
 }
 
 func (o *Oligo) Oligo() oligo.Oligo {
@@ -218,14 +279,14 @@ func (o *Oligo) Trim(prefix, suffix oligo.Oligo, dist int, keep bool) oligo.Olig
 	var ppos, spos, plen, slen int
 
 	if prefix != nil {
-		ppos, plen = oligo.Find(o, prefix, dist)
+		ppos, plen = oligo.Find(o.ol, prefix, dist)
 		if ppos < 0 {
 			return nil
 		}
 	}
 
 	if suffix != nil {
-		spos, slen = oligo.Find(o, suffix, dist)
+		spos, slen = oligo.Find(o.ol, suffix, dist)
 		if spos < 0 {
 			return nil
 		}
@@ -5,12 +5,13 @@ import (
 	"runtime"
 	"sort"
 	"adscodex/oligo"
+	"adscodex/search"
 )
 
 type Pool struct {
 	oligos	[]*Oligo
 
-	trie	*Trie
+	trie	*search.Trie
 }
 
 func NewPool(ols []oligo.Oligo, unique bool) *Pool {
@@ -206,11 +207,11 @@ func (p *Pool) InitSearch() (err error) {
 		return nil
 	}
 
-	p.trie, err = NewTrie(p.oligos)
+	p.trie, err = search.NewTrie(ToOligoArray(p.oligos))
 	return
 }
 
-func (p *Pool) Trie() *Trie {
+func (p *Pool) Trie() *search.Trie {
 	if p.trie == nil {
 		p.InitSearch()
 	}
@@ -218,7 +219,7 @@ func (p *Pool) Trie() *Trie {
 	return p.trie
 }
 
-func (p *Pool) Search(ol oligo.Oligo, dist int) (match []DistSeq) {
+func (p *Pool) Search(ol oligo.Oligo, dist int) (match []search.DistSeq) {
 	if p.trie == nil {
 		panic("InitSearch has to be called before Search can be used")
 	}
@@ -226,7 +227,7 @@ func (p *Pool) Search(ol oligo.Oligo, dist int) (match []DistSeq) {
 	return p.trie.Search(ol, dist)
 }
 
-func (p *Pool) SearchMin(ol oligo.Oligo) (match *DistSeq) {
+func (p *Pool) SearchMin(ol oligo.Oligo) (match *search.DistSeq) {
 	if p.trie == nil {
 		panic("InitSearch has to be called before Search can be used")
 	}
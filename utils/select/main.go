@@ -8,9 +8,8 @@ import (
 	"sort"
 	"sync"
 	"adscodex/oligo"
-	"adscodex/oligo/long"
+	"adscodex/seqio"
 	"adscodex/io/fastq"
-	"adscodex/io/csv"
 	"adscodex/utils"
 )
 
@@ -27,11 +26,15 @@ var p5 = flag.String("p5", "", "5'-end primer")
 var p3 = flag.String("p3", "", "3'-end primer")
 var unique = flag.Bool("uq", true, "select unique oligos")
 var datasetFile = flag.String("ds", "", "dataset file")
-var ftype = flag.String("t", "fastq", "file type")
+var ftype = flag.String("t", "auto", "file type (auto, csv, fasta, fastq, or sam)")
 var oligolen = flag.Int("l", 0, "if not zero, select only oligos with length +/- 10% of the specified value")
 var useqscore = flag.Bool("q", true, "use quality score (if available)")
 var pcut = flag.Bool("pcut", false, "remove the primers")
 var printids = flag.Bool("printids", false, "print oligos' ids")
+var packed = flag.Bool("packed", false, "store the oligos as packed (2 bits per nt)")
+var paired = flag.Bool("paired", false, "the files are paired-end fastq files (R1 R2 R1 R2 ...), the overlapping read pairs are merged")
+var split = flag.Bool("split", false, "split long (nanopore) reads into all strands delimited by the primers, in both orientations")
+var keepn = flag.Bool("keepn", false, "keep the reads with unknown nts (N) instead of dropping them")
 
 var pr5, pr3 oligo.Oligo
 var dspool *utils.Pool
@@ -40,15 +43,20 @@ var umap map[string]*utils.Oligo
 var idmap map[string][]string
 var total, selected, prcount uint64
 var dsmap map[string]bool
+var splitter *utils.Splitter
 
 func main() {
 
 	flag.Parse()
+	utils.SetPacked(*packed)
+	if *keepn {
+		utils.SetNPolicy(oligo.NKeep)
+	}
 
 	if *p5 != "" {
 		var ok bool
 
-		pr5, ok = long.FromString(*p5)
+		pr5, ok = oligo.NewPattern(*p5)
 		if !ok {
 			fmt.Fprintf(os.Stderr, "invalid 5'-end primer: %s\n", *p5)
 			return
@@ -58,7 +66,7 @@ func main() {
 	if *p3 != "" {
 		var ok bool
 
-		pr3, ok = long.FromString(*p3)
+		pr3, ok = oligo.NewPattern(*p3)
 		if !ok {
 			fmt.Fprintf(os.Stderr, "invalid 3'-end primer: %s\n", *p3)
 			return
@@ -66,10 +74,19 @@ func main() {
 	}
 
 
+	if *split {
+		if pr5 == nil || pr3 == nil {
+			fmt.Fprintf(os.Stderr, "splitting reads requires both primers\n")
+			return
+		}
+
+		splitter = utils.NewSplitter(pr5, pr3, *pdist, !*pcut)
+	}
+
 	if *datasetFile != "" {
 		var err error
 
-		dspool, err = utils.ReadPool([]string { *datasetFile }, false, csv.Parse)
+		dspool, err = utils.ReadPool([]string { *datasetFile }, false, seqio.Parse)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			return
@@ -97,6 +114,11 @@ func main() {
 		go seqproc(ch, ech)
 	}
 
+	if *paired && flag.NArg() % 2 != 0 {
+		fmt.Fprintf(os.Stderr, "Error: expecting R1 and R2 file pairs\n")
+		return
+	}
+
 	for i := 0; i < flag.NArg(); i++ {
 		fmt.Fprintf(os.Stderr, "Processing %s\n", flag.Arg(i))
 		fname := flag.Arg(i)
@@ -109,17 +131,24 @@ func main() {
                         return nil
                 }
 
-		var err error
-		switch (*ftype) {
-		default:
-			fmt.Fprintf(os.Stderr, "Error: invalid file type: %s\n", *ftype)
+		format, err := seqio.ParseFormatName(*ftype)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			return
+		}
 
-		case "csv":
-			err = csv.Parse(fname, fproc)
-
-		case "fastq":
-			err = fastq.Parse(fname, fproc)
+		if *paired {
+			i++
+			fmt.Fprintf(os.Stderr, "Processing %s\n", flag.Arg(i))
+			err = fastq.ParsePaired(fname, flag.Arg(i), fproc)
+		} else if *split {
+			// the splitter looks for the strands in both orientations,
+			// process each read only once
+			err = seqio.ParseRecords(fname, format, func(rec *seqio.Record) error {
+				return fproc(rec.Id, rec.Sequence, rec.Quality, rec.Orientation == seqio.Reverse)
+			})
+		} else {
+			err = seqio.ParseFormat(fname, format, fproc)
 		}
 
 		if err != nil {
@@ -169,6 +198,15 @@ func main() {
 	} else {
 		fmt.Fprintf(os.Stderr, "\nTotal: %d, selected %d, with primers %d\n", total, selected, prcount)
 	}
+
+	if splitter != nil {
+		st := splitter.Stats()
+		fmt.Fprintf(os.Stderr, "Reads: %d, strands %d, without strands %d, multiple strands %d, chimeras %d, orphan primers %d\n",
+			st.Reads, st.Strands, st.Empty, st.Multi, st.Chimeras, st.Orphans)
+		if st.TotalNts != 0 {
+			fmt.Fprintf(os.Stderr, "Adapter content: %d of %d nts (%.2f%%)\n", st.AdapterNts, st.TotalNts, float64(st.AdapterNts) * 100 / float64(st.TotalNts))
+		}
+	}
 }
 
 func seqproc(ch chan Seq, ech chan bool) {
@@ -210,50 +248,17 @@ func seqproc(ch chan Seq, ech chan bool) {
 			ol.Invert()
 		}
 
-		tol1 := ol.Trim(pr5, pr3, *pdist, !*pcut)
-		if tol1 == nil {
-			continue
-		}
-
-		tol := tol1.(*utils.Oligo)
-		if *oligolen != 0 {
-			tlen := float64(tol.Len())
-			olen := float64(*oligolen)
-			if tlen < olen*0.85 || tlen > olen*1.15 {
-				continue
+		var tols []*utils.Oligo
+		if splitter != nil {
+			for _, st := range splitter.Split(ol) {
+				tols = append(tols, st.Oligo.(*utils.Oligo))
 			}
+		} else if tol := ol.Trim(pr5, pr3, *pdist, !*pcut); tol != nil {
+			tols = append(tols, tol.(*utils.Oligo))
 		}
 
-		prcnt++
-		if dspool != nil {
-			ms := dspool.Search(tol, *dist)
-			if ms == nil {
-				// doesn't match an oligo in the dataset
-				continue
-			}
-
-			ulock.Lock()
-			for _, m := range ms {
-				dsmap[m.Seq.String()] = true
-			}
-			ulock.Unlock()
-		}
-
-		ss := tol.String()
-		if *unique {
-			ulock.Lock()
-			if o, ok := umap[ss]; ok {
-				o.Inc(tol.Count(), tol.Qubundances())
-			} else {
-				umap[ss] = tol
-			}
-
-			if *printids {
-				idmap[ss] = append(idmap[ss], s.id)
-			}
-			ulock.Unlock()
-		} else {
-			fmt.Printf("%v %v %v %s\n", ss, ol.Qubundance(), ol.Count(), s.id)
+		for _, tol := range tols {
+			selectOligo(s, ol, tol, &prcnt)
 		}
 	}
 
@@ -278,3 +283,46 @@ func seqproc(ch chan Seq, ech chan bool) {
 
 	ech <- true
 }
+
+// Selects the trimmed oligo (tol) from the read (ol)
+func selectOligo(s Seq, ol, tol *utils.Oligo, prcnt *uint64) {
+	if *oligolen != 0 {
+		tlen := float64(tol.Len())
+		olen := float64(*oligolen)
+		if tlen < olen*0.85 || tlen > olen*1.15 {
+			return
+		}
+	}
+
+	(*prcnt)++
+	if dspool != nil {
+		ms := dspool.Search(tol, *dist)
+		if ms == nil {
+			// doesn't match an oligo in the dataset
+			return
+		}
+
+		ulock.Lock()
+		for _, m := range ms {
+			dsmap[m.Seq.String()] = true
+		}
+		ulock.Unlock()
+	}
+
+	ss := tol.String()
+	if *unique {
+		ulock.Lock()
+		if o, ok := umap[ss]; ok {
+			o.Inc(tol.Count(), tol.Qubundances())
+		} else {
+			umap[ss] = tol
+		}
+
+		if *printids {
+			idmap[ss] = append(idmap[ss], s.id)
+		}
+		ulock.Unlock()
+	} else {
+		fmt.Printf("%v %v %v %s\n", ss, ol.Qubundance(), ol.Count(), s.id)
+	}
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+	"adscodex/oligo"
+)
+
+// Strand found in a (long) read by the Splitter
+type Strand struct {
+	Oligo	oligo.Oligo	// the strand, in forward (5'->3') orientation
+	Start	int		// start position in the read
+	End	int		// end position in the read
+	Reverse	bool		// true if the strand is reverse complemented in the read
+}
+
+// Statistics collected by the Splitter
+type SplitStats struct {
+	Reads		uint64	// number of reads
+	Strands		uint64	// number of strands found
+	Empty		uint64	// reads without any strand
+	Multi		uint64	// reads with more than one strand (concatemers)
+	Chimeras	uint64	// reads with strands in both orientations, or with mismatched primers
+	Orphans		uint64	// primers that don't delimit a strand
+	TotalNts	uint64	// number of nts in all reads
+	AdapterNts	uint64	// number of nts outside the strands and their primers (adapters, partial strands)
+}
+
+// Splits reads that can contain more than one strand (nanopore reads with
+// adapters, concatenated oligos, or chimeras) into strands delimited by
+// the primers. The strands are looked up in both orientations.
+type Splitter struct {
+	dist	int
+	keep	bool
+	prims	[4]oligo.Oligo	// primers, indexed by the hit kind
+
+	sync.Mutex
+	stats	SplitStats
+}
+
+// Kinds of primer hits
+const (
+	fwdStart = iota	// 5'-end primer
+	fwdEnd		// 3'-end primer
+	revStart	// reverse complement of the 3'-end primer
+	revEnd		// reverse complement of the 5'-end primer
+)
+
+type primerHit struct {
+	kind	int
+	pos	int
+	len	int
+}
+
+// Creates a new splitter. The primers can contain IUPAC codes
+// (oligo.Pattern), dist is the number of errors allowed in a primer.
+// If keep is true, the primers are kept in the strands.
+func NewSplitter(p5, p3 oligo.Oligo, dist int, keep bool) *Splitter {
+	s := &Splitter{dist: dist, keep: keep}
+	s.prims[fwdStart] = p5
+	s.prims[fwdEnd] = p3
+	s.prims[revStart] = reverseComplement(p3)
+	s.prims[revEnd] = reverseComplement(p5)
+
+	return s
+}
+
+func reverseComplement(o oligo.Oligo) oligo.Oligo {
+	if p, ok := o.(*oligo.Pattern); ok {
+		return p.ReverseComplement()
+	}
+
+	r := o.Clone()
+	oligo.Reverse(r)
+	oligo.Invert(r)
+	return r
+}
+
+// Returns the strands found in the read. The Oligo field of the strands
+// has the same type as the read.
+func (s *Splitter) Split(ol oligo.Oligo) (strands []Strand) {
+	var hits []primerHit
+
+	// look for the primers in the underlying oligo, slicing utils.Oligo
+	// copies the qualities
+	sol := ol
+	if uol, ok := ol.(*Oligo); ok {
+		sol = uol.Oligo()
+	}
+
+	for k, p := range s.prims {
+		hits = s.findAll(hits, k, sol, p, 0, sol.Len())
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].pos < hits[j].pos
+	})
+
+	// drop the hits that overlap with the previous one
+	n := 0
+	for _, h := range hits {
+		if n > 0 && h.pos < hits[n-1].pos + hits[n-1].len {
+			continue
+		}
+
+		hits[n] = h
+		n++
+	}
+	hits = hits[0:n]
+
+	var orphans, chimera, fwd, rev, covered int
+	for i := 0; i < len(hits); i++ {
+		h := hits[i]
+		if h.kind != fwdStart && h.kind != revStart || i + 1 == len(hits) {
+			orphans++
+			continue
+		}
+
+		e := hits[i + 1]
+		if e.kind != h.kind + 1 {
+			// start followed by a primer that doesn't end the strand
+			if e.kind == fwdEnd || e.kind == revEnd {
+				chimera = 1
+			}
+
+			orphans++
+			continue
+		}
+
+		st := Strand{Start: h.pos + h.len, End: e.pos, Reverse: h.kind == revStart}
+		if s.keep {
+			st.Start, st.End = h.pos, e.pos + e.len
+		}
+
+		st.Oligo = ol.Slice(st.Start, st.End)
+		if st.Reverse {
+			if uol, ok := st.Oligo.(*Oligo); ok {
+				uol.Reverse()
+				uol.Invert()
+			} else {
+				oligo.Reverse(st.Oligo)
+				oligo.Invert(st.Oligo)
+			}
+			rev++
+		} else {
+			fwd++
+		}
+
+		strands = append(strands, st)
+		covered += e.pos + e.len - h.pos
+		i++
+	}
+
+	if fwd > 0 && rev > 0 {
+		chimera = 1
+	}
+
+	s.Lock()
+	s.stats.Reads++
+	s.stats.Strands += uint64(len(strands))
+	switch len(strands) {
+	case 0:
+		s.stats.Empty++
+	case 1:
+	default:
+		s.stats.Multi++
+	}
+	s.stats.Chimeras += uint64(chimera)
+	s.stats.Orphans += uint64(orphans)
+	s.stats.TotalNts += uint64(ol.Len())
+	s.stats.AdapterNts += uint64(ol.Len() - covered)
+	s.Unlock()
+
+	return
+}
+
+// Finds all non-overlapping occurrences of the primer in ol[start:end]
+func (s *Splitter) findAll(hits []primerHit, kind int, ol, prim oligo.Oligo, start, end int) []primerHit {
+	if end - start <= 0 || end - start < prim.Len() - s.dist {
+		return hits
+	}
+
+	pos, l := oligo.Find(ol.Slice(start, end), prim, s.dist)
+	if pos < 0 || l == 0 {
+		return hits
+	}
+
+	pos += start
+	hits = append(hits, primerHit{kind, pos, l})
+	hits = s.findAll(hits, kind, ol, prim, start, pos)
+	return s.findAll(hits, kind, ol, prim, pos + l, end)
+}
+
+// Returns the statistics collected so far
+func (s *Splitter) Stats() SplitStats {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.stats
+}
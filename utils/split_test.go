@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"math/rand"
+	"testing"
+	"adscodex/oligo"
+	"adscodex/oligo/long"
+)
+
+func randomOligo(n int) oligo.Oligo {
+	o := long.New(n)
+	for i := 0; i < n; i++ {
+		o.Set(i, rand.Intn(4))
+	}
+
+	return o
+}
+
+func rc(o oligo.Oligo) oligo.Oligo {
+	r := o.Clone()
+	oligo.Reverse(r)
+	oligo.Invert(r)
+	return r
+}
+
+func concat(ols ...oligo.Oligo) oligo.Oligo {
+	r := long.New(0)
+	for _, o := range ols {
+		r.Append(o)
+	}
+
+	return r
+}
+
+func TestSplit(t *testing.T) {
+	p5 := long.FromString1("CGACATCTCGATGGCAGCAT")
+	p3 := long.FromString1("CAGTGAGCTGGCAACTTCCA")
+	for i := 0; i < 50; i++ {
+		s := NewSplitter(p5, p3, 2, false)
+		pl1, pl2, pl3 := randomOligo(100), randomOligo(100), randomOligo(100)
+
+		// adapter, forward strand, reverse strand, truncated strand
+		read := concat(randomOligo(30), p5, pl1, p3, randomOligo(10), rc(concat(p5, pl2, p3)), p5, pl3)
+		strands := s.Split(read)
+		if len(strands) != 2 {
+			t.Fatalf("expected 2 strands, got %d", len(strands))
+		}
+
+		if strands[0].Reverse || strands[0].Oligo.Cmp(pl1) != 0 {
+			t.Fatalf("invalid forward strand: %v expected %v", strands[0].Oligo, pl1)
+		}
+
+		if !strands[1].Reverse || strands[1].Oligo.Cmp(pl2) != 0 {
+			t.Fatalf("invalid reverse strand: %v expected %v", strands[1].Oligo, pl2)
+		}
+
+		st := s.Stats()
+		if st.Reads != 1 || st.Strands != 2 || st.Multi != 1 || st.Chimeras != 1 || st.Orphans != 1 {
+			t.Fatalf("invalid stats: %+v", st)
+		}
+
+		if st.AdapterNts != uint64(30 + 10 + p5.Len() + pl3.Len()) {
+			t.Fatalf("invalid adapter nts: %d", st.AdapterNts)
+		}
+
+		// keeping the primers
+		s = NewSplitter(p5, p3, 2, true)
+		strands = s.Split(read)
+		if len(strands) != 2 || strands[1].Oligo.Cmp(concat(p5, pl2, p3)) != 0 {
+			t.Fatalf("invalid strands with primers: %v", strands)
+		}
+	}
+}